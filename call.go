@@ -0,0 +1,178 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"context"
+	"net/rpc"
+	"sync"
+	"time"
+)
+
+// A CallOption configures a call made by CallContext or GoContext.
+type CallOption func(*callInfo)
+
+type callInfo struct {
+	trailer  *Metadata
+	priority int32
+	creds    Credentials
+}
+
+// Trailer returns a CallOption that stores the response trailer in md.
+func Trailer(md *Metadata) CallOption {
+	return func(info *callInfo) {
+		info.trailer = md
+	}
+}
+
+// Priority returns a CallOption that sends the call with priority p:
+// servers with a Scheduler start the waiting calls of higher priority
+// first. The default priority is 0.
+func Priority(p int32) CallOption {
+	return func(info *callInfo) {
+		info.priority = p
+	}
+}
+
+// CallCredentials returns a CallOption that attaches the metadata of
+// creds to the request, in place of the Credentials of the options of
+// the client.
+func CallCredentials(creds Credentials) CallOption {
+	return func(info *callInfo) {
+		info.creds = creds
+	}
+}
+
+// clientCall is sent through rpc.Client in place of the args of a call
+// made by GoContext, the client codec unwraps it.
+type clientCall struct {
+	ctx      context.Context // for the trace context and the span of the call
+	args     interface{}
+	md       Metadata
+	deadline time.Time // the deadline of ctx, sent as the request timeout
+	info     callInfo
+	stream   *ClientStream // nil if not made by NewStream
+	oneWay   bool          // made by OneWay, no response is read
+
+	// set by the codec when the request is sent
+	codec *clientCodec
+	seq   uint64
+
+	finished   chan struct{} // closed when the response is read
+	finishOnce sync.Once
+}
+
+func newClientCall(ctx context.Context, args interface{}, opts ...CallOption) *clientCall {
+	c := &clientCall{ctx: ctx, args: args, finished: make(chan struct{})}
+	c.md, _ = MetadataFromOutgoingContext(ctx)
+	c.deadline, _ = ctx.Deadline()
+	for _, opt := range opts {
+		opt(&c.info)
+	}
+	return c
+}
+
+func (c *clientCall) finish() {
+	c.finishOnce.Do(func() { close(c.finished) })
+}
+
+// watch cancels the call when ctx is done before the response is read.
+func (c *clientCall) watch(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+		c.codec.cancel(c.seq, ctx.Err())
+	case <-c.finished:
+	case <-c.codec.done:
+	}
+}
+
+// CallContext invokes the named function on a Protobuf-RPC client, waits
+// for it to complete, and returns its error status. The metadata of ctx
+// (see NewOutgoingContext) and its trace context (see NewTraceContext)
+// are sent with the request, and the deadline of ctx is sent as the
+// request timeout.
+//
+// If ctx is done before the call completes, the call is canceled:
+// CallContext returns a status error with CodeDeadlineExceeded or
+// CodeCanceled, which wraps ctx.Err(), and the server cancels the
+// context of the handler. reply and the trailer must not be used.
+func CallContext(ctx context.Context, client *rpc.Client, serviceMethod string, args, reply interface{}, opts ...CallOption) error {
+	call := GoContext(ctx, client, serviceMethod, args, reply, make(chan *rpc.Call, 1), opts...)
+	select {
+	case call = <-call.Done:
+		return call.Error
+	case <-ctx.Done():
+		return contextError(ctx.Err())
+	}
+}
+
+// GoContext invokes the function asynchronously like rpc.Client.Go, and
+// sends the metadata, trace context and deadline of ctx with the request. If ctx is
+// done before the call completes, the call is canceled and completes
+// with a status error with CodeDeadlineExceeded or CodeCanceled.
+func GoContext(ctx context.Context, client *rpc.Client, serviceMethod string, args, reply interface{}, done chan *rpc.Call, opts ...CallOption) *rpc.Call {
+	if err := ctx.Err(); err != nil {
+		call := &rpc.Call{
+			ServiceMethod: serviceMethod,
+			Args:          args,
+			Reply:         reply,
+			Error:         contextError(err),
+			Done:          done,
+		}
+		if call.Done == nil {
+			call.Done = make(chan *rpc.Call, 1)
+		}
+		select {
+		case call.Done <- call:
+		default:
+			// like rpc.Client.Go, do not block on a full done channel
+		}
+		return call
+	}
+
+	c := newClientCall(ctx, args, opts...)
+
+	// rpc.Client.Go sends the request before it returns
+	call := client.Go(serviceMethod, c, reply, done)
+	call.Args = args
+	if c.codec != nil && ctx.Done() != nil {
+		go c.watch(ctx)
+	}
+	return call
+}
+
+// OneWay sends a one-way call of the named function: the server serves
+// it but sends no response, the errors of the call are reported to the
+// Options.OneWayErrorHandler of the server. OneWay returns once the
+// request is queued for sending, it only returns the errors of the
+// client, like a canceled ctx or a broken connection. The metadata, trace
+// context and deadline of ctx are sent like by CallContext.
+//
+// Servers which do not know one-way calls send a response, which the
+// client drops.
+func OneWay(ctx context.Context, client *rpc.Client, serviceMethod string, args interface{}, opts ...CallOption) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+
+	c := newClientCall(ctx, args, opts...)
+	c.oneWay = true
+
+	// the codec completes the call once the request is queued
+	call := <-client.Go(serviceMethod, c, nil, make(chan *rpc.Call, 1)).Done
+	return call.Error
+}
+
+// contextError returns the status error of a context error.
+func contextError(err error) error {
+	code := CodeUnknown
+	switch err {
+	case context.DeadlineExceeded:
+		code = CodeDeadlineExceeded
+	case context.Canceled:
+		code = CodeCanceled
+	}
+	return &Status{Code: code, Message: err.Error(), err: err}
+}
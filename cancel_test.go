@@ -0,0 +1,106 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"context"
+	"net"
+	"net/rpc"
+	"testing"
+	"time"
+
+	msg "github.com/chai2010/protorpc/examples/message.pb"
+)
+
+type testBlock struct {
+	canceled chan string
+}
+
+// Block waits until its context is canceled, or replies at once if the
+// message is "now".
+func (t *testBlock) Block(args *msg.EchoRequest, reply *msg.EchoResponse) error {
+	if args.Msg == "now" {
+		reply.Msg = args.Msg
+		return nil
+	}
+	<-RequestContext(args).Done()
+	t.canceled <- args.Msg
+	reply.Msg = args.Msg
+	return nil
+}
+
+func TestCancel(t *testing.T) {
+	srv := rpc.NewServer()
+	block := &testBlock{canceled: make(chan string, 10)}
+	if err := srv.RegisterName("BlockService", block); err != nil {
+		t.Fatal(err)
+	}
+	cliConn, srvConn := net.Pipe()
+	codec := NewServerCodec(srvConn).(*serverCodec)
+	go srv.ServeCodec(codec)
+	client := NewClientWithOptions(cliConn, &Options{Handshake: true})
+	defer client.Close()
+
+	// pipelined calls, the first two are canceled
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	var reply1, reply2, reply3 msg.EchoResponse
+	call1 := GoContext(ctx1, client, "BlockService.Block", &msg.EchoRequest{Msg: "1"}, &reply1, nil)
+	call2 := GoContext(ctx2, client, "BlockService.Block", &msg.EchoRequest{Msg: "2"}, &reply2, nil)
+	call3 := GoContext(context.Background(), client, "BlockService.Block", &msg.EchoRequest{Msg: "now"}, &reply3, nil)
+
+	if call3 = <-call3.Done; call3.Error != nil || reply3.Msg != "now" {
+		t.Fatalf("call3: %v %q", call3.Error, reply3.Msg)
+	}
+
+	cancel2()
+	if call2 = <-call2.Done; StatusFromError(call2.Error).Code != CodeCanceled {
+		t.Fatalf("call2: expect Canceled, got %v", call2.Error)
+	}
+	if got := <-block.canceled; got != "2" {
+		t.Fatalf("expect handler 2 canceled, got %q", got)
+	}
+
+	cancel1()
+	if call1 = <-call1.Done; StatusFromError(call1.Error).Code != CodeCanceled {
+		t.Fatalf("call1: expect Canceled, got %v", call1.Error)
+	}
+	if got := <-block.canceled; got != "1" {
+		t.Fatalf("expect handler 1 canceled, got %q", got)
+	}
+
+	// the connection is still usable, and the server forgot the canceled calls
+	var reply msg.EchoResponse
+	if err := CallContext(context.Background(), client, "BlockService.Block", &msg.EchoRequest{Msg: "now"}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	codec.mutex.Lock()
+	n := len(codec.pending) + len(codec.seqs) + len(codec.canceled)
+	codec.mutex.Unlock()
+	if n != 0 {
+		t.Fatalf("server codec keeps %d requests", n)
+	}
+}
+
+func TestCancelUnknownMethod(t *testing.T) {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("EchoService", new(testEcho)); err != nil {
+		t.Fatal(err)
+	}
+	cliConn, srvConn := net.Pipe()
+	go srv.ServeCodec(NewServerCodec(srvConn))
+	client := NewClient(cliConn)
+	defer client.Close()
+
+	// the body of an invalid request is discarded
+	var reply msg.EchoResponse
+	if err := client.Call("EchoService.Unknown", &msg.EchoRequest{Msg: "x"}, &reply); err == nil {
+		t.Fatalf("expect error")
+	}
+	if err := client.Call("EchoService.Echo", &msg.EchoRequest{Msg: "x"}, &reply); err != nil || reply.Msg != "x" {
+		t.Fatalf("Echo: %v %q", err, reply.Msg)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+
+	wire "github.com/chai2010/protorpc/wire.pb"
+)
+
+// Names of the checksum algorithms.
+const (
+	ChecksumNone     = "none"
+	ChecksumCRC32    = "crc32"    // crc32 (IEEE), understood by old peers
+	ChecksumCRC32C   = "crc32c"   // crc32 (Castagnoli), hardware accelerated on amd64 and arm64
+	ChecksumXXHash64 = "xxhash64" // 64-bit, for large bodies
+)
+
+var checksumTypes = map[string]wire.ChecksumType{
+	ChecksumNone:     wire.ChecksumType_CHECKSUM_NONE,
+	ChecksumCRC32:    wire.ChecksumType_CHECKSUM_CRC32_IEEE,
+	ChecksumCRC32C:   wire.ChecksumType_CHECKSUM_CRC32C,
+	ChecksumXXHash64: wire.ChecksumType_CHECKSUM_XXHASH64,
+}
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+func getChecksumType(name string) (uint32, error) {
+	if name == "" {
+		return uint32(wire.ChecksumType_CHECKSUM_NONE), nil
+	}
+	if typ, ok := checksumTypes[name]; ok {
+		return uint32(typ), nil
+	}
+	return 0, fmt.Errorf("protorpc: unknown checksum %q", name)
+}
+
+// replyChecksumType returns the checksum type of a response to a request
+// with the given checksum type. Old peers only know crc32 (IEEE).
+func replyChecksumType(typ uint32) uint32 {
+	if typ == uint32(wire.ChecksumType_CHECKSUM_DEFAULT) {
+		return uint32(wire.ChecksumType_CHECKSUM_CRC32_IEEE)
+	}
+	return typ
+}
+
+// checksum returns the checksum of data: 32-bit algorithms fill sum32,
+// 64-bit algorithms fill sum64.
+func checksum(typ uint32, data []byte) (sum32 uint32, sum64 uint64) {
+	switch wire.ChecksumType(typ) {
+	case wire.ChecksumType_CHECKSUM_CRC32_IEEE:
+		return crc32.ChecksumIEEE(data), 0
+	case wire.ChecksumType_CHECKSUM_CRC32C:
+		return crc32.Checksum(data, crc32cTable), 0
+	case wire.ChecksumType_CHECKSUM_XXHASH64:
+		return 0, xxhash64(data)
+	}
+	return 0, 0
+}
+
+// ErrChecksumMismatch is the error of a received body whose checksum
+// does not match the header.
+var ErrChecksumMismatch = errors.New("protorpc: checksum mismatch")
+
+// verifyChecksum checks data against the checksum from the header.
+// Old peers do not send the checksum type: they use crc32 (IEEE), and
+// a zero checksum means no checksum.
+func verifyChecksum(typ, sum32 uint32, sum64 uint64, data []byte) bool {
+	switch wire.ChecksumType(typ) {
+	case wire.ChecksumType_CHECKSUM_DEFAULT:
+		return sum32 == 0 || crc32.ChecksumIEEE(data) == sum32
+	case wire.ChecksumType_CHECKSUM_NONE:
+		return true
+	case wire.ChecksumType_CHECKSUM_CRC32_IEEE,
+		wire.ChecksumType_CHECKSUM_CRC32C,
+		wire.ChecksumType_CHECKSUM_XXHASH64:
+		x32, x64 := checksum(typ, data)
+		return x32 == sum32 && x64 == sum64
+	}
+	return false
+}
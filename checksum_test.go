@@ -0,0 +1,99 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	msg "github.com/chai2010/protorpc/examples/message.pb"
+	wire "github.com/chai2010/protorpc/wire.pb"
+)
+
+func TestXXHash64(t *testing.T) {
+	for _, tt := range []struct {
+		s    string
+		hash uint64
+	}{
+		{"", 0xef46db3751d8e999},
+		{"a", 0xd24ec4f1a98c6e5b},
+		{"abc", 0x44bc2cf5ad770999},
+		{strings.Repeat("abcdefgh", 5), 0xfd9883f01a0a794a},
+	} {
+		if got := xxhash64([]byte(tt.s)); got != tt.hash {
+			t.Fatalf("xxhash64(%q): expected = %x, got = %x", tt.s, tt.hash, got)
+		}
+	}
+}
+
+func TestChecksumTypes(t *testing.T) {
+	opts := DefaultOptions()
+	c, _ := getCompressor(CompressionSnappy)
+	args := &msg.EchoRequest{Msg: strings.Repeat("Hello, 世界.", 100)}
+
+	for _, name := range []string{ChecksumNone, ChecksumCRC32, ChecksumCRC32C, ChecksumXXHash64} {
+		typ, err := getChecksumType(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		if err := writeRequest(&buf, opts, newCompressStats(), c, &wire.RequestHeader{Id: 1, Method: "EchoService.Echo", ChecksumType: wire.ChecksumType(typ)}, args); err != nil {
+			t.Fatal(err)
+		}
+		var header wire.RequestHeader
+		if err := readRequestHeader(&buf, opts, &header); err != nil {
+			t.Fatal(err)
+		}
+		if uint32(header.ChecksumType) != typ {
+			t.Fatalf("%s: expected checksum type = %d, got = %d", name, typ, header.ChecksumType)
+		}
+		if name == ChecksumXXHash64 && (header.Checksum != 0 || header.Checksum64 == 0) {
+			t.Fatalf("%s: expected 64-bit checksum, got = %v", name, header)
+		}
+		body := append([]byte(nil), buf.Bytes()...)
+
+		// good body
+		var reply msg.EchoRequest
+		if err := readRequestBody(bytes.NewReader(body), opts, &header, &reply); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if reply.Msg != args.Msg {
+			t.Fatalf("%s: expected = %q, got = %q", name, args.Msg, reply.Msg)
+		}
+
+		// corrupt body
+		body[len(body)-1] ^= 0x80
+		err = readRequestBody(bytes.NewReader(body), opts, &header, &reply)
+		if name != ChecksumNone && !errors.Is(err, ErrChecksumMismatch) {
+			t.Fatalf("%s: expected checksum error, got = %v", name, err)
+		}
+	}
+}
+
+func TestChecksumZero(t *testing.T) {
+	data := []byte("abc")
+
+	// a zero checksum disables verification for old peers only
+	if !verifyChecksum(uint32(wire.ChecksumType_CHECKSUM_DEFAULT), 0, 0, data) {
+		t.Fatalf("expected legacy zero checksum to be skipped")
+	}
+	for _, typ := range []wire.ChecksumType{
+		wire.ChecksumType_CHECKSUM_CRC32_IEEE,
+		wire.ChecksumType_CHECKSUM_CRC32C,
+		wire.ChecksumType_CHECKSUM_XXHASH64,
+	} {
+		if verifyChecksum(uint32(typ), 0, 0, data) {
+			t.Fatalf("%v: expected zero checksum to be verified", typ)
+		}
+	}
+
+	// unknown algorithm
+	if verifyChecksum(100, 0, 0, data) {
+		t.Fatalf("expected unknown checksum type to fail")
+	}
+}
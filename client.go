@@ -5,6 +5,9 @@
 package protorpc
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -17,77 +20,591 @@ import (
 )
 
 type clientCodec struct {
-	r io.Reader
-	w io.Writer
-	c io.Closer
+	r     *bufio.Reader
+	w     io.Writer
+	c     io.Closer
+	opts  *Options
+	stats *compressStats
 
-	// temporary work space
-	respHeader wire.ResponseHeader
+	mux    *mux         // writes the frames
+	chunks *assembler   // the responses being received in chunks
+	flight *flightLimit // the received bytes held by the codec
+
+	// With Options.Handshake, ready is closed when the handshake is done.
+	// Requests wait for it.
+	ready        chan struct{}
+	handshakeErr error
+	features     uint64 // accepted by the server
+
+	keepalive *keepalive // nil without Options.KeepaliveInterval
+
+	// Responses are read by a goroutine, so that responses of canceled
+	// calls can be injected without waiting for the server.
+	responses chan *clientResponse
+	done      chan struct{} // closed when the codec is closed or broken
+	doneOnce  sync.Once
+	closeOnce sync.Once
+	closeErr  error
+
+	// the response being read
+	resp *clientResponse
 
 	// Protobuf-RPC responses include the request id but not the request method.
 	// Package rpc expects both.
 	// We save the request method in pending when sending a request
 	// and then look it up by request ID when filling out the rpc Response.
-	mutex   sync.Mutex        // protects pending
-	pending map[uint64]string // map request id to method name
+	mutex   sync.Mutex                // protects pending
+	pending map[uint64]*clientRequest // map request id to request state
+}
+
+// clientRequest is the state of a request saved until its response is read.
+type clientRequest struct {
+	method  string
+	trailer *Metadata   // set by the Trailer call option
+	call    *clientCall // nil if not made by GoContext
+	span    *callSpan   // nil without Options.Tracer
+}
+
+// clientResponse is a response read from the connection, or injected
+// by cancel and for one-way calls.
+type clientResponse struct {
+	header wire.ResponseHeader
+	body   []byte    // the body, not decoded yet
+	local  bool      // injected by the client
+	err    error     // the connection is broken
+	span   *callSpan // the span of the call, ended by ReadResponseBody
 }
 
 // NewClientCodec returns a new rpc.ClientCodec using Protobuf-RPC on conn.
 func NewClientCodec(conn io.ReadWriteCloser) rpc.ClientCodec {
-	return &clientCodec{
-		r:       conn,
-		w:       conn,
-		c:       conn,
-		pending: make(map[uint64]string),
+	return NewClientCodecWithOptions(conn, nil)
+}
+
+// NewClientCodecWithOptions returns a new rpc.ClientCodec using Protobuf-RPC
+// on conn, configured by opts. A nil opts means DefaultOptions().
+func NewClientCodecWithOptions(conn io.ReadWriteCloser, opts *Options) rpc.ClientCodec {
+	opts = opts.clone()
+	c := &clientCodec{
+		r:         opts.newReader(conn),
+		w:         opts.newWriter(conn),
+		c:         conn,
+		opts:      opts,
+		stats:     newCompressStats(),
+		responses: make(chan *clientResponse),
+		done:      make(chan struct{}),
+		pending:   make(map[uint64]*clientRequest),
+		features:  legacyFeatures,
+	}
+	c.flight = newFlightLimit(opts)
+	c.chunks = newAssembler(c.flight)
+	c.mux = newMux(c.w, conn, true, opts)
+	if opts.Handshake {
+		c.ready = make(chan struct{})
 	}
+	go c.readLoop()
+	return c
 }
 
-func (c *clientCodec) WriteRequest(r *rpc.Request, param interface{}) error {
-	c.mutex.Lock()
-	c.pending[r.Seq] = r.ServiceMethod
-	c.mutex.Unlock()
+// waitHandshake waits for the handshake, if any, to be done.
+func (c *clientCodec) waitHandshake() error {
+	if c.ready == nil {
+		return nil
+	}
+	select {
+	case <-c.ready:
+		return c.handshakeErr
+	case <-c.done:
+		return rpc.ErrShutdown
+	}
+}
+
+func (c *clientCodec) WriteRequest(r *rpc.Request, param interface{}) (err error) {
+	if err := c.waitHandshake(); err != nil {
+		return err
+	}
+
+	req := &clientRequest{method: r.ServiceMethod}
+	header := &wire.RequestHeader{
+		Id:     r.Seq,
+		Method: r.ServiceMethod,
+	}
+
+	// unwrap the args of GoContext
+	ctx := context.Background()
+	md := Metadata(nil)
+	creds := c.opts.Credentials
+	if call, ok := param.(*clientCall); ok {
+		ctx = call.ctx
+		param = call.args
+		md = call.md
+		if call.info.creds != nil {
+			creds = call.info.creds
+		}
+		header.OneWay = call.oneWay
+		header.Priority = call.info.priority
+		req.trailer = call.info.trailer
+		req.call = call
+
+		if !call.deadline.IsZero() {
+			timeout := time.Until(call.deadline)
+			if timeout <= 0 {
+				return contextError(context.DeadlineExceeded)
+			}
+			header.Timeout = uint64((timeout + time.Microsecond - 1) / time.Microsecond)
+		}
+	}
+
+	if c.opts.Tracer != nil {
+		var span Span
+		ctx, span = c.opts.Tracer.StartClientSpan(ctx, r.ServiceMethod)
+		req.span = &callSpan{span: span}
+		defer func() {
+			// the span of a pending call ends with its response
+			if err != nil || header.OneWay {
+				req.span.end(err)
+			}
+		}()
+	}
+	header.Traceparent, header.Tracestate = traceHeader(ctx)
+
+	if creds != nil {
+		credsMD, err := creds.Metadata(ctx, r.ServiceMethod)
+		if err != nil {
+			return unauthenticated(err)
+		}
+		md = md.Copy()
+		for k, v := range credsMD {
+			md.Set(k, v...)
+		}
+	}
+	header.Metadata = md.toWire()
 
 	var request proto.Message
 	if param != nil {
 		var ok bool
-		if request, ok = param.(proto.Message); !ok {
+		if request, ok = protoMessage(param); !ok {
 			return fmt.Errorf(
 				"protorpc.ClientCodec.WriteRequest: %T does not implement proto.Message",
 				param,
 			)
 		}
 	}
-	err := writeRequest(c.w, r.Seq, r.ServiceMethod, request)
+	compressor, err := c.encoding(header)
+	if err != nil {
+		return err
+	}
+	if len(header.Metadata) != 0 && c.features&uint64(wire.Feature_FEATURE_METADATA) == 0 {
+		return errors.New("protorpc: the server does not accept metadata")
+	}
+	if req.call != nil && req.call.stream != nil && c.features&uint64(wire.Feature_FEATURE_STREAMING) == 0 {
+		return errors.New("protorpc: the server does not accept streams")
+	}
+	body, err := encodeRequest(c.opts, c.stats, compressor, header, request)
 	if err != nil {
 		return err
 	}
+	if req.call != nil && req.call.stream != nil {
+		seq := r.Seq // r is reused by rpc.Client
+		req.call.stream.queue.credit = func(n int, stream bool) {
+			c.flight.sub(n)
+			c.mux.credit(seq, n, stream)
+		}
+	}
+
+	if header.OneWay {
+		// no response is read, the call completes when the request is
+		// queued
+		if err := c.mux.send(r.Seq, header, body, true, false); err != nil {
+			return err
+		}
+		resp := &clientResponse{local: true}
+		resp.header.Id = r.Seq
+		go c.inject(resp)
+		return nil
+	}
+
+	c.mutex.Lock()
+	c.pending[r.Seq] = req
+	c.mutex.Unlock()
+
+	// rpc.Client holds its lock, do not wait for the request to be written
+	if err := c.mux.send(r.Seq, header, body, false, false); err != nil {
+		c.mutex.Lock()
+		delete(c.pending, r.Seq)
+		c.mutex.Unlock()
+		return err
+	}
 
+	if req.call != nil {
+		req.call.codec, req.call.seq = c, r.Seq
+	}
 	return nil
 }
 
-func (c *clientCodec) ReadResponseHeader(r *rpc.Response) error {
-	header := wire.ResponseHeader{}
-	err := readResponseHeader(c.r, &header)
+// encoding returns the compressor of the bodies sent, and fills the
+// checksum type and serialization of header.
+func (c *clientCodec) encoding(header *wire.RequestHeader) (Compressor, error) {
+	compressor, err := getCompressor(c.opts.Compression)
+	if err != nil {
+		return nil, err
+	}
+	checksumType, err := getChecksumType(c.opts.Checksum)
+	if err != nil {
+		return nil, err
+	}
+	serializer, err := getSerializer(c.opts.Serialization)
+	if err != nil {
+		return nil, err
+	}
+
+	// without handshake an old server would decode a corrupted body
+	// instead of failing the call
+	if !c.opts.Handshake {
+		if !legacyCompression(compressor.ID()) {
+			return nil, fmt.Errorf("protorpc: compression %q needs Options.Handshake", c.opts.Compression)
+		}
+		if !legacyChecksum(checksumType) {
+			return nil, fmt.Errorf("protorpc: checksum %q needs Options.Handshake", c.opts.Checksum)
+		}
+	}
+
+	// only use the features accepted by the server
+	if f := compressionFeature(compressor.ID()); c.features&f != f {
+		compressor = noneCompressor{}
+	}
+	if f := checksumFeature(checksumType); c.features&f != f {
+		checksumType = uint32(wire.ChecksumType_CHECKSUM_CRC32_IEEE)
+	}
+	serialization := serializer.ID()
+	if f := serializationFeature(serialization); c.features&f != f {
+		serialization = uint32(wire.SerializationType_SERIALIZATION_PROTO)
+	}
+	header.ChecksumType = wire.ChecksumType(checksumType)
+	header.Serialization = serialization
+	return compressor, nil
+}
+
+// writeData sends m on the stream id, and waits until it is written. It
+// returns io.EOF if the stream ended.
+func (c *clientCodec) writeData(id uint64, m proto.Message) error {
+	header := &wire.RequestHeader{
+		Id:        id,
+		FrameType: wire.FrameType_FRAME_STREAM_DATA,
+	}
+	compressor, err := c.encoding(header)
+	if err != nil {
+		return err
+	}
+	body, err := encodeRequest(c.opts, c.stats, compressor, header, m)
 	if err != nil {
 		return err
 	}
 
+	err = c.mux.send(id, header, body, false, true)
+	if err == errMuxDropped {
+		err = io.EOF
+	}
+	return err
+}
+
+// cancel cancels the call seq: it tells the server, and injects a
+// response with the error of ctx. The response of the server, if any,
+// is dropped.
+func (c *clientCodec) cancel(seq uint64, ctxErr error) {
 	c.mutex.Lock()
-	r.Seq = header.Id
-	r.Error = header.Error
-	r.ServiceMethod = c.pending[r.Seq]
-	delete(c.pending, r.Seq)
+	req, ok := c.pending[seq]
+	delete(c.pending, seq)
 	c.mutex.Unlock()
+	if !ok {
+		return // the response is being read
+	}
 
-	c.respHeader = header
-	return nil
+	// the rest of the request is not sent, and the rest of the
+	// response is dropped
+	c.mux.drop(seq)
+	c.chunks.remove(seq)
+
+	// a failed cancel frame breaks the connection, rpc.Client
+	// sees the error when reading the next response
+	if c.features&uint64(wire.Feature_FEATURE_CANCEL) != 0 {
+		c.writeControl(seq, wire.FrameType_FRAME_CANCEL)
+	}
+
+	resp := &clientResponse{local: true, span: req.span}
+	resp.header.Id = seq
+	resp.header.Error = contextError(ctxErr).Error()
+	c.inject(resp)
+}
+
+// inject passes a response made by the client to rpc.Client, unless the
+// codec is closed.
+func (c *clientCodec) inject(resp *clientResponse) {
+	select {
+	case c.responses <- resp:
+	case <-c.done:
+	}
+}
+
+// writeControl sends a control frame with an empty body. The frames of
+// the stream id, like FRAME_STREAM_HALF_CLOSE, are sent after the queued
+// messages of id, the others are sent first.
+func (c *clientCodec) writeControl(id uint64, frameType wire.FrameType) error {
+	header := &wire.RequestHeader{Id: id, FrameType: frameType}
+	body, err := encodeRequest(c.opts, nil, noneCompressor{}, header, nil)
+	if err != nil {
+		return err
+	}
+	if frameType == wire.FrameType_FRAME_STREAM_HALF_CLOSE {
+		return c.mux.send(id, header, body, false, false)
+	}
+	return c.mux.sendControl(header)
+}
+
+func (c *clientCodec) ping() error {
+	return c.writeControl(pingID, wire.FrameType_FRAME_PING)
+}
+
+// dead breaks the connection to a server which stopped answering: the
+// pending calls fail with err.
+func (c *clientCodec) dead(err error) {
+	select {
+	case c.responses <- &clientResponse{err: err}:
+	case <-c.done:
+	}
+	c.closeConn()
+}
+
+func (c *clientCodec) readLoop() {
+	// no request is written before the handshake is done
+	if c.ready != nil {
+		// a server older than the handshake waits for the rest of the
+		// frame it thinks the preamble starts
+		timeout := c.opts.HandshakeTimeout
+		if timeout <= 0 {
+			timeout = DefaultHandshakeTimeout
+		}
+		timer := time.AfterFunc(timeout, func() { c.closeConn() })
+		var info handshakeInfo
+		info, c.handshakeErr = clientHandshake(c.r, c.w, c.opts)
+		if !timer.Stop() {
+			c.handshakeErr = ErrHandshakeTimeout
+		}
+		c.features = info.features
+		if c.features&uint64(wire.Feature_FEATURE_MUX) != 0 {
+			c.mux.enableFlow(info)
+		}
+		close(c.ready)
+		if c.handshakeErr != nil {
+			c.mux.close(c.handshakeErr)
+			select {
+			case c.responses <- &clientResponse{err: c.handshakeErr}:
+			case <-c.done:
+			}
+			return
+		}
+	}
+	if c.opts.KeepaliveInterval > 0 && c.features&uint64(wire.Feature_FEATURE_PING) != 0 {
+		c.keepalive = newKeepalive(c.opts, c.ping, c.dead, c.done)
+		go c.keepalive.run()
+	}
+
+	for {
+		resp, err := c.recv()
+		if err != nil {
+			// the connection is poisoned, the server may not stop
+			c.mux.close(err)
+			c.closeConn()
+			resp = &clientResponse{err: err}
+		} else {
+			switch resp.header.FrameType {
+			case wire.FrameType_FRAME_PING:
+				c.writeControl(resp.header.Id, wire.FrameType_FRAME_PONG)
+				continue
+			case wire.FrameType_FRAME_PONG:
+				continue
+			case wire.FrameType_FRAME_WINDOW_UPDATE:
+				c.mux.update(resp.header.Id, resp.header.Window, resp.header.ConnWindow)
+				continue
+			case wire.FrameType_FRAME_STREAM_DATA:
+				c.pushData(resp)
+				continue
+			}
+		}
+		select {
+		case c.responses <- resp:
+		case <-c.done:
+			return
+		}
+		if resp.err != nil {
+			return
+		}
+	}
+}
+
+// recv reads the next frame, the bodies sent in chunks are assembled.
+func (c *clientCodec) recv() (*clientResponse, error) {
+	for {
+		resp := new(clientResponse)
+		header := &resp.header
+		if err := readResponseHeader(c.r, c.opts, header); err != nil {
+			return nil, err
+		}
+		if c.keepalive != nil {
+			c.keepalive.received()
+		}
+
+		if header.FrameType == wire.FrameType_FRAME_CHUNK {
+			b, n, err := c.chunks.recv(c.r, c.opts, header.Id, header.More)
+			if err != nil {
+				return nil, err
+			}
+			if b == nil {
+				c.mux.credit(header.Id, n, false)
+				continue
+			}
+			resp = b.first.(*clientResponse)
+			if _, err := c.credit(&resp.header, n); err != nil {
+				return nil, err
+			}
+			if header.More {
+				continue
+			}
+			return c.open(resp, b.body)
+		}
+
+		body, err := recvResponseBody(c.r, c.opts, header)
+		if err != nil {
+			return nil, err
+		}
+		held, err := c.credit(header, len(body))
+		if err != nil {
+			return nil, err
+		}
+		if header.More {
+			// the rest of the responses of canceled calls is dropped
+			if c.isPending(header.Id) {
+				limit := bodyLimit(c.opts, maxUint32(header.RawResponseLen, header.SnappyCompressedResponseLen))
+				if err := c.chunks.start(header.Id, resp, body, limit, time.Time{}, held); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		return c.open(resp, body)
+	}
+}
+
+// open checks and decompresses the body of resp. The frames are
+// corrupted if it fails.
+func (c *clientCodec) open(resp *clientResponse, body []byte) (*clientResponse, error) {
+	body, err := openResponseBody(&resp.header, body)
+	if err != nil {
+		return nil, err
+	}
+	resp.body = body
+	return resp, nil
+}
+
+func (c *clientCodec) isPending(id uint64) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	_, ok := c.pending[id]
+	return ok
+}
+
+// credit grants n received body bytes of header back to the server. The
+// bytes of a stream are held until its messages are read, and counted
+// in the flight limit: credit reports if they are held.
+func (c *clientCodec) credit(header *wire.ResponseHeader, n int) (bool, error) {
+	c.mutex.Lock()
+	req, ok := c.pending[header.Id]
+	c.mutex.Unlock()
+
+	switch {
+	case !ok:
+		c.mux.credit(header.Id, n, false)
+	case header.FrameType == wire.FrameType_FRAME_STREAM_DATA && req.call != nil && req.call.stream != nil:
+		if err := c.flight.add(n); err != nil {
+			return false, err
+		}
+		req.call.stream.queue.hold(n)
+		return true, nil
+	default:
+		c.mux.credit(header.Id, n, true)
+	}
+	return false, nil
+}
+
+// pushData queues a message received on a stream, the messages of
+// unknown streams are dropped.
+func (c *clientCodec) pushData(resp *clientResponse) {
+	c.mutex.Lock()
+	req, ok := c.pending[resp.header.Id]
+	c.mutex.Unlock()
+	if !ok || req.call == nil || req.call.stream == nil {
+		return
+	}
+	req.call.stream.queue.push(func(m proto.Message) error {
+		defer putBuffer(resp.body)
+		return decodeBody(resp.header.Serialization, resp.body, m)
+	})
 }
 
-func (c *clientCodec) ReadResponseBody(x interface{}) error {
+func (c *clientCodec) ReadResponseHeader(r *rpc.Response) error {
+	for {
+		var resp *clientResponse
+		select {
+		case resp = <-c.responses:
+		case <-c.done:
+			c.endSpans(rpc.ErrShutdown)
+			return io.EOF
+		}
+		if resp.err != nil {
+			c.doneOnce.Do(func() { close(c.done) })
+			c.endSpans(resp.err)
+			return resp.err
+		}
+		header := &resp.header
+
+		c.mutex.Lock()
+		req, ok := c.pending[header.Id]
+		delete(c.pending, header.Id)
+		c.mutex.Unlock()
+
+		// drop the responses of canceled calls
+		if !ok && !resp.local {
+			continue
+		}
+		if !resp.local {
+			c.mux.drop(header.Id)
+		}
+
+		r.Seq = header.Id
+		r.Error = header.Error
+		if header.Status != nil {
+			r.Error = statusFromWire(header.Status).Error()
+		}
+		if req != nil {
+			r.ServiceMethod = req.method
+			if req.trailer != nil {
+				*req.trailer = metadataFromWire(header.Trailer)
+			}
+			if req.call != nil {
+				req.call.finish()
+			}
+			resp.span = req.span
+		}
+
+		c.resp = resp
+		return nil
+	}
+}
+
+func (c *clientCodec) ReadResponseBody(x interface{}) (err error) {
 	var response proto.Message
 	if x != nil {
 		var ok bool
-		response, ok = x.(proto.Message)
+		response, ok = protoMessage(x)
 		if !ok {
 			return fmt.Errorf(
 				"protorpc.ClientCodec.ReadResponseBody: %T does not implement proto.Message",
@@ -96,18 +613,58 @@ func (c *clientCodec) ReadResponseBody(x interface{}) error {
 		}
 	}
 
-	err := readResponseBody(c.r, &c.respHeader, response)
-	if err != nil {
+	resp := c.resp
+	c.resp = nil
+	if resp.span != nil {
+		defer func() { resp.span.end(callError(&resp.header, err)) }()
+	}
+	if resp.local {
 		return nil
 	}
 
-	c.respHeader = wire.ResponseHeader{}
-	return nil
+	err = decodeBody(resp.header.Serialization, resp.body, response)
+	putBuffer(resp.body)
+	return err
+}
+
+// endSpans ends the spans of the pending calls, which fail with err.
+func (c *clientCodec) endSpans(err error) {
+	c.mutex.Lock()
+	var spans []*callSpan
+	for _, req := range c.pending {
+		if req.span != nil {
+			spans = append(spans, req.span)
+		}
+	}
+	c.mutex.Unlock()
+	for _, span := range spans {
+		span.end(err)
+	}
+}
+
+// callError returns the error of a call whose response header is
+// header, or err, the error of reading the response body.
+func callError(header *wire.ResponseHeader, err error) error {
+	if header.Status != nil {
+		return statusFromWire(header.Status)
+	}
+	if header.Error != "" {
+		return parseStatus(header.Error)
+	}
+	return err
 }
 
 // Close closes the underlying connection.
 func (c *clientCodec) Close() error {
-	return c.c.Close()
+	c.doneOnce.Do(func() { close(c.done) })
+	c.mux.close(errMuxClosed)
+	return c.closeConn()
+}
+
+// closeConn closes the connection once.
+func (c *clientCodec) closeConn() error {
+	c.closeOnce.Do(func() { c.closeErr = c.c.Close() })
+	return c.closeErr
 }
 
 // NewClient returns a new rpc.Client to handle requests to the
@@ -116,6 +673,11 @@ func NewClient(conn io.ReadWriteCloser) *rpc.Client {
 	return rpc.NewClientWithCodec(NewClientCodec(conn))
 }
 
+// NewClientWithOptions is like NewClient but uses the given options.
+func NewClientWithOptions(conn io.ReadWriteCloser, opts *Options) *rpc.Client {
+	return rpc.NewClientWithCodec(NewClientCodecWithOptions(conn, opts))
+}
+
 // Dial connects to a Protobuf-RPC server at the specified network address.
 func Dial(network, address string) (*rpc.Client, error) {
 	conn, err := net.Dial(network, address)
@@ -125,6 +687,16 @@ func Dial(network, address string) (*rpc.Client, error) {
 	return NewClient(conn), err
 }
 
+// DialWithOptions connects to a Protobuf-RPC server at the specified network
+// address, using the given options for the connection.
+func DialWithOptions(network, address string, opts *Options) (*rpc.Client, error) {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewClientWithOptions(conn, opts), err
+}
+
 // DialTimeout connects to a Protobuf-RPC server at the specified network address.
 func DialTimeout(network, address string, timeout time.Duration) (*rpc.Client, error) {
 	conn, err := net.DialTimeout(network, address, timeout)
@@ -0,0 +1,351 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	wire "github.com/chai2010/protorpc/wire.pb"
+	"github.com/golang/snappy"
+)
+
+// Names of the builtin compressors.
+const (
+	CompressionNone    = "none"
+	CompressionSnappy  = "snappy"
+	CompressionGzip    = "gzip"
+	CompressionDeflate = "deflate"
+)
+
+// A Compressor compresses request and response bodies.
+//
+// The compressor used for a body is identified on the wire by ID, so
+// both peers must register the same compressor under the same id.
+// The builtin ids are listed in wire.CompressionType.
+//
+// Compress and Decompress return new slices, which the codec owns.
+type Compressor interface {
+	ID() uint32
+	Compress(src []byte) ([]byte, error)
+	Decompress(src []byte, rawLen int) ([]byte, error)
+}
+
+// pooledCompressor is implemented by the builtin compressors, which
+// compress and decompress into new or pooled buffers owned by the
+// caller: they are returned to the pool once sent or decoded.
+type pooledCompressor interface {
+	compressPooled(src []byte) ([]byte, error)
+	decompressPooled(src []byte, rawLen int) ([]byte, error)
+}
+
+var compressors = struct {
+	sync.RWMutex
+	byName map[string]Compressor
+	byID   map[uint32]Compressor
+}{
+	byName: make(map[string]Compressor),
+	byID:   make(map[uint32]Compressor),
+}
+
+func init() {
+	RegisterCompressor(CompressionNone, noneCompressor{})
+	RegisterCompressor(CompressionSnappy, snappyCompressor{})
+	RegisterCompressor(CompressionGzip, gzipCompressor{})
+	RegisterCompressor(CompressionDeflate, deflateCompressor{})
+}
+
+// RegisterCompressor makes a compressor available by the provided name.
+// If RegisterCompressor is called twice with the same name or id,
+// the last one wins.
+func RegisterCompressor(name string, c Compressor) {
+	compressors.Lock()
+	defer compressors.Unlock()
+	compressors.byName[name] = c
+	compressors.byID[c.ID()] = c
+}
+
+func getCompressor(name string) (Compressor, error) {
+	if name == "" {
+		return noneCompressor{}, nil
+	}
+
+	compressors.RLock()
+	defer compressors.RUnlock()
+	if c, ok := compressors.byName[name]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("protorpc: unknown compressor %q", name)
+}
+
+// ErrBadCompression is the error of a received body which can not be
+// decompressed, or whose compressor is unknown.
+var ErrBadCompression = errors.New("protorpc: bad compressed body")
+
+// getCompressorByID returns the compressor of a received body.
+// Old peers do not send the compression id, and use snappy whenever
+// the snappy compressed length is not zero.
+func getCompressorByID(id, compressedLen uint32) (Compressor, error) {
+	if id == uint32(wire.CompressionType_COMPRESSION_DEFAULT) {
+		if compressedLen != 0 {
+			return snappyCompressor{}, nil
+		}
+		return noneCompressor{}, nil
+	}
+
+	compressors.RLock()
+	defer compressors.RUnlock()
+	if c, ok := compressors.byID[id]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("%w: unknown compression id %d", ErrBadCompression, id)
+}
+
+type noneCompressor struct{}
+
+func (noneCompressor) ID() uint32 {
+	return uint32(wire.CompressionType_COMPRESSION_NONE)
+}
+func (noneCompressor) Compress(src []byte) ([]byte, error) {
+	return src, nil
+}
+func (noneCompressor) Decompress(src []byte, rawLen int) ([]byte, error) {
+	return src, nil
+}
+
+type snappyCompressor struct{}
+
+func (snappyCompressor) ID() uint32 {
+	return uint32(wire.CompressionType_COMPRESSION_SNAPPY)
+}
+func (snappyCompressor) Compress(src []byte) ([]byte, error) {
+	return snappy.Encode(nil, src), nil
+}
+func (snappyCompressor) Decompress(src []byte, rawLen int) ([]byte, error) {
+	n, err := snappy.DecodedLen(src)
+	if err != nil {
+		return nil, err
+	}
+	if n > rawLen {
+		return nil, fmt.Errorf("protorpc: snappy decoded length %d, expect %d", n, rawLen)
+	}
+	return snappy.Decode(nil, src)
+}
+func (snappyCompressor) compressPooled(src []byte) ([]byte, error) {
+	return snappy.Encode(getBuffer(snappy.MaxEncodedLen(len(src))), src), nil
+}
+func (snappyCompressor) decompressPooled(src []byte, rawLen int) ([]byte, error) {
+	n, err := snappy.DecodedLen(src)
+	if err != nil {
+		return nil, err
+	}
+	if n != rawLen {
+		return nil, fmt.Errorf("protorpc: snappy decoded length %d, expect %d", n, rawLen)
+	}
+	return snappy.Decode(getBuffer(n), src)
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) ID() uint32 {
+	return uint32(wire.CompressionType_COMPRESSION_GZIP)
+}
+func (c gzipCompressor) Compress(src []byte) ([]byte, error) {
+	return c.compressPooled(src)
+}
+func (gzipCompressor) compressPooled(src []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(getBuffer(len(src)/2 + 64)[:0])
+	zw := gzip.NewWriter(buf)
+	if _, err := zw.Write(src); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+func (gzipCompressor) Decompress(src []byte, rawLen int) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return readAllLimit(zr, rawLen)
+}
+func (gzipCompressor) decompressPooled(src []byte, rawLen int) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return readPooled(zr, rawLen)
+}
+
+type deflateCompressor struct{}
+
+func (deflateCompressor) ID() uint32 {
+	return uint32(wire.CompressionType_COMPRESSION_DEFLATE)
+}
+func (c deflateCompressor) Compress(src []byte) ([]byte, error) {
+	return c.compressPooled(src)
+}
+func (deflateCompressor) compressPooled(src []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(getBuffer(len(src)/2 + 64)[:0])
+	zw, err := flate.NewWriter(buf, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(src); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+func (deflateCompressor) Decompress(src []byte, rawLen int) ([]byte, error) {
+	zr := flate.NewReader(bytes.NewReader(src))
+	defer zr.Close()
+	return readAllLimit(zr, rawLen)
+}
+func (deflateCompressor) decompressPooled(src []byte, rawLen int) ([]byte, error) {
+	zr := flate.NewReader(bytes.NewReader(src))
+	defer zr.Close()
+	return readPooled(zr, rawLen)
+}
+
+// Adaptive compression: compression of a method is turned off when the
+// average compressed/raw ratio is above adaptiveMaxRatio, and probed
+// again after adaptiveProbeInterval uncompressed bodies.
+const (
+	adaptiveMaxRatio      = 0.9
+	adaptiveProbeInterval = 64
+)
+
+// compressStats tracks per method whether compression pays off.
+type compressStats struct {
+	mutex   sync.Mutex
+	methods map[string]*methodCompressStats
+}
+
+type methodCompressStats struct {
+	ratio   float64 // moving average of compressed/raw
+	skipped int     // bodies sent uncompressed since the last probe
+}
+
+func newCompressStats() *compressStats {
+	return &compressStats{
+		methods: make(map[string]*methodCompressStats),
+	}
+}
+
+func (s *compressStats) shouldCompress(method string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	m, ok := s.methods[method]
+	if !ok || m.ratio <= adaptiveMaxRatio || m.skipped >= adaptiveProbeInterval {
+		return true
+	}
+	m.skipped++
+	return false
+}
+
+func (s *compressStats) update(method string, rawLen, compressedLen int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	ratio := float64(compressedLen) / float64(rawLen)
+	if m, ok := s.methods[method]; ok {
+		m.ratio = m.ratio*0.75 + ratio*0.25
+		m.skipped = 0
+	} else {
+		s.methods[method] = &methodCompressStats{ratio: ratio}
+	}
+}
+
+// compressBody returns the body to send for the raw data of method.
+// The body is sent uncompressed if it is shorter than Options.MinCompressLen,
+// if compression does not make it smaller, or if adaptive compression
+// turned compression off for the method.
+//
+// raw is a pooled buffer. If the body is compressed, raw is returned to
+// the pool and the body is a pooled buffer too.
+func compressBody(opts *Options, stats *compressStats, c Compressor, method string, raw []byte) (body []byte, compressed bool, err error) {
+	if c.ID() == uint32(wire.CompressionType_COMPRESSION_NONE) || len(raw) < opts.MinCompressLen {
+		return raw, false, nil
+	}
+	if opts.AdaptiveCompression && !stats.shouldCompress(method) {
+		return raw, false, nil
+	}
+
+	pc, pooled := c.(pooledCompressor)
+	if pooled {
+		body, err = pc.compressPooled(raw)
+	} else {
+		body, err = c.Compress(raw)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if opts.AdaptiveCompression && len(raw) > 0 {
+		stats.update(method, len(raw), len(body))
+	}
+	if len(body) >= len(raw) {
+		if pooled {
+			putBuffer(body)
+		}
+		return raw, false, nil
+	}
+	if !pooled {
+		// the result of a user compressor may be kept by it
+		body = append(getBuffer(len(body))[:0], body...)
+	}
+	putBuffer(raw)
+	return body, true, nil
+}
+
+// decompressBody returns the raw data of a compressed body, and whether
+// it is a buffer to return to the pool once decoded.
+func decompressBody(c Compressor, body []byte, rawLen int) (raw []byte, pooled bool, err error) {
+	if pc, ok := c.(pooledCompressor); ok {
+		raw, err = pc.decompressPooled(body, rawLen)
+		return raw, err == nil, err
+	}
+	raw, err = c.Decompress(body, rawLen)
+	return raw, false, err
+}
+
+// readAllLimit reads at most rawLen+1 bytes from r, so the caller's raw
+// length check fails instead of inflating a body of unbounded size.
+func readAllLimit(r io.Reader, rawLen int) ([]byte, error) {
+	return ioutil.ReadAll(io.LimitReader(r, int64(rawLen)+1))
+}
+
+// readPooled reads the rawLen bytes of r into a pooled buffer. It fails
+// if r has more or fewer bytes; reading to the end of r checks the
+// trailer of gzip.
+func readPooled(r io.Reader, rawLen int) ([]byte, error) {
+	raw := getBuffer(rawLen)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		putBuffer(raw)
+		return nil, fmt.Errorf("protorpc: decompressed less than %d bytes: %v", rawLen, err)
+	}
+	var extra [1]byte
+	if _, err := io.ReadFull(r, extra[:]); err != io.EOF {
+		putBuffer(raw)
+		if err == nil {
+			err = fmt.Errorf("protorpc: decompressed more than %d bytes", rawLen)
+		}
+		return nil, err
+	}
+	return raw, nil
+}
@@ -0,0 +1,261 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"bytes"
+	"math/rand"
+	"net"
+	"net/rpc"
+	"strings"
+	"testing"
+
+	msg "github.com/chai2010/protorpc/examples/message.pb"
+	wire "github.com/chai2010/protorpc/wire.pb"
+	"github.com/golang/snappy"
+)
+
+var testCompressorNames = []string{
+	CompressionNone,
+	CompressionSnappy,
+	CompressionGzip,
+	CompressionDeflate,
+}
+
+func TestCompressorRoundTrip(t *testing.T) {
+	for _, name := range testCompressorNames {
+		c, err := getCompressor(name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for _, raw := range [][]byte{nil, []byte("abc"), bytes.Repeat([]byte("Hello, 世界."), 1024)} {
+			data, err := c.Compress(raw)
+			if err != nil {
+				t.Fatalf("%s: Compress: %v", name, err)
+			}
+			got, err := c.Decompress(data, len(raw))
+			if err != nil {
+				t.Fatalf("%s: Decompress: %v", name, err)
+			}
+			if !bytes.Equal(got, raw) {
+				t.Fatalf("%s: expected = %q, got = %q", name, raw, got)
+			}
+		}
+	}
+}
+
+func TestCompressorDecompressPooled(t *testing.T) {
+	raw := bytes.Repeat([]byte("Hello, 世界."), 1024)
+	for _, name := range testCompressorNames[1:] {
+		c, _ := getCompressor(name)
+		data, err := c.Compress(raw)
+		if err != nil {
+			t.Fatalf("%s: Compress: %v", name, err)
+		}
+
+		// the body is decoded into a buffer of the pool
+		got, err := c.(pooledCompressor).decompressPooled(data, len(raw))
+		if err != nil {
+			t.Fatalf("%s: decompressPooled: %v", name, err)
+		}
+		if !bytes.Equal(got, raw) || cap(got) != cap(getBuffer(len(raw))) {
+			t.Fatalf("%s: unexpected body of len %d cap %d", name, len(got), cap(got))
+		}
+		putBuffer(got)
+
+		// a wrong raw length fails
+		for _, rawLen := range []int{len(raw) - 1, len(raw) + 1} {
+			if _, err := c.(pooledCompressor).decompressPooled(data, rawLen); err == nil {
+				t.Fatalf("%s: raw length %d: expect error", name, rawLen)
+			}
+		}
+	}
+}
+
+func TestCompressionLegacyHeader(t *testing.T) {
+	var buf bytes.Buffer
+	args := &msg.EchoRequest{Msg: strings.Repeat("abc", 100)}
+
+	// snappy is written with the legacy compressed length, old peers can read it
+	c, _ := getCompressor(CompressionSnappy)
+	if err := writeRequest(&buf, DefaultOptions(), newCompressStats(), c, &wire.RequestHeader{Id: 1, Method: "EchoService.Echo"}, args); err != nil {
+		t.Fatal(err)
+	}
+	var header wire.RequestHeader
+	if err := readRequestHeader(&buf, DefaultOptions(), &header); err != nil {
+		t.Fatal(err)
+	}
+	if header.SnappyCompressedRequestLen == 0 {
+		t.Fatalf("expected snappy_compressed_request_len != 0")
+	}
+	body, err := recvFrame(&buf, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := snappy.Decode(nil, body); err != nil {
+		t.Fatalf("snappy.Decode: %v", err)
+	}
+
+	// old peers do not send the compression id
+	header = wire.RequestHeader{
+		Id:                         2,
+		RawRequestLen:              header.RawRequestLen,
+		SnappyCompressedRequestLen: uint32(len(body)),
+	}
+	buf.Reset()
+	sendFrame(&buf, body)
+
+	var reply msg.EchoRequest
+	if err := readRequestBody(&buf, DefaultOptions(), &header, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Msg != args.Msg {
+		t.Fatalf("expected = %q, got = %q", args.Msg, reply.Msg)
+	}
+}
+
+type testEcho int
+
+func (t *testEcho) Echo(args *msg.EchoRequest, reply *msg.EchoResponse) error {
+	reply.Msg = args.Msg
+	return nil
+}
+
+func TestCompressionServerReply(t *testing.T) {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("EchoService", new(testEcho)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range testCompressorNames {
+		cliConn, srvConn := net.Pipe()
+		go srv.ServeCodec(NewServerCodec(srvConn))
+
+		codec := NewClientCodecWithOptions(cliConn, &Options{Compression: name, Handshake: true}).(*clientCodec)
+		args := &msg.EchoRequest{Msg: strings.Repeat(name, 100)}
+		if err := codec.WriteRequest(&rpc.Request{ServiceMethod: "EchoService.Echo", Seq: 1}, args); err != nil {
+			t.Fatalf("%s: WriteRequest: %v", name, err)
+		}
+		var resp rpc.Response
+		if err := codec.ReadResponseHeader(&resp); err != nil {
+			t.Fatalf("%s: ReadResponseHeader: %v", name, err)
+		}
+		c, _ := getCompressor(name)
+		if got := codec.resp.header.Compression; got != c.ID() {
+			t.Fatalf("%s: expected compression = %d, got = %d", name, c.ID(), got)
+		}
+		var reply msg.EchoResponse
+		if err := codec.ReadResponseBody(&reply); err != nil {
+			t.Fatalf("%s: ReadResponseBody: %v", name, err)
+		}
+		if reply.Msg != args.Msg {
+			t.Fatalf("%s: expected = %q, got = %q", name, args.Msg, reply.Msg)
+		}
+		codec.Close()
+	}
+}
+
+type testReverseCompressor struct{}
+
+func (testReverseCompressor) ID() uint32 { return 100 }
+func (testReverseCompressor) Compress(src []byte) ([]byte, error) {
+	dst := make([]byte, len(src))
+	for i, b := range src {
+		dst[len(src)-1-i] = b
+	}
+	return dst, nil
+}
+func (c testReverseCompressor) Decompress(src []byte, rawLen int) ([]byte, error) {
+	return c.Compress(src)
+}
+
+func TestRegisterCompressor(t *testing.T) {
+	RegisterCompressor("reverse", testReverseCompressor{})
+
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("EchoService", new(testEcho)); err != nil {
+		t.Fatal(err)
+	}
+	cliConn, srvConn := net.Pipe()
+	go srv.ServeCodec(NewServerCodec(srvConn))
+
+	client := NewClientWithOptions(cliConn, &Options{Compression: "reverse", Handshake: true})
+	defer client.Close()
+
+	var reply msg.EchoResponse
+	if err := client.Call("EchoService.Echo", &msg.EchoRequest{Msg: "abc"}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Msg != "abc" {
+		t.Fatalf("expected = %q, got = %q", "abc", reply.Msg)
+	}
+}
+
+func TestCompressionMinLen(t *testing.T) {
+	opts := DefaultOptions()
+	stats := newCompressStats()
+	c, _ := getCompressor(CompressionSnappy)
+
+	for _, tt := range []struct {
+		msg        string
+		compressed bool
+	}{
+		{"abc", false},
+		{strings.Repeat("abc", 100), true},
+	} {
+		var buf bytes.Buffer
+		if err := writeRequest(&buf, opts, stats, c, &wire.RequestHeader{Id: 1, Method: "EchoService.Echo"}, &msg.EchoRequest{Msg: tt.msg}); err != nil {
+			t.Fatal(err)
+		}
+		var header wire.RequestHeader
+		if err := readRequestHeader(&buf, opts, &header); err != nil {
+			t.Fatal(err)
+		}
+		if got := header.SnappyCompressedRequestLen != 0; got != tt.compressed {
+			t.Fatalf("len(msg) = %d: expected compressed = %v, got = %v", len(tt.msg), tt.compressed, got)
+		}
+		if header.Compression != c.ID() {
+			t.Fatalf("expected compression = %d, got = %d", c.ID(), header.Compression)
+		}
+	}
+}
+
+func TestAdaptiveCompression(t *testing.T) {
+	opts := &Options{AdaptiveCompression: true}
+	stats := newCompressStats()
+	c, _ := getCompressor(CompressionGzip)
+
+	random := make([]byte, 4096)
+	rand.New(rand.NewSource(1)).Read(random)
+	text := bytes.Repeat([]byte("Hello, 世界."), 1024)
+
+	// the first body is a probe, the next ones are not compressed
+	var compressCalls int
+	for i := 0; i < adaptiveProbeInterval+2; i++ {
+		if stats.shouldCompress("Random") {
+			compressCalls++
+			stats.update("Random", len(random), len(random)+16)
+		}
+	}
+	if compressCalls != 2 {
+		t.Fatalf("expected 2 probes, got = %d", compressCalls)
+	}
+
+	// another method is not affected
+	_, compressed, err := compressBody(opts, stats, c, "Text", text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !compressed {
+		t.Fatalf("expected compressed body for method Text")
+	}
+	_, compressed, err = compressBody(opts, stats, c, "Random", random)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if compressed {
+		t.Fatalf("expected uncompressed body for method Random")
+	}
+}
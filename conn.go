@@ -5,62 +5,161 @@
 package protorpc
 
 import (
+	"bufio"
 	"encoding/binary"
 	"errors"
-	"fmt"
 	"io"
 	"net"
+
+	"github.com/golang/protobuf/proto"
 )
 
-func sendFrame(w io.Writer, data []byte) (err error) {
+// WriteFrame writes data to w as a frame: the length of data as a
+// uvarint, followed by data.
+func WriteFrame(w io.Writer, data []byte) error {
 	// Allocate enough space for the biggest uvarint
 	var size [binary.MaxVarintLen64]byte
 
-	if data == nil || len(data) == 0 {
-		n := binary.PutUvarint(size[:], uint64(0))
-		if err = write(w, size[:n], false); err != nil {
-			return
-		}
-		return
-	}
-
 	// Write the size and data
 	n := binary.PutUvarint(size[:], uint64(len(data)))
-	if err = write(w, size[:n], false); err != nil {
-		return
+	if err := write(w, size[:n], false); err != nil {
+		return err
 	}
-	if err = write(w, data, false); err != nil {
-		return
+	if len(data) == 0 {
+		return nil
 	}
-	return
+	return write(w, data, false)
 }
 
-func recvFrame(r io.Reader, maxSize int) (data []byte, err error) {
-	size, err := readUvarint(r)
+// ReadFrame reads a frame written by WriteFrame from r. The data is read
+// into buf if it is large enough, and into a new slice otherwise. A frame
+// longer than maxSize is a *LimitError of ErrFrameTooLarge, unless
+// maxSize is 0.
+//
+// If r is an io.ByteReader, like a bufio.Reader, the length of the frame
+// is decoded without allocation.
+func ReadFrame(r io.Reader, buf []byte, maxSize int) ([]byte, error) {
+	size, err := readFrameLen(r, maxSize, ErrFrameTooLarge)
 	if err != nil {
 		return nil, err
 	}
-	if maxSize > 0 {
-		if int(size) > maxSize {
-			return nil, fmt.Errorf("protorpc: varint overflows maxSize(%d)", maxSize)
-		}
+	if cap(buf) < size {
+		buf = make([]byte, size)
+	}
+	buf = buf[:size]
+	if err := read(r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// frameWriter writes a header frame and a body frame together: on a
+// net.Conn they are sent by one vectored write, without copy.
+type frameWriter struct {
+	lens [2 * binary.MaxVarintLen64]byte
+	bufs [4][]byte
+	vec  net.Buffers
+}
+
+func (fw *frameWriter) write(w io.Writer, header, body []byte) error {
+	n := binary.PutUvarint(fw.lens[:], uint64(len(header)))
+	m := binary.PutUvarint(fw.lens[n:], uint64(len(body)))
+	fw.bufs = [4][]byte{fw.lens[:n], header, fw.lens[n : n+m], body}
+	fw.vec = fw.bufs[:]
+	_, err := fw.vec.WriteTo(w)
+	fw.bufs = [4][]byte{} // do not keep the body
+	return err
+}
+
+func sendFrame(w io.Writer, data []byte) error {
+	return WriteFrame(w, data)
+}
+
+func recvFrame(r io.Reader, maxSize int) ([]byte, error) {
+	return ReadFrame(r, nil, maxSize)
+}
+
+// recvPreallocLen is the buffer allocated for a body before its data is
+// received. The buffer of a larger body grows as the data is received,
+// so that a peer can not make the codec allocate more than it sends.
+const recvPreallocLen = 64 << 10
+
+// recvBody is like recvFrame, but receives the frame into a pooled buffer.
+func recvBody(r io.Reader, maxSize int) ([]byte, error) {
+	size, err := readFrameLen(r, maxSize, ErrBodyTooLarge)
+	if err != nil {
+		return nil, err
 	}
-	if size != 0 {
-		data = make([]byte, size)
-		if err = read(r, data); err != nil {
+	buf := getBuffer(minInt(size, recvPreallocLen))[:0]
+	for len(buf) < size {
+		buf = growBuffer(buf, minInt(size-len(buf), cap(buf)))
+		n := minInt(size, cap(buf))
+		if err := read(r, buf[len(buf):n]); err != nil {
+			putBuffer(buf)
 			return nil, err
 		}
+		buf = buf[:n]
+	}
+	return buf, nil
+}
+
+// recvMessage receives a header frame and unmarshals it into m. A frame
+// which fits in the buffer of a bufio.Reader is unmarshaled from the
+// buffer, without copy.
+func recvMessage(r io.Reader, maxSize int, m proto.Message) error {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		data, err := recvFrame(r, maxSize)
+		if err != nil {
+			return err
+		}
+		return proto.Unmarshal(data, m)
+	}
+
+	size, err := readFrameLen(br, maxSize, ErrHeaderTooLarge)
+	if err != nil {
+		return err
+	}
+	if size > br.Size() {
+		data := make([]byte, size)
+		if err := read(br, data); err != nil {
+			return err
+		}
+		return proto.Unmarshal(data, m)
+	}
+	data, err := br.Peek(size)
+	if err != nil {
+		return err
+	}
+	err = proto.Unmarshal(data, m) // copies the bytes it keeps
+	br.Discard(size)
+	return err
+}
+
+// readFrameLen reads the length of a frame, a frame longer than maxSize
+// is a LimitError of tooLarge.
+func readFrameLen(r io.Reader, maxSize int, tooLarge error) (int, error) {
+	size, err := readUvarint(r)
+	if err != nil {
+		return 0, err
 	}
-	return data, nil
+	if (maxSize > 0 && size > uint64(maxSize)) || int(size) < 0 || uint64(int(size)) != size {
+		return 0, &LimitError{Err: tooLarge, Size: int64(size), Limit: int64(maxSize)}
+	}
+	return int(size), nil
 }
 
 // ReadUvarint reads an encoded unsigned integer from r and returns it as a uint64.
 func readUvarint(r io.Reader) (uint64, error) {
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = &byteReader{r: r}
+	}
+
 	var x uint64
 	var s uint
 	for i := 0; ; i++ {
-		var b byte
-		b, err := readByte(r)
+		b, err := br.ReadByte()
 		if err != nil {
 			return 0, err
 		}
@@ -75,6 +174,14 @@ func readUvarint(r io.Reader) (uint64, error) {
 	}
 }
 
+// flush writes any buffered data to the underlying connection.
+func flush(w io.Writer) error {
+	if bw, ok := w.(*bufio.Writer); ok {
+		return bw.Flush()
+	}
+	return nil
+}
+
 func write(w io.Writer, data []byte, onePacket bool) error {
 	if onePacket {
 		if _, err := w.Write(data); err != nil {
@@ -107,11 +214,15 @@ func read(r io.Reader, data []byte) error {
 	return nil
 }
 
-func readByte(r io.Reader) (c byte, err error) {
-	data := make([]byte, 1)
-	if err = read(r, data); err != nil {
+// byteReader reads the bytes of a reader which is not an io.ByteReader.
+type byteReader struct {
+	r io.Reader
+	b [1]byte
+}
+
+func (r *byteReader) ReadByte() (byte, error) {
+	if err := read(r.r, r.b[:]); err != nil {
 		return 0, err
 	}
-	c = data[0]
-	return
+	return r.b[0], nil
 }
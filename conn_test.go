@@ -0,0 +1,135 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"testing"
+
+	wire "github.com/chai2010/protorpc/wire.pb"
+)
+
+// onlyReader hides the io.ByteReader of a reader.
+type onlyReader struct {
+	r io.Reader
+}
+
+func (r onlyReader) Read(p []byte) (int, error) {
+	return r.r.Read(p)
+}
+
+func TestReadFrame(t *testing.T) {
+	var buf bytes.Buffer
+	for _, s := range []string{"hello", "", "world!"} {
+		if err := WriteFrame(&buf, []byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data := buf.Bytes()
+
+	for _, r := range []io.Reader{bytes.NewReader(data), onlyReader{bytes.NewReader(data)}} {
+		frame := make([]byte, 0, 16)
+		for _, s := range []string{"hello", "", "world!"} {
+			got, err := ReadFrame(r, frame, 0)
+			if err != nil || string(got) != s {
+				t.Fatalf("ReadFrame(%T): %v %q, expect %q", r, err, got, s)
+			}
+			if len(got) != 0 && &got[0] != &frame[:1][0] {
+				t.Fatalf("ReadFrame(%T): the buffer is not reused", r)
+			}
+		}
+		if _, err := ReadFrame(r, frame, 0); err != io.EOF {
+			t.Fatalf("ReadFrame(%T): expect io.EOF, got %v", r, err)
+		}
+	}
+
+	if _, err := ReadFrame(bytes.NewReader(data), nil, 4); err == nil {
+		t.Fatalf("expect a frame larger than maxSize to fail")
+	}
+}
+
+func TestFrameWriter(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var fw frameWriter
+		fw.write(conn, []byte("header"), bytes.Repeat([]byte("b"), 1000))
+		fw.write(conn, nil, []byte("body"))
+	}()
+
+	conn, err := net.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for _, s := range []string{"header", string(bytes.Repeat([]byte("b"), 1000)), "", "body"} {
+		got, err := ReadFrame(r, nil, 0)
+		if err != nil || string(got) != s {
+			t.Fatalf("ReadFrame: %v, got %d bytes, expect %d", err, len(got), len(s))
+		}
+	}
+}
+
+func TestRecvMessage(t *testing.T) {
+	header := &wire.RequestHeader{Id: 7, Method: "EchoService.Echo"}
+	var buf bytes.Buffer
+	for i := 0; i < 2; i++ {
+		if err := writeRequest(&buf, DefaultOptions(), nil, noneCompressor{}, header, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the header is unmarshaled from the buffer of the reader, its
+	// fields must not alias it
+	r := bufio.NewReader(&buf)
+	var got wire.RequestHeader
+	if err := readRequestHeader(r, DefaultOptions(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recvRequestBody(r, DefaultOptions(), &got); err != nil {
+		t.Fatal(err)
+	}
+	var next wire.RequestHeader
+	if err := readRequestHeader(r, DefaultOptions(), &next); err != nil {
+		t.Fatal(err)
+	}
+	if got.Id != 7 || got.Method != "EchoService.Echo" || next.Method != got.Method {
+		t.Fatalf("unexpected headers %v, %v", &got, &next)
+	}
+}
+
+func BenchmarkReadFrame(b *testing.B) {
+	var buf bytes.Buffer
+	for i := 0; i < 1000; i++ {
+		WriteFrame(&buf, bytes.Repeat([]byte("x"), 100))
+	}
+	data := buf.Bytes()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	var frame []byte
+	r := bufio.NewReader(bytes.NewReader(data))
+	for i := 0; i < b.N; i++ {
+		if i%1000 == 0 {
+			r.Reset(bytes.NewReader(data))
+		}
+		var err error
+		if frame, err = ReadFrame(r, frame, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
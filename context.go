@@ -0,0 +1,99 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// serverCall is the state of a request shared by the server codec and
+// the handler.
+type serverCall struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mutex   sync.Mutex // protects trailer
+	trailer Metadata
+}
+
+type serverCallKey struct{}
+
+// serverCalls maps the args of the requests being served to their state.
+// net/rpc allocates new args for each request.
+var serverCalls = struct {
+	sync.Mutex
+	m map[interface{}]*serverCall
+}{
+	m: make(map[interface{}]*serverCall),
+}
+
+func newServerCall(parent context.Context, args interface{}, md Metadata, deadline time.Time) *serverCall {
+	call := &serverCall{}
+	ctx := context.WithValue(parent, incomingMetadataKey{}, md)
+	ctx = context.WithValue(ctx, serverCallKey{}, call)
+	if deadline.IsZero() {
+		call.ctx, call.cancel = context.WithCancel(ctx)
+	} else {
+		call.ctx, call.cancel = context.WithDeadline(ctx, deadline)
+	}
+
+	serverCalls.Lock()
+	serverCalls.m[args] = call
+	serverCalls.Unlock()
+	return call
+}
+
+// done removes the call of args and cancels its context.
+func (call *serverCall) done(args interface{}) {
+	serverCalls.Lock()
+	delete(serverCalls.m, args)
+	serverCalls.Unlock()
+	call.cancel()
+}
+
+// RequestContext returns the context of the request being served whose
+// args are args. The handler passes its args:
+//
+//	func (t *Echo) Echo(args *EchoRequest, reply *EchoResponse) error {
+//		ctx := protorpc.RequestContext(args)
+//		md, _ := protorpc.MetadataFromIncomingContext(ctx)
+//		...
+//	}
+//
+// The context has the deadline and the trace context sent by the client,
+// and its Peer, and is canceled when the response is sent. RequestContext
+// returns context.Background() if args are not the args of a request read
+// by a Protobuf-RPC server codec.
+func RequestContext(args interface{}) context.Context {
+	serverCalls.Lock()
+	call, ok := serverCalls.m[args]
+	serverCalls.Unlock()
+	if !ok {
+		return context.Background()
+	}
+	return call.ctx
+}
+
+// SetTrailer sets the trailer sent with the response of the request
+// whose context is ctx, see RequestContext.
+func SetTrailer(ctx context.Context, md Metadata) error {
+	call, ok := ctx.Value(serverCallKey{}).(*serverCall)
+	if !ok {
+		return errors.New("protorpc.SetTrailer: not a request context")
+	}
+	call.mutex.Lock()
+	call.trailer = md.Copy()
+	call.mutex.Unlock()
+	return nil
+}
+
+func (call *serverCall) getTrailer() Metadata {
+	call.mutex.Lock()
+	defer call.mutex.Unlock()
+	return call.trailer
+}
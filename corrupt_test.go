@@ -0,0 +1,128 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/rpc"
+	"strings"
+	"testing"
+
+	msg "github.com/chai2010/protorpc/examples/message.pb"
+	wire "github.com/chai2010/protorpc/wire.pb"
+	"github.com/golang/protobuf/proto"
+)
+
+// corruptServer answers the first request on conn with the response
+// header and body changed by corrupt.
+func corruptServer(t *testing.T, conn net.Conn, corrupt func(header *wire.ResponseHeader, body []byte) []byte) {
+	defer conn.Close()
+
+	var reqHeader wire.RequestHeader
+	if err := readRequestHeader(conn, DefaultOptions(), &reqHeader); err != nil {
+		t.Error(err)
+		return
+	}
+	var args msg.EchoRequest
+	if err := readRequestBody(conn, DefaultOptions(), &reqHeader, &args); err != nil {
+		t.Error(err)
+		return
+	}
+
+	header := &wire.ResponseHeader{Id: reqHeader.Id, ChecksumType: wire.ChecksumType_CHECKSUM_CRC32_IEEE}
+	c, _ := getCompressor(CompressionSnappy)
+	body, err := encodeResponse(DefaultOptions(), nil, c, "", header, &msg.EchoResponse{Msg: args.Msg})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	body = corrupt(header, body)
+	pbHeader, _ := proto.Marshal(header)
+	var fw frameWriter
+	fw.write(conn, pbHeader, body)
+
+	// the client closes the poisoned connection
+	if _, err := io.Copy(ioutil.Discard, conn); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCorruptResponse(t *testing.T) {
+	args := &msg.EchoRequest{Msg: strings.Repeat("hello", 100)}
+	for _, tt := range []struct {
+		name    string
+		corrupt func(header *wire.ResponseHeader, body []byte) []byte
+		err     error
+	}{
+		{"flipped bit", func(header *wire.ResponseHeader, body []byte) []byte {
+			body[len(body)/2] ^= 0x10
+			return body
+		}, ErrChecksumMismatch},
+		{"bad snappy", func(header *wire.ResponseHeader, body []byte) []byte {
+			body = []byte("not snappy data")
+			header.SnappyCompressedResponseLen = uint32(len(body))
+			header.Checksum, _ = checksum(uint32(header.ChecksumType), body)
+			return body
+		}, ErrBadCompression},
+		{"wrong raw length", func(header *wire.ResponseHeader, body []byte) []byte {
+			header.RawResponseLen--
+			return body
+		}, ErrBadCompression},
+		{"unknown compressor", func(header *wire.ResponseHeader, body []byte) []byte {
+			header.Compression = 100
+			return body
+		}, ErrBadCompression},
+	} {
+		cliConn, srvConn := net.Pipe()
+		go corruptServer(t, srvConn, tt.corrupt)
+		client := NewClient(cliConn)
+
+		var reply msg.EchoResponse
+		if err := client.Call("EchoService.Echo", args, &reply); !errors.Is(err, tt.err) {
+			t.Fatalf("%s: expect %v, got %v", tt.name, tt.err, err)
+		}
+		if err := client.Call("EchoService.Echo", args, &reply); err != rpc.ErrShutdown {
+			t.Fatalf("%s: expect the connection to be poisoned, got %v", tt.name, err)
+		}
+		client.Close()
+	}
+}
+
+func TestCorruptRequest(t *testing.T) {
+	cliConn, srvConn := net.Pipe()
+	defer cliConn.Close()
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("EchoService", new(testMux)); err != nil {
+		t.Fatal(err)
+	}
+	go srv.ServeCodec(NewServerCodec(srvConn))
+
+	go func() {
+		header := &wire.RequestHeader{Id: 1, Method: "EchoService.Echo", ChecksumType: wire.ChecksumType_CHECKSUM_CRC32C}
+		body, _ := encodeRequest(DefaultOptions(), nil, noneCompressor{}, header, &msg.EchoRequest{Msg: "hello"})
+		body[0] ^= 0x01
+		pbHeader, _ := proto.Marshal(header)
+		var fw frameWriter
+		fw.write(cliConn, pbHeader, body)
+	}()
+
+	// the call fails with CodeDataLoss, and the server closes the connection
+	var header wire.ResponseHeader
+	if err := readResponseHeader(cliConn, DefaultOptions(), &header); err != nil {
+		t.Fatal(err)
+	}
+	if header.Id != 1 || header.Status == nil || Code(header.Status.Code) != CodeDataLoss || !strings.Contains(header.Error, ErrChecksumMismatch.Error()) {
+		t.Fatalf("unexpected response %v", &header)
+	}
+	if _, err := recvResponseBody(cliConn, DefaultOptions(), &header); err != nil {
+		t.Fatal(err)
+	}
+	if err := readResponseHeader(cliConn, DefaultOptions(), &header); err != io.EOF {
+		t.Fatalf("expect the connection to be closed, got %v", err)
+	}
+}
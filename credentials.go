@@ -0,0 +1,124 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credentials attach credentials, like a token, to the requests of a
+// client, see Options.Credentials and the CallCredentials call option.
+type Credentials interface {
+	// Metadata returns the metadata sent with a request of
+	// serviceMethod made with ctx, it replaces the values of the same
+	// keys in the metadata of ctx. An error fails the call, without
+	// sending it, with CodeUnauthenticated unless it is a status error.
+	//
+	// Metadata is called when the request is written, while the other
+	// requests of the client wait: a slow refresh should be cached.
+	Metadata(ctx context.Context, serviceMethod string) (Metadata, error)
+}
+
+// authorizationKey is the metadata key of the bearer tokens.
+const authorizationKey = "authorization"
+
+type staticToken Metadata
+
+// StaticToken returns credentials which send token as a bearer token in
+// the "authorization" metadata of each request, see BearerToken.
+func StaticToken(token string) Credentials {
+	return staticToken(NewMetadata(authorizationKey, "Bearer "+token))
+}
+
+func (t staticToken) Metadata(ctx context.Context, serviceMethod string) (Metadata, error) {
+	return Metadata(t), nil
+}
+
+type tokenFunc struct {
+	fetch func(ctx context.Context) (string, time.Time, error)
+
+	mutex  sync.Mutex // protects the fields below, and serializes the fetches
+	md     Metadata
+	expiry time.Time
+}
+
+// TokenFunc returns credentials which send the bearer token returned by
+// fetch, like StaticToken. The token is reused until its expiry: fetch
+// is called again by the first request after it. A zero expiry means
+// fetch is called for each request.
+func TokenFunc(fetch func(ctx context.Context) (token string, expiry time.Time, err error)) Credentials {
+	return &tokenFunc{fetch: fetch}
+}
+
+func (t *tokenFunc) Metadata(ctx context.Context, serviceMethod string) (Metadata, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.md != nil && time.Now().Before(t.expiry) {
+		return t.md, nil
+	}
+	token, expiry, err := t.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t.md, t.expiry = NewMetadata(authorizationKey, "Bearer "+token), expiry
+	return t.md, nil
+}
+
+// BearerToken returns the bearer token of the "authorization" metadata
+// of the request whose context is ctx, sent by StaticToken or TokenFunc.
+func BearerToken(ctx context.Context) (string, bool) {
+	md, _ := MetadataFromIncomingContext(ctx)
+	const prefix = "bearer "
+	v := md.Get(authorizationKey)
+	if len(v) < len(prefix) || !strings.EqualFold(v[:len(prefix)], prefix) {
+		return "", false
+	}
+	return v[len(prefix):], true
+}
+
+// An Authenticator authenticates the requests of a server, see
+// Options.Authenticator.
+type Authenticator interface {
+	// Authenticate returns the principal of a request of serviceMethod,
+	// like the user it was made for. ctx has the metadata and the Peer
+	// of the request.
+	//
+	// An error rejects the request before it is served: the client gets
+	// the status of a status error, CodeUnauthenticated otherwise. The
+	// principal is in the context of the handler, see
+	// PrincipalFromContext.
+	Authenticate(ctx context.Context, serviceMethod string) (principal interface{}, err error)
+}
+
+// AuthenticatorFunc is a function used as an Authenticator.
+type AuthenticatorFunc func(ctx context.Context, serviceMethod string) (interface{}, error)
+
+// Authenticate returns f(ctx, serviceMethod).
+func (f AuthenticatorFunc) Authenticate(ctx context.Context, serviceMethod string) (interface{}, error) {
+	return f(ctx, serviceMethod)
+}
+
+type principalKey struct{}
+
+// PrincipalFromContext returns the principal of the request whose
+// context is ctx, returned by the Authenticator of the server.
+func PrincipalFromContext(ctx context.Context) (interface{}, bool) {
+	p := ctx.Value(principalKey{})
+	return p, p != nil
+}
+
+// unauthenticated returns the status error of a request rejected with
+// err, a status error or an error of credentials.
+func unauthenticated(err error) error {
+	var s *Status
+	if errors.As(err, &s) {
+		return err
+	}
+	return &Status{Code: CodeUnauthenticated, Message: err.Error(), err: err}
+}
@@ -0,0 +1,169 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc_test
+
+import (
+	"context"
+	"errors"
+	"net/rpc"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chai2010/protorpc"
+	msg "github.com/chai2010/protorpc/examples/message.pb"
+)
+
+type AuthService int
+
+// Whoami replies with the principal of the request.
+func (t *AuthService) Whoami(args *msg.EchoRequest, reply *msg.EchoResponse) error {
+	principal, ok := protorpc.PrincipalFromContext(protorpc.RequestContext(args))
+	if !ok {
+		return protorpc.Errorf(protorpc.CodeInternal, "no principal")
+	}
+	reply.Msg = principal.(string)
+	return nil
+}
+
+// testAuthenticator accepts the tokens "alice" and "bob", and denies
+// "mallory".
+type testAuthenticator struct {
+	calls int32
+}
+
+func (a *testAuthenticator) Authenticate(ctx context.Context, serviceMethod string) (interface{}, error) {
+	atomic.AddInt32(&a.calls, 1)
+	if _, ok := protorpc.PeerFromContext(ctx); !ok {
+		return nil, errors.New("no peer")
+	}
+	token, ok := protorpc.BearerToken(ctx)
+	switch {
+	case !ok:
+		return nil, errors.New("missing token")
+	case token == "mallory":
+		return nil, protorpc.Errorf(protorpc.CodePermissionDenied, "%s is denied", token)
+	case token != "alice" && token != "bob":
+		return nil, errors.New("invalid token")
+	}
+	return token, nil
+}
+
+func registerAuthService(srv *rpc.Server) error {
+	return srv.RegisterName("AuthService", new(AuthService))
+}
+
+func callWhoami(ctx context.Context, client *rpc.Client, opts ...protorpc.CallOption) (string, error) {
+	var reply msg.EchoResponse
+	err := protorpc.CallContext(ctx, client, "AuthService.Whoami", &msg.EchoRequest{}, &reply, opts...)
+	return reply.Msg, err
+}
+
+func TestCredentials(t *testing.T) {
+	client := newTestClient(t,
+		&protorpc.Options{Credentials: protorpc.StaticToken("alice")},
+		&protorpc.Options{Authenticator: new(testAuthenticator)},
+		registerAuthService,
+	)
+	ctx := context.Background()
+
+	if got, err := callWhoami(ctx, client); err != nil || got != "alice" {
+		t.Fatalf("expected alice, got %q, %v", got, err)
+	}
+
+	// the credentials replace the metadata of the context
+	mdCtx := protorpc.NewOutgoingContext(ctx, protorpc.NewMetadata("Authorization", "Bearer bob"))
+	if got, err := callWhoami(mdCtx, client); err != nil || got != "alice" {
+		t.Fatalf("expected alice, got %q, %v", got, err)
+	}
+
+	// the call option replaces the credentials of the client
+	if got, err := callWhoami(ctx, client, protorpc.CallCredentials(protorpc.StaticToken("bob"))); err != nil || got != "bob" {
+		t.Fatalf("expected bob, got %q, %v", got, err)
+	}
+
+	// rejected calls fail with Unauthenticated, or the status of the
+	// authenticator
+	_, err := callWhoami(ctx, client, protorpc.CallCredentials(protorpc.StaticToken("eve")))
+	if s := protorpc.StatusFromError(err); s.Code != protorpc.CodeUnauthenticated || s.Message != "invalid token" {
+		t.Fatalf("expected Unauthenticated, got %v", err)
+	}
+	_, err = callWhoami(ctx, client, protorpc.CallCredentials(protorpc.StaticToken("mallory")))
+	if s := protorpc.StatusFromError(err); s.Code != protorpc.CodePermissionDenied {
+		t.Fatalf("expected PermissionDenied, got %v", err)
+	}
+
+	// the connection serves the next calls
+	if got, err := callWhoami(ctx, client); err != nil || got != "alice" {
+		t.Fatalf("expected alice, got %q, %v", got, err)
+	}
+}
+
+func TestAuthenticatorNoCredentials(t *testing.T) {
+	client := newTestClient(t, nil, &protorpc.Options{Authenticator: new(testAuthenticator)}, registerAuthService)
+
+	var reply msg.EchoResponse
+	err := client.Call("AuthService.Whoami", &msg.EchoRequest{}, &reply)
+	if s := protorpc.StatusFromError(err); s.Code != protorpc.CodeUnauthenticated || s.Message != "missing token" {
+		t.Fatalf("expected Unauthenticated, got %v", err)
+	}
+
+	// without authenticator, the requests have no principal
+	plain := newTestClient(t, nil, nil, registerAuthService)
+	err = plain.Call("AuthService.Whoami", &msg.EchoRequest{}, &reply)
+	if s := protorpc.StatusFromError(err); s.Code != protorpc.CodeInternal {
+		t.Fatalf("expected no principal, got %v", err)
+	}
+}
+
+func TestTokenFunc(t *testing.T) {
+	auth := new(testAuthenticator)
+	client := newTestClient(t, nil, &protorpc.Options{Authenticator: auth}, registerAuthService)
+	ctx := context.Background()
+
+	var fetches int32
+	creds := protorpc.CallCredentials(protorpc.TokenFunc(func(ctx context.Context) (string, time.Time, error) {
+		atomic.AddInt32(&fetches, 1)
+		return "alice", time.Now().Add(time.Hour), nil
+	}))
+
+	// the token is reused until it expires
+	for i := 0; i < 3; i++ {
+		if got, err := callWhoami(ctx, client, creds); err != nil || got != "alice" {
+			t.Fatalf("expected alice, got %q, %v", got, err)
+		}
+	}
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Fatalf("expected 1 fetch, got %d", n)
+	}
+
+	// a zero expiry fetches a token for each call
+	atomic.StoreInt32(&fetches, 0)
+	zero := protorpc.CallCredentials(protorpc.TokenFunc(func(ctx context.Context) (string, time.Time, error) {
+		atomic.AddInt32(&fetches, 1)
+		return "bob", time.Time{}, nil
+	}))
+	for i := 0; i < 2; i++ {
+		if got, err := callWhoami(ctx, client, zero); err != nil || got != "bob" {
+			t.Fatalf("expected bob, got %q, %v", got, err)
+		}
+	}
+	if n := atomic.LoadInt32(&fetches); n != 2 {
+		t.Fatalf("expected 2 fetches, got %d", n)
+	}
+
+	// a failed fetch fails the call without sending it
+	calls := atomic.LoadInt32(&auth.calls)
+	failing := protorpc.TokenFunc(func(ctx context.Context) (string, time.Time, error) {
+		return "", time.Time{}, errors.New("token server down")
+	})
+	_, err := callWhoami(ctx, client, protorpc.CallCredentials(failing))
+	if s := protorpc.StatusFromError(err); s.Code != protorpc.CodeUnauthenticated || s.Message != "token server down" {
+		t.Fatalf("expected Unauthenticated, got %v", err)
+	}
+	if n := atomic.LoadInt32(&auth.calls); n != calls {
+		t.Fatalf("expected the call not to be sent")
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"context"
+	"errors"
+	"net/rpc"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	msg "github.com/chai2010/protorpc/examples/message.pb"
+	wire "github.com/chai2010/protorpc/wire.pb"
+	"github.com/golang/protobuf/proto"
+)
+
+type testSleep struct {
+	calls int32
+}
+
+// Sleep waits until its context is done, and replies with the time left
+// when it was called.
+func (t *testSleep) Sleep(args *msg.EchoRequest, reply *msg.EchoResponse) error {
+	atomic.AddInt32(&t.calls, 1)
+	ctx := RequestContext(args)
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return errors.New("no deadline")
+	}
+	reply.Msg = time.Until(deadline).String()
+	<-ctx.Done()
+	return nil
+}
+
+func (t *testSleep) register(srv *rpc.Server) error {
+	return srv.RegisterName("SleepService", t)
+}
+
+func TestDeadline(t *testing.T) {
+	client := newTestClient(t, nil, nil, new(testSleep).register)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	var reply msg.EchoResponse
+	err := CallContext(ctx, client, "SleepService.Sleep", &msg.EchoRequest{}, &reply)
+	if s := StatusFromError(err); s == nil || s.Code != CodeDeadlineExceeded {
+		t.Fatalf("expect DeadlineExceeded, got %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("CallContext returned after %v", d)
+	}
+
+	// done contexts fail without sending the request
+	err = CallContext(ctx, client, "SleepService.Sleep", &msg.EchoRequest{}, &reply)
+	if s := StatusFromError(err); s == nil || s.Code != CodeDeadlineExceeded {
+		t.Fatalf("expect DeadlineExceeded, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestDeadlineHandlerContext(t *testing.T) {
+	codec := NewClientCodec(dialTestServer(t, nil, new(testSleep).register))
+	defer codec.Close()
+
+	// the handler sees the deadline, and the stale result is replaced
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	call := newClientCall(ctx, &msg.EchoRequest{})
+	if err := codec.WriteRequest(&rpc.Request{ServiceMethod: "SleepService.Sleep", Seq: 1}, call); err != nil {
+		t.Fatal(err)
+	}
+	var resp rpc.Response
+	if err := codec.ReadResponseHeader(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if s := StatusFromError(rpc.ServerError(resp.Error)); s.Code != CodeDeadlineExceeded {
+		t.Fatalf("expect DeadlineExceeded, got %q", resp.Error)
+	}
+	if err := codec.ReadResponseBody(nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeadlineStaleRequest(t *testing.T) {
+	sleep := new(testSleep)
+	conn := dialTestServer(t, nil, sleep.register)
+	defer conn.Close()
+
+	// the body arrives after the deadline of the request
+	header := &wire.RequestHeader{
+		Id:      1,
+		Method:  "SleepService.Sleep",
+		Timeout: 1000, // 1ms
+	}
+	pbHeader, err := proto.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sendFrame(conn, pbHeader); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := sendFrame(conn, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var respHeader wire.ResponseHeader
+	if err := readResponseHeader(conn, DefaultOptions(), &respHeader); err != nil {
+		t.Fatal(err)
+	}
+	if respHeader.Status == nil || Code(respHeader.Status.Code) != CodeDeadlineExceeded {
+		t.Fatalf("expect DeadlineExceeded, got %v", respHeader.Status)
+	}
+	if n := atomic.LoadInt32(&sleep.calls); n != 0 {
+		t.Fatalf("stale request served %d times", n)
+	}
+}
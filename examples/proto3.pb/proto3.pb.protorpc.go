@@ -1,25 +1,28 @@
 // Code generated by protoc-gen-protorpc. DO NOT EDIT.
 //
+// plugin: https://github.com/chai2010/protorpc/tree/master/protoc-gen-plugin
 // plugin: https://github.com/chai2010/protorpc/tree/master/protoc-gen-protorpc
-// plugin: https://github.com/chai2010/protorpc/tree/master/protoc-plugin-common
 //
 // source: proto3.proto
 
 package proto3_proto
 
 import (
-	"fmt"
-	"io"
-	"log"
-	"net"
-	"net/rpc"
-	"time"
-
-	"github.com/chai2010/protorpc"
-	"github.com/golang/protobuf/proto"
+	context "context"
+	tls "crypto/tls"
+	fmt "fmt"
+	protorpc "github.com/chai2010/protorpc"
+	proto "github.com/golang/protobuf/proto"
+	io "io"
+	log "log"
+	net "net"
+	rpc "net/rpc"
+	time "time"
 )
 
 var (
+	_ = context.Background
+	_ = tls.Client
 	_ = fmt.Sprint
 	_ = io.Reader(nil)
 	_ = log.Print
@@ -73,6 +76,12 @@ func NewEchoServiceServer(x EchoService) *rpc.Server {
 // ListenAndServeEchoService listen announces on the local network address laddr
 // and serves the given EchoService implementation.
 func ListenAndServeEchoService(network, addr string, x EchoService) error {
+	return ListenAndServeEchoServiceWithOptions(network, addr, x, nil)
+}
+
+// ListenAndServeEchoServiceWithOptions is like ListenAndServeEchoService
+// but uses the given codec options for each connection.
+func ListenAndServeEchoServiceWithOptions(network, addr string, x EchoService, opts *protorpc.Options) error {
 	lis, err := net.Listen(network, addr)
 	if err != nil {
 		return err
@@ -89,17 +98,33 @@ func ListenAndServeEchoService(network, addr string, x EchoService) error {
 		if err != nil {
 			log.Fatalf("lis.Accept(): %v\n", err)
 		}
-		go srv.ServeCodec(protorpc.NewServerCodec(conn))
+		go srv.ServeCodec(protorpc.NewServerCodecWithOptions(conn, opts))
 	}
 }
 
+// ListenAndServeEchoServiceTLS listen announces on the local network address laddr
+// over TLS and serves the given EchoService implementation, see protorpc.ListenAndServeTLS.
+func ListenAndServeEchoServiceTLS(network, addr string, x EchoService, config *tls.Config) error {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("EchoService", x); err != nil {
+		return err
+	}
+	return protorpc.ListenAndServeTLS(network, addr, srv, config, nil)
+}
+
 // ServeEchoService serves the given EchoService implementation.
 func ServeEchoService(conn io.ReadWriteCloser, x EchoService) {
+	ServeEchoServiceWithOptions(conn, x, nil)
+}
+
+// ServeEchoServiceWithOptions serves the given EchoService implementation
+// with the given codec options.
+func ServeEchoServiceWithOptions(conn io.ReadWriteCloser, x EchoService, opts *protorpc.Options) {
 	srv := rpc.NewServer()
 	if err := srv.RegisterName("EchoService", x); err != nil {
 		log.Fatal(err)
 	}
-	srv.ServeCodec(protorpc.NewServerCodec(conn))
+	srv.ServeCodec(protorpc.NewServerCodecWithOptions(conn, opts))
 }
 
 type EchoServiceClient struct {
@@ -113,6 +138,13 @@ func NewEchoServiceClient(conn io.ReadWriteCloser) *EchoServiceClient {
 	return &EchoServiceClient{c}
 }
 
+// NewEchoServiceClientWithOptions is like NewEchoServiceClient
+// but uses the given codec options.
+func NewEchoServiceClientWithOptions(conn io.ReadWriteCloser, opts *protorpc.Options) *EchoServiceClient {
+	c := rpc.NewClientWithCodec(protorpc.NewClientCodecWithOptions(conn, opts))
+	return &EchoServiceClient{c}
+}
+
 func (c *EchoServiceClient) Echo(in *Message) (out *Message, err error) {
 	if in == nil {
 		in = new(Message)
@@ -141,6 +173,36 @@ func (c *EchoServiceClient) Echo(in *Message) (out *Message, err error) {
 	return out, nil
 }
 
+// EchoContext is like Echo but sends the metadata of ctx,
+// and cancels the call when ctx is done.
+func (c *EchoServiceClient) EchoContext(ctx context.Context, in *Message, opts ...protorpc.CallOption) (out *Message, err error) {
+	if in == nil {
+		in = new(Message)
+	}
+
+	type Validator interface {
+		Validate() error
+	}
+	if x, ok := proto.Message(in).(Validator); ok {
+		if err := x.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	out = new(Message)
+	if err = protorpc.CallContext(ctx, c.Client, "EchoService.Echo", in, out, opts...); err != nil {
+		return nil, err
+	}
+
+	if x, ok := proto.Message(out).(Validator); ok {
+		if err := x.Validate(); err != nil {
+			return out, err
+		}
+	}
+
+	return out, nil
+}
+
 func (c *EchoServiceClient) AsyncEcho(in *Message, out *Message, done chan *rpc.Call) *rpc.Call {
 	if in == nil {
 		in = new(Message)
@@ -152,6 +214,20 @@ func (c *EchoServiceClient) AsyncEcho(in *Message, out *Message, done chan *rpc.
 	)
 }
 
+// AsyncEchoContext is like AsyncEcho but sends the metadata of ctx,
+// and cancels the call when ctx is done.
+func (c *EchoServiceClient) AsyncEchoContext(ctx context.Context, in *Message, out *Message, done chan *rpc.Call, opts ...protorpc.CallOption) *rpc.Call {
+	if in == nil {
+		in = new(Message)
+	}
+	return protorpc.GoContext(
+		ctx, c.Client,
+		"EchoService.Echo",
+		in, out,
+		done, opts...,
+	)
+}
+
 // DialEchoService connects to an EchoService at the specified network address.
 func DialEchoService(network, addr string) (*EchoServiceClient, error) {
 	c, err := protorpc.Dial(network, addr)
@@ -161,6 +237,16 @@ func DialEchoService(network, addr string) (*EchoServiceClient, error) {
 	return &EchoServiceClient{c}, nil
 }
 
+// DialEchoServiceWithOptions connects to an EchoService at the specified network address,
+// using the given codec options.
+func DialEchoServiceWithOptions(network, addr string, opts *protorpc.Options) (*EchoServiceClient, error) {
+	c, err := protorpc.DialWithOptions(network, addr, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &EchoServiceClient{c}, nil
+}
+
 // DialEchoServiceTimeout connects to an EchoService at the specified network address.
 func DialEchoServiceTimeout(network, addr string, timeout time.Duration) (*EchoServiceClient, error) {
 	c, err := protorpc.DialTimeout(network, addr, timeout)
@@ -169,3 +255,13 @@ func DialEchoServiceTimeout(network, addr string, timeout time.Duration) (*EchoS
 	}
 	return &EchoServiceClient{c}, nil
 }
+
+// DialEchoServiceTLS connects to an EchoService at the specified network address
+// over TLS.
+func DialEchoServiceTLS(network, addr string, config *tls.Config) (*EchoServiceClient, error) {
+	c, err := protorpc.DialTLS(network, addr, config)
+	if err != nil {
+		return nil, err
+	}
+	return &EchoServiceClient{c}, nil
+}
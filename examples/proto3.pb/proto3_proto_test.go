@@ -7,11 +7,11 @@ package proto3_proto
 import (
 	"bytes"
 	"encoding/gob"
-	"log"
-	"os"
+	"net/rpc"
 	"reflect"
 	"testing"
-	"time"
+
+	"github.com/chai2010/protorpc/protorpctest"
 )
 
 type tEchoService struct {
@@ -29,21 +29,15 @@ func (p *tEchoService) Echo(in *Message, out *Message) error {
 	return nil
 }
 
-func TestMain(m *testing.M) {
-	go func() {
-		if err := ListenAndServeEchoService("tcp", "127.0.0.1:3000", new(tEchoService)); err != nil {
-			log.Fatal(err)
-		}
-	}()
-	time.Sleep(time.Second * 3) // wait for start the server
-	os.Exit(m.Run())
-}
-
 func TestEchoService(t *testing.T) {
-	c, err := DialEchoService("tcp", "127.0.0.1:3000")
+	srv := protorpctest.StartServer(t, nil, func(srv *rpc.Server) error {
+		return RegisterEchoService(srv, new(tEchoService))
+	})
+	conn, err := srv.Dial()
 	if err != nil {
 		t.Fatal(err)
 	}
+	c := NewEchoServiceClient(conn)
 	defer c.Close()
 
 	in := Message{
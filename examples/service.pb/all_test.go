@@ -5,25 +5,18 @@
 package service
 
 import (
-	"log"
-	"net"
 	"net/rpc"
 	"testing"
 
 	"github.com/chai2010/protorpc"
+	"github.com/chai2010/protorpc/protorpctest"
 )
 
-func init() {
-	err := listenAndServeArithAndEchoService("tcp", "127.0.0.1:1984")
-	if err != nil {
-		log.Fatalf("listenAndServeArithAndEchoService: %v", err)
-	}
-}
-
 func TestAll(t *testing.T) {
-	conn, err := net.Dial("tcp", "127.0.0.1:1984")
+	srv := protorpctest.StartServer(t, nil, registerArithAndEchoService)
+	conn, err := srv.Dial()
 	if err != nil {
-		t.Fatalf(`net.Dial("tcp", "127.0.0.1:1984"): %v`, err)
+		t.Fatalf(`srv.Dial(): %v`, err)
 	}
 	client := rpc.NewClientWithCodec(protorpc.NewClientCodec(conn))
 	defer client.Close()
@@ -38,29 +31,11 @@ func TestAll(t *testing.T) {
 	testEchoStub(t, echoStub)
 }
 
-func listenAndServeArithAndEchoService(network, addr string) error {
-	clients, err := net.Listen(network, addr)
-	if err != nil {
-		return err
-	}
-	srv := rpc.NewServer()
+func registerArithAndEchoService(srv *rpc.Server) error {
 	if err := RegisterArithService(srv, new(Arith)); err != nil {
 		return err
 	}
-	if err := RegisterEchoService(srv, new(Echo)); err != nil {
-		return err
-	}
-	go func() {
-		for {
-			conn, err := clients.Accept()
-			if err != nil {
-				log.Printf("clients.Accept(): %v\n", err)
-				continue
-			}
-			go srv.ServeCodec(protorpc.NewServerCodec(conn))
-		}
-	}()
-	return nil
+	return RegisterEchoService(srv, new(Echo))
 }
 
 func testArithClient(t *testing.T, client *rpc.Client) {
@@ -8,18 +8,21 @@
 package service
 
 import (
-	"fmt"
-	"io"
-	"log"
-	"net"
-	"net/rpc"
-	"time"
-
-	"github.com/chai2010/protorpc"
-	"github.com/golang/protobuf/proto"
+	context "context"
+	tls "crypto/tls"
+	fmt "fmt"
+	protorpc "github.com/chai2010/protorpc"
+	proto "github.com/golang/protobuf/proto"
+	io "io"
+	log "log"
+	net "net"
+	rpc "net/rpc"
+	time "time"
 )
 
 var (
+	_ = context.Background
+	_ = tls.Client
 	_ = fmt.Sprint
 	_ = io.Reader(nil)
 	_ = log.Print
@@ -76,6 +79,12 @@ func NewArithServiceServer(x ArithService) *rpc.Server {
 // ListenAndServeArithService listen announces on the local network address laddr
 // and serves the given ArithService implementation.
 func ListenAndServeArithService(network, addr string, x ArithService) error {
+	return ListenAndServeArithServiceWithOptions(network, addr, x, nil)
+}
+
+// ListenAndServeArithServiceWithOptions is like ListenAndServeArithService
+// but uses the given codec options for each connection.
+func ListenAndServeArithServiceWithOptions(network, addr string, x ArithService, opts *protorpc.Options) error {
 	lis, err := net.Listen(network, addr)
 	if err != nil {
 		return err
@@ -92,17 +101,33 @@ func ListenAndServeArithService(network, addr string, x ArithService) error {
 		if err != nil {
 			log.Fatalf("lis.Accept(): %v\n", err)
 		}
-		go srv.ServeCodec(protorpc.NewServerCodec(conn))
+		go srv.ServeCodec(protorpc.NewServerCodecWithOptions(conn, opts))
 	}
 }
 
+// ListenAndServeArithServiceTLS listen announces on the local network address laddr
+// over TLS and serves the given ArithService implementation, see protorpc.ListenAndServeTLS.
+func ListenAndServeArithServiceTLS(network, addr string, x ArithService, config *tls.Config) error {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("ArithService", x); err != nil {
+		return err
+	}
+	return protorpc.ListenAndServeTLS(network, addr, srv, config, nil)
+}
+
 // ServeArithService serves the given ArithService implementation.
 func ServeArithService(conn io.ReadWriteCloser, x ArithService) {
+	ServeArithServiceWithOptions(conn, x, nil)
+}
+
+// ServeArithServiceWithOptions serves the given ArithService implementation
+// with the given codec options.
+func ServeArithServiceWithOptions(conn io.ReadWriteCloser, x ArithService, opts *protorpc.Options) {
 	srv := rpc.NewServer()
 	if err := srv.RegisterName("ArithService", x); err != nil {
 		log.Fatal(err)
 	}
-	srv.ServeCodec(protorpc.NewServerCodec(conn))
+	srv.ServeCodec(protorpc.NewServerCodecWithOptions(conn, opts))
 }
 
 type ArithServiceClient struct {
@@ -116,6 +141,13 @@ func NewArithServiceClient(conn io.ReadWriteCloser) *ArithServiceClient {
 	return &ArithServiceClient{c}
 }
 
+// NewArithServiceClientWithOptions is like NewArithServiceClient
+// but uses the given codec options.
+func NewArithServiceClientWithOptions(conn io.ReadWriteCloser, opts *protorpc.Options) *ArithServiceClient {
+	c := rpc.NewClientWithCodec(protorpc.NewClientCodecWithOptions(conn, opts))
+	return &ArithServiceClient{c}
+}
+
 func (c *ArithServiceClient) Add(in *ArithRequest) (out *ArithResponse, err error) {
 	if in == nil {
 		in = new(ArithRequest)
@@ -144,6 +176,36 @@ func (c *ArithServiceClient) Add(in *ArithRequest) (out *ArithResponse, err erro
 	return out, nil
 }
 
+// AddContext is like Add but sends the metadata of ctx,
+// and cancels the call when ctx is done.
+func (c *ArithServiceClient) AddContext(ctx context.Context, in *ArithRequest, opts ...protorpc.CallOption) (out *ArithResponse, err error) {
+	if in == nil {
+		in = new(ArithRequest)
+	}
+
+	type Validator interface {
+		Validate() error
+	}
+	if x, ok := proto.Message(in).(Validator); ok {
+		if err := x.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	out = new(ArithResponse)
+	if err = protorpc.CallContext(ctx, c.Client, "ArithService.Add", in, out, opts...); err != nil {
+		return nil, err
+	}
+
+	if x, ok := proto.Message(out).(Validator); ok {
+		if err := x.Validate(); err != nil {
+			return out, err
+		}
+	}
+
+	return out, nil
+}
+
 func (c *ArithServiceClient) AsyncAdd(in *ArithRequest, out *ArithResponse, done chan *rpc.Call) *rpc.Call {
 	if in == nil {
 		in = new(ArithRequest)
@@ -155,6 +217,20 @@ func (c *ArithServiceClient) AsyncAdd(in *ArithRequest, out *ArithResponse, done
 	)
 }
 
+// AsyncAddContext is like AsyncAdd but sends the metadata of ctx,
+// and cancels the call when ctx is done.
+func (c *ArithServiceClient) AsyncAddContext(ctx context.Context, in *ArithRequest, out *ArithResponse, done chan *rpc.Call, opts ...protorpc.CallOption) *rpc.Call {
+	if in == nil {
+		in = new(ArithRequest)
+	}
+	return protorpc.GoContext(
+		ctx, c.Client,
+		"ArithService.Add",
+		in, out,
+		done, opts...,
+	)
+}
+
 func (c *ArithServiceClient) Mul(in *ArithRequest) (out *ArithResponse, err error) {
 	if in == nil {
 		in = new(ArithRequest)
@@ -183,6 +259,36 @@ func (c *ArithServiceClient) Mul(in *ArithRequest) (out *ArithResponse, err erro
 	return out, nil
 }
 
+// MulContext is like Mul but sends the metadata of ctx,
+// and cancels the call when ctx is done.
+func (c *ArithServiceClient) MulContext(ctx context.Context, in *ArithRequest, opts ...protorpc.CallOption) (out *ArithResponse, err error) {
+	if in == nil {
+		in = new(ArithRequest)
+	}
+
+	type Validator interface {
+		Validate() error
+	}
+	if x, ok := proto.Message(in).(Validator); ok {
+		if err := x.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	out = new(ArithResponse)
+	if err = protorpc.CallContext(ctx, c.Client, "ArithService.Mul", in, out, opts...); err != nil {
+		return nil, err
+	}
+
+	if x, ok := proto.Message(out).(Validator); ok {
+		if err := x.Validate(); err != nil {
+			return out, err
+		}
+	}
+
+	return out, nil
+}
+
 func (c *ArithServiceClient) AsyncMul(in *ArithRequest, out *ArithResponse, done chan *rpc.Call) *rpc.Call {
 	if in == nil {
 		in = new(ArithRequest)
@@ -194,6 +300,20 @@ func (c *ArithServiceClient) AsyncMul(in *ArithRequest, out *ArithResponse, done
 	)
 }
 
+// AsyncMulContext is like AsyncMul but sends the metadata of ctx,
+// and cancels the call when ctx is done.
+func (c *ArithServiceClient) AsyncMulContext(ctx context.Context, in *ArithRequest, out *ArithResponse, done chan *rpc.Call, opts ...protorpc.CallOption) *rpc.Call {
+	if in == nil {
+		in = new(ArithRequest)
+	}
+	return protorpc.GoContext(
+		ctx, c.Client,
+		"ArithService.Mul",
+		in, out,
+		done, opts...,
+	)
+}
+
 func (c *ArithServiceClient) Div(in *ArithRequest) (out *ArithResponse, err error) {
 	if in == nil {
 		in = new(ArithRequest)
@@ -222,6 +342,36 @@ func (c *ArithServiceClient) Div(in *ArithRequest) (out *ArithResponse, err erro
 	return out, nil
 }
 
+// DivContext is like Div but sends the metadata of ctx,
+// and cancels the call when ctx is done.
+func (c *ArithServiceClient) DivContext(ctx context.Context, in *ArithRequest, opts ...protorpc.CallOption) (out *ArithResponse, err error) {
+	if in == nil {
+		in = new(ArithRequest)
+	}
+
+	type Validator interface {
+		Validate() error
+	}
+	if x, ok := proto.Message(in).(Validator); ok {
+		if err := x.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	out = new(ArithResponse)
+	if err = protorpc.CallContext(ctx, c.Client, "ArithService.Div", in, out, opts...); err != nil {
+		return nil, err
+	}
+
+	if x, ok := proto.Message(out).(Validator); ok {
+		if err := x.Validate(); err != nil {
+			return out, err
+		}
+	}
+
+	return out, nil
+}
+
 func (c *ArithServiceClient) AsyncDiv(in *ArithRequest, out *ArithResponse, done chan *rpc.Call) *rpc.Call {
 	if in == nil {
 		in = new(ArithRequest)
@@ -233,6 +383,20 @@ func (c *ArithServiceClient) AsyncDiv(in *ArithRequest, out *ArithResponse, done
 	)
 }
 
+// AsyncDivContext is like AsyncDiv but sends the metadata of ctx,
+// and cancels the call when ctx is done.
+func (c *ArithServiceClient) AsyncDivContext(ctx context.Context, in *ArithRequest, out *ArithResponse, done chan *rpc.Call, opts ...protorpc.CallOption) *rpc.Call {
+	if in == nil {
+		in = new(ArithRequest)
+	}
+	return protorpc.GoContext(
+		ctx, c.Client,
+		"ArithService.Div",
+		in, out,
+		done, opts...,
+	)
+}
+
 func (c *ArithServiceClient) Error(in *ArithRequest) (out *ArithResponse, err error) {
 	if in == nil {
 		in = new(ArithRequest)
@@ -261,6 +425,36 @@ func (c *ArithServiceClient) Error(in *ArithRequest) (out *ArithResponse, err er
 	return out, nil
 }
 
+// ErrorContext is like Error but sends the metadata of ctx,
+// and cancels the call when ctx is done.
+func (c *ArithServiceClient) ErrorContext(ctx context.Context, in *ArithRequest, opts ...protorpc.CallOption) (out *ArithResponse, err error) {
+	if in == nil {
+		in = new(ArithRequest)
+	}
+
+	type Validator interface {
+		Validate() error
+	}
+	if x, ok := proto.Message(in).(Validator); ok {
+		if err := x.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	out = new(ArithResponse)
+	if err = protorpc.CallContext(ctx, c.Client, "ArithService.Error", in, out, opts...); err != nil {
+		return nil, err
+	}
+
+	if x, ok := proto.Message(out).(Validator); ok {
+		if err := x.Validate(); err != nil {
+			return out, err
+		}
+	}
+
+	return out, nil
+}
+
 func (c *ArithServiceClient) AsyncError(in *ArithRequest, out *ArithResponse, done chan *rpc.Call) *rpc.Call {
 	if in == nil {
 		in = new(ArithRequest)
@@ -272,6 +466,20 @@ func (c *ArithServiceClient) AsyncError(in *ArithRequest, out *ArithResponse, do
 	)
 }
 
+// AsyncErrorContext is like AsyncError but sends the metadata of ctx,
+// and cancels the call when ctx is done.
+func (c *ArithServiceClient) AsyncErrorContext(ctx context.Context, in *ArithRequest, out *ArithResponse, done chan *rpc.Call, opts ...protorpc.CallOption) *rpc.Call {
+	if in == nil {
+		in = new(ArithRequest)
+	}
+	return protorpc.GoContext(
+		ctx, c.Client,
+		"ArithService.Error",
+		in, out,
+		done, opts...,
+	)
+}
+
 // DialArithService connects to an ArithService at the specified network address.
 func DialArithService(network, addr string) (*ArithServiceClient, error) {
 	c, err := protorpc.Dial(network, addr)
@@ -281,6 +489,16 @@ func DialArithService(network, addr string) (*ArithServiceClient, error) {
 	return &ArithServiceClient{c}, nil
 }
 
+// DialArithServiceWithOptions connects to an ArithService at the specified network address,
+// using the given codec options.
+func DialArithServiceWithOptions(network, addr string, opts *protorpc.Options) (*ArithServiceClient, error) {
+	c, err := protorpc.DialWithOptions(network, addr, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &ArithServiceClient{c}, nil
+}
+
 // DialArithServiceTimeout connects to an ArithService at the specified network address.
 func DialArithServiceTimeout(network, addr string, timeout time.Duration) (*ArithServiceClient, error) {
 	c, err := protorpc.DialTimeout(network, addr, timeout)
@@ -289,3 +507,13 @@ func DialArithServiceTimeout(network, addr string, timeout time.Duration) (*Arit
 	}
 	return &ArithServiceClient{c}, nil
 }
+
+// DialArithServiceTLS connects to an ArithService at the specified network address
+// over TLS.
+func DialArithServiceTLS(network, addr string, config *tls.Config) (*ArithServiceClient, error) {
+	c, err := protorpc.DialTLS(network, addr, config)
+	if err != nil {
+		return nil, err
+	}
+	return &ArithServiceClient{c}, nil
+}
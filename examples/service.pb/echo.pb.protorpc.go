@@ -8,18 +8,21 @@
 package service
 
 import (
-	"fmt"
-	"io"
-	"log"
-	"net"
-	"net/rpc"
-	"time"
-
-	"github.com/chai2010/protorpc"
-	"github.com/golang/protobuf/proto"
+	context "context"
+	tls "crypto/tls"
+	fmt "fmt"
+	protorpc "github.com/chai2010/protorpc"
+	proto "github.com/golang/protobuf/proto"
+	io "io"
+	log "log"
+	net "net"
+	rpc "net/rpc"
+	time "time"
 )
 
 var (
+	_ = context.Background
+	_ = tls.Client
 	_ = fmt.Sprint
 	_ = io.Reader(nil)
 	_ = log.Print
@@ -74,6 +77,12 @@ func NewEchoServiceServer(x EchoService) *rpc.Server {
 // ListenAndServeEchoService listen announces on the local network address laddr
 // and serves the given EchoService implementation.
 func ListenAndServeEchoService(network, addr string, x EchoService) error {
+	return ListenAndServeEchoServiceWithOptions(network, addr, x, nil)
+}
+
+// ListenAndServeEchoServiceWithOptions is like ListenAndServeEchoService
+// but uses the given codec options for each connection.
+func ListenAndServeEchoServiceWithOptions(network, addr string, x EchoService, opts *protorpc.Options) error {
 	lis, err := net.Listen(network, addr)
 	if err != nil {
 		return err
@@ -90,17 +99,33 @@ func ListenAndServeEchoService(network, addr string, x EchoService) error {
 		if err != nil {
 			log.Fatalf("lis.Accept(): %v\n", err)
 		}
-		go srv.ServeCodec(protorpc.NewServerCodec(conn))
+		go srv.ServeCodec(protorpc.NewServerCodecWithOptions(conn, opts))
+	}
+}
+
+// ListenAndServeEchoServiceTLS listen announces on the local network address laddr
+// over TLS and serves the given EchoService implementation, see protorpc.ListenAndServeTLS.
+func ListenAndServeEchoServiceTLS(network, addr string, x EchoService, config *tls.Config) error {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("EchoService", x); err != nil {
+		return err
 	}
+	return protorpc.ListenAndServeTLS(network, addr, srv, config, nil)
 }
 
 // ServeEchoService serves the given EchoService implementation.
 func ServeEchoService(conn io.ReadWriteCloser, x EchoService) {
+	ServeEchoServiceWithOptions(conn, x, nil)
+}
+
+// ServeEchoServiceWithOptions serves the given EchoService implementation
+// with the given codec options.
+func ServeEchoServiceWithOptions(conn io.ReadWriteCloser, x EchoService, opts *protorpc.Options) {
 	srv := rpc.NewServer()
 	if err := srv.RegisterName("EchoService", x); err != nil {
 		log.Fatal(err)
 	}
-	srv.ServeCodec(protorpc.NewServerCodec(conn))
+	srv.ServeCodec(protorpc.NewServerCodecWithOptions(conn, opts))
 }
 
 type EchoServiceClient struct {
@@ -114,6 +139,13 @@ func NewEchoServiceClient(conn io.ReadWriteCloser) *EchoServiceClient {
 	return &EchoServiceClient{c}
 }
 
+// NewEchoServiceClientWithOptions is like NewEchoServiceClient
+// but uses the given codec options.
+func NewEchoServiceClientWithOptions(conn io.ReadWriteCloser, opts *protorpc.Options) *EchoServiceClient {
+	c := rpc.NewClientWithCodec(protorpc.NewClientCodecWithOptions(conn, opts))
+	return &EchoServiceClient{c}
+}
+
 func (c *EchoServiceClient) Echo(in *EchoRequest) (out *EchoResponse, err error) {
 	if in == nil {
 		in = new(EchoRequest)
@@ -142,6 +174,36 @@ func (c *EchoServiceClient) Echo(in *EchoRequest) (out *EchoResponse, err error)
 	return out, nil
 }
 
+// EchoContext is like Echo but sends the metadata of ctx,
+// and cancels the call when ctx is done.
+func (c *EchoServiceClient) EchoContext(ctx context.Context, in *EchoRequest, opts ...protorpc.CallOption) (out *EchoResponse, err error) {
+	if in == nil {
+		in = new(EchoRequest)
+	}
+
+	type Validator interface {
+		Validate() error
+	}
+	if x, ok := proto.Message(in).(Validator); ok {
+		if err := x.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	out = new(EchoResponse)
+	if err = protorpc.CallContext(ctx, c.Client, "EchoService.Echo", in, out, opts...); err != nil {
+		return nil, err
+	}
+
+	if x, ok := proto.Message(out).(Validator); ok {
+		if err := x.Validate(); err != nil {
+			return out, err
+		}
+	}
+
+	return out, nil
+}
+
 func (c *EchoServiceClient) AsyncEcho(in *EchoRequest, out *EchoResponse, done chan *rpc.Call) *rpc.Call {
 	if in == nil {
 		in = new(EchoRequest)
@@ -153,6 +215,20 @@ func (c *EchoServiceClient) AsyncEcho(in *EchoRequest, out *EchoResponse, done c
 	)
 }
 
+// AsyncEchoContext is like AsyncEcho but sends the metadata of ctx,
+// and cancels the call when ctx is done.
+func (c *EchoServiceClient) AsyncEchoContext(ctx context.Context, in *EchoRequest, out *EchoResponse, done chan *rpc.Call, opts ...protorpc.CallOption) *rpc.Call {
+	if in == nil {
+		in = new(EchoRequest)
+	}
+	return protorpc.GoContext(
+		ctx, c.Client,
+		"EchoService.Echo",
+		in, out,
+		done, opts...,
+	)
+}
+
 func (c *EchoServiceClient) EchoTwice(in *EchoRequest) (out *EchoResponse, err error) {
 	if in == nil {
 		in = new(EchoRequest)
@@ -181,6 +257,36 @@ func (c *EchoServiceClient) EchoTwice(in *EchoRequest) (out *EchoResponse, err e
 	return out, nil
 }
 
+// EchoTwiceContext is like EchoTwice but sends the metadata of ctx,
+// and cancels the call when ctx is done.
+func (c *EchoServiceClient) EchoTwiceContext(ctx context.Context, in *EchoRequest, opts ...protorpc.CallOption) (out *EchoResponse, err error) {
+	if in == nil {
+		in = new(EchoRequest)
+	}
+
+	type Validator interface {
+		Validate() error
+	}
+	if x, ok := proto.Message(in).(Validator); ok {
+		if err := x.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	out = new(EchoResponse)
+	if err = protorpc.CallContext(ctx, c.Client, "EchoService.EchoTwice", in, out, opts...); err != nil {
+		return nil, err
+	}
+
+	if x, ok := proto.Message(out).(Validator); ok {
+		if err := x.Validate(); err != nil {
+			return out, err
+		}
+	}
+
+	return out, nil
+}
+
 func (c *EchoServiceClient) AsyncEchoTwice(in *EchoRequest, out *EchoResponse, done chan *rpc.Call) *rpc.Call {
 	if in == nil {
 		in = new(EchoRequest)
@@ -192,6 +298,20 @@ func (c *EchoServiceClient) AsyncEchoTwice(in *EchoRequest, out *EchoResponse, d
 	)
 }
 
+// AsyncEchoTwiceContext is like AsyncEchoTwice but sends the metadata of ctx,
+// and cancels the call when ctx is done.
+func (c *EchoServiceClient) AsyncEchoTwiceContext(ctx context.Context, in *EchoRequest, out *EchoResponse, done chan *rpc.Call, opts ...protorpc.CallOption) *rpc.Call {
+	if in == nil {
+		in = new(EchoRequest)
+	}
+	return protorpc.GoContext(
+		ctx, c.Client,
+		"EchoService.EchoTwice",
+		in, out,
+		done, opts...,
+	)
+}
+
 // DialEchoService connects to an EchoService at the specified network address.
 func DialEchoService(network, addr string) (*EchoServiceClient, error) {
 	c, err := protorpc.Dial(network, addr)
@@ -201,6 +321,16 @@ func DialEchoService(network, addr string) (*EchoServiceClient, error) {
 	return &EchoServiceClient{c}, nil
 }
 
+// DialEchoServiceWithOptions connects to an EchoService at the specified network address,
+// using the given codec options.
+func DialEchoServiceWithOptions(network, addr string, opts *protorpc.Options) (*EchoServiceClient, error) {
+	c, err := protorpc.DialWithOptions(network, addr, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &EchoServiceClient{c}, nil
+}
+
 // DialEchoServiceTimeout connects to an EchoService at the specified network address.
 func DialEchoServiceTimeout(network, addr string, timeout time.Duration) (*EchoServiceClient, error) {
 	c, err := protorpc.DialTimeout(network, addr, timeout)
@@ -209,3 +339,13 @@ func DialEchoServiceTimeout(network, addr string, timeout time.Duration) (*EchoS
 	}
 	return &EchoServiceClient{c}, nil
 }
+
+// DialEchoServiceTLS connects to an EchoService at the specified network address
+// over TLS.
+func DialEchoServiceTLS(network, addr string, config *tls.Config) (*EchoServiceClient, error) {
+	c, err := protorpc.DialTLS(network, addr, config)
+	if err != nil {
+		return nil, err
+	}
+	return &EchoServiceClient{c}, nil
+}
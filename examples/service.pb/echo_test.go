@@ -5,24 +5,19 @@
 package service
 
 import (
-	"fmt"
-	"log"
+	"net"
 	"net/rpc"
-	"sync"
 	"testing"
 	"unicode/utf8"
+
+	"github.com/chai2010/protorpc/protorpctest"
 )
 
 var (
-	echoHost = "127.0.0.1"
-	echoPort = 2015
-
 	echoRequest         = "Hello, new gopher!"
 	echoResponse        = echoRequest + echoRequest
 	echoMassiveRequest  = makeMassive("Hello, 世界.")
 	echoMassiveResponse = echoMassiveRequest + echoMassiveRequest
-
-	onceEcho sync.Once
 )
 
 func makeMassive(args string) string {
@@ -39,34 +34,47 @@ func makeMassive(args string) string {
 	return string(runeBuf)
 }
 
-func setupEchoServer() {
-	var wg sync.WaitGroup
-	wg.Add(1)
-	defer wg.Wait()
+// newEchoClient returns a client of an EchoService server listening in
+// memory, which is closed at the end of the test.
+func newEchoClient(t *testing.T) *EchoServiceClient {
+	srv := protorpctest.StartServer(t, nil, func(srv *rpc.Server) error {
+		return RegisterEchoService(srv, new(Echo))
+	})
+	conn, err := srv.Dial()
+	if err != nil {
+		t.Fatalf(`srv.Dial(): %v`, err)
+	}
+	return NewEchoServiceClient(conn)
+}
 
+// dialEchoServiceTCP returns a client of an EchoService server listening
+// on a TCP port chosen by the system, for the benchmarks to measure the
+// network path.
+func dialEchoServiceTCP(b *testing.B) *EchoServiceClient {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatalf(`net.Listen("tcp", "127.0.0.1:0"): %v`, err)
+	}
+	b.Cleanup(func() { lis.Close() })
 	go func() {
-		wg.Done()
-
-		addr := fmt.Sprintf("127.0.0.1:%d", echoPort)
-		err := ListenAndServeEchoService("tcp", addr, new(Echo))
-		if err != nil {
-			log.Fatalf("ListenAndServeEchoService: %v", err)
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go ServeEchoService(conn, new(Echo))
 		}
 	}()
-}
-
-func TestEchoService(t *testing.T) {
-	onceEcho.Do(setupEchoServer)
 
-	addr := fmt.Sprintf("%s:%d", echoHost, echoPort)
-	c, err := DialEchoService("tcp", addr)
+	c, err := DialEchoService("tcp", lis.Addr().String())
 	if err != nil {
-		t.Fatalf(
-			`net.Dial("tcp", "%s:%d"): %v`,
-			echoHost, echoPort,
-			err,
-		)
+		b.Fatalf(`DialEchoService("tcp", %q): %v`, lis.Addr(), err)
 	}
+	return c
+}
+
+func TestEchoService(t *testing.T) {
+	c := newEchoClient(t)
 	defer c.Close()
 
 	testEchoService(t, c.Client)
@@ -108,21 +116,12 @@ func testEchoService(t *testing.T, client *rpc.Client) {
 }
 
 func TestClientSyncEcho(t *testing.T) {
-	onceEcho.Do(setupEchoServer)
-
-	addr := fmt.Sprintf("%s:%d", echoHost, echoPort)
-	echoClient, err := DialEchoService("tcp", addr)
-	if err != nil {
-		t.Fatalf(
-			`net.Dial("tcp", "%s:%d"): %v`,
-			echoHost, echoPort,
-			err,
-		)
-	}
+	echoClient := newEchoClient(t)
 	defer echoClient.Close()
 
 	var args EchoRequest
 	var reply *EchoResponse
+	var err error
 
 	// EchoService.EchoTwice
 	args.Msg = "abc"
@@ -152,21 +151,12 @@ func TestClientSyncEcho(t *testing.T) {
 }
 
 func TestClientSyncMassive(t *testing.T) {
-	onceEcho.Do(setupEchoServer)
-
-	addr := fmt.Sprintf("%s:%d", echoHost, echoPort)
-	echoClient, err := DialEchoService("tcp", addr)
-	if err != nil {
-		t.Fatalf(
-			`net.Dial("tcp", "%s:%d"): %v`,
-			echoHost, echoPort,
-			err,
-		)
-	}
+	echoClient := newEchoClient(t)
 	defer echoClient.Close()
 
 	var args EchoRequest
 	var reply *EchoResponse
+	var err error
 
 	// EchoService.EchoTwice
 	args.Msg = echoMassiveRequest + "abc"
@@ -202,17 +192,7 @@ func TestClientSyncMassive(t *testing.T) {
 }
 
 func TestClientAsyncEcho(t *testing.T) {
-	onceEcho.Do(setupEchoServer)
-
-	addr := fmt.Sprintf("%s:%d", echoHost, echoPort)
-	client, err := DialEchoService("tcp", addr)
-	if err != nil {
-		t.Fatalf(
-			`net.Dial("tcp", "%s:%d"): %v`,
-			echoHost, echoPort,
-			err,
-		)
-	}
+	client := newEchoClient(t)
 	defer client.Close()
 
 	var args EchoRequest
@@ -235,17 +215,7 @@ func TestClientAsyncEcho(t *testing.T) {
 }
 
 func TestClientAsyncEchoBatches(t *testing.T) {
-	onceEcho.Do(setupEchoServer)
-
-	addr := fmt.Sprintf("%s:%d", echoHost, echoPort)
-	client, err := DialEchoService("tcp", addr)
-	if err != nil {
-		t.Fatalf(
-			`net.Dial("tcp", "%s:%d"): %v`,
-			echoHost, echoPort,
-			err,
-		)
-	}
+	client := newEchoClient(t)
 	defer client.Close()
 
 	var args1 EchoRequest
@@ -305,17 +275,7 @@ func TestClientAsyncEchoBatches(t *testing.T) {
 }
 
 func TestClientAsyncMassive(t *testing.T) {
-	onceEcho.Do(setupEchoServer)
-
-	addr := fmt.Sprintf("%s:%d", echoHost, echoPort)
-	client, err := DialEchoService("tcp", addr)
-	if err != nil {
-		t.Fatalf(
-			`net.Dial("tcp", "%s:%d"): %v`,
-			echoHost, echoPort,
-			err,
-		)
-	}
+	client := newEchoClient(t)
 	defer client.Close()
 
 	var args EchoRequest
@@ -341,17 +301,7 @@ func TestClientAsyncMassive(t *testing.T) {
 }
 
 func TestClientAsyncMassiveBatches(t *testing.T) {
-	onceEcho.Do(setupEchoServer)
-
-	addr := fmt.Sprintf("%s:%d", echoHost, echoPort)
-	client, err := DialEchoService("tcp", addr)
-	if err != nil {
-		t.Fatalf(
-			`net.Dial("tcp", "%s:%d"): %v`,
-			echoHost, echoPort,
-			err,
-		)
-	}
+	client := newEchoClient(t)
 	defer client.Close()
 
 	var args1 EchoRequest
@@ -417,23 +367,15 @@ func TestClientAsyncMassiveBatches(t *testing.T) {
 }
 
 func BenchmarkSyncEcho(b *testing.B) {
-	onceEcho.Do(setupEchoServer)
-
-	addr := fmt.Sprintf("%s:%d", echoHost, echoPort)
-	echoClient, err := DialEchoService("tcp", addr)
-	if err != nil {
-		b.Fatalf(
-			`net.Dial("tcp", "%s:%d"): %v`,
-			echoHost, echoPort,
-			err,
-		)
-	}
+	echoClient := dialEchoServiceTCP(b)
 	defer echoClient.Close()
 
+	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		var args EchoRequest
 		var reply *EchoResponse
+		var err error
 
 		// EchoService.EchoTwice
 		args.Msg = "abc"
@@ -477,23 +419,14 @@ func BenchmarkSyncEcho(b *testing.B) {
 }
 
 func BenchmarkSyncMassive(b *testing.B) {
-	onceEcho.Do(setupEchoServer)
-
-	addr := fmt.Sprintf("%s:%d", echoHost, echoPort)
-	echoClient, err := DialEchoService("tcp", addr)
-	if err != nil {
-		b.Fatalf(
-			`net.Dial("tcp", "%s:%d"): %v`,
-			echoHost, echoPort,
-			err,
-		)
-	}
+	echoClient := dialEchoServiceTCP(b)
 	defer echoClient.Close()
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		var args EchoRequest
 		var reply *EchoResponse
+		var err error
 
 		// EchoService.EchoTwice
 		args.Msg = echoMassiveRequest + "abc"
@@ -546,17 +479,7 @@ func BenchmarkSyncMassive(b *testing.B) {
 }
 
 func BenchmarkAsyncEcho(b *testing.B) {
-	onceEcho.Do(setupEchoServer)
-
-	addr := fmt.Sprintf("%s:%d", echoHost, echoPort)
-	client, err := DialEchoService("tcp", addr)
-	if err != nil {
-		b.Fatalf(
-			`net.Dial("tcp", "%s:%d"): %v`,
-			echoHost, echoPort,
-			err,
-		)
-	}
+	client := dialEchoServiceTCP(b)
 	defer client.Close()
 
 	b.ResetTimer()
@@ -619,19 +542,10 @@ func BenchmarkAsyncEcho(b *testing.B) {
 }
 
 func BenchmarkAsyncMassive(b *testing.B) {
-	onceEcho.Do(setupEchoServer)
-
-	addr := fmt.Sprintf("%s:%d", echoHost, echoPort)
-	client, err := DialEchoService("tcp", addr)
-	if err != nil {
-		b.Fatalf(
-			`net.Dial("tcp", "%s:%d"): %v`,
-			echoHost, echoPort,
-			err,
-		)
-	}
+	client := dialEchoServiceTCP(b)
 	defer client.Close()
 
+	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		var args1 EchoRequest
@@ -2,7 +2,7 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-//go:generate protoc --go_out=. arith.proto echo.proto
-//go:generate protoc --protorpc_out=. arith.proto echo.proto
+//go:generate protoc -I. -I../../wire.pb --go_out=Mgoogle/protobuf/empty.proto=github.com/golang/protobuf/ptypes/empty:. arith.proto echo.proto stream.proto telemetry.proto
+//go:generate protoc -I. -I../../wire.pb --protorpc_out=Mgoogle/protobuf/empty.proto=github.com/golang/protobuf/ptypes/empty:. arith.proto echo.proto stream.proto telemetry.proto
 
 package service
@@ -0,0 +1,51 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"fmt"
+	"io"
+)
+
+type Stream int
+
+func (t *Stream) Tail(args *TailRequest, stream StreamService_TailServer) error {
+	for i := 0; i < int(args.Lines); i++ {
+		line := &LogLine{Text: fmt.Sprintf("%s: line %d", args.Name, i)}
+		if err := stream.Send(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *Stream) Upload(stream StreamService_UploadServer) error {
+	var size int64
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&UploadResult{Size: size})
+		}
+		if err != nil {
+			return err
+		}
+		size += int64(len(chunk.Data))
+	}
+}
+
+func (t *Stream) Echo(stream StreamService_EchoServer) error {
+	for {
+		line, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(line); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: stream.proto
+
+package service
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+type TailRequest struct {
+	Name  string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Lines int32  `protobuf:"varint,2,opt,name=lines" json:"lines,omitempty"`
+}
+
+func (m *TailRequest) Reset()                    { *m = TailRequest{} }
+func (m *TailRequest) String() string            { return proto.CompactTextString(m) }
+func (*TailRequest) ProtoMessage()               {}
+func (*TailRequest) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{0} }
+
+func (m *TailRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *TailRequest) GetLines() int32 {
+	if m != nil {
+		return m.Lines
+	}
+	return 0
+}
+
+type LogLine struct {
+	Text string `protobuf:"bytes,1,opt,name=text" json:"text,omitempty"`
+}
+
+func (m *LogLine) Reset()                    { *m = LogLine{} }
+func (m *LogLine) String() string            { return proto.CompactTextString(m) }
+func (*LogLine) ProtoMessage()               {}
+func (*LogLine) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{1} }
+
+func (m *LogLine) GetText() string {
+	if m != nil {
+		return m.Text
+	}
+	return ""
+}
+
+type UploadChunk struct {
+	Data []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (m *UploadChunk) Reset()                    { *m = UploadChunk{} }
+func (m *UploadChunk) String() string            { return proto.CompactTextString(m) }
+func (*UploadChunk) ProtoMessage()               {}
+func (*UploadChunk) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{2} }
+
+func (m *UploadChunk) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+type UploadResult struct {
+	Size int64 `protobuf:"varint,1,opt,name=size" json:"size,omitempty"`
+}
+
+func (m *UploadResult) Reset()                    { *m = UploadResult{} }
+func (m *UploadResult) String() string            { return proto.CompactTextString(m) }
+func (*UploadResult) ProtoMessage()               {}
+func (*UploadResult) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{3} }
+
+func (m *UploadResult) GetSize() int64 {
+	if m != nil {
+		return m.Size
+	}
+	return 0
+}
+
+func init() {
+	proto.RegisterType((*TailRequest)(nil), "service.TailRequest")
+	proto.RegisterType((*LogLine)(nil), "service.LogLine")
+	proto.RegisterType((*UploadChunk)(nil), "service.UploadChunk")
+	proto.RegisterType((*UploadResult)(nil), "service.UploadResult")
+}
+
+func init() { proto.RegisterFile("stream.proto", fileDescriptor2) }
+
+var fileDescriptor2 = []byte{
+	// 236 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x64, 0x90, 0xc1, 0x4a, 0x03, 0x31,
+	0x18, 0x84, 0xf9, 0x75, 0xdb, 0xe2, 0xdf, 0x15, 0xe4, 0xa7, 0x42, 0x29, 0x08, 0x35, 0xa7, 0x3d,
+	0x2d, 0x8b, 0x1e, 0xfa, 0x00, 0xe2, 0xad, 0xa7, 0x54, 0x1f, 0x20, 0xb6, 0x3f, 0x36, 0x98, 0x26,
+	0x75, 0x93, 0x15, 0xf1, 0x91, 0x7c, 0x4a, 0x49, 0x52, 0x65, 0x71, 0x6f, 0x93, 0x61, 0x26, 0xf3,
+	0x25, 0x58, 0xfa, 0xd0, 0xb2, 0x3a, 0xd4, 0xc7, 0xd6, 0x05, 0x47, 0x13, 0xcf, 0xed, 0x87, 0xde,
+	0xb2, 0x58, 0xe1, 0xf4, 0x49, 0x69, 0x23, 0xf9, 0xbd, 0x63, 0x1f, 0x88, 0xb0, 0xb0, 0xea, 0xc0,
+	0x73, 0x58, 0x42, 0x75, 0x21, 0x93, 0xa6, 0x19, 0x8e, 0x8c, 0xb6, 0xec, 0xe7, 0x67, 0x4b, 0xa8,
+	0x46, 0x32, 0x1f, 0xc4, 0x0d, 0x4e, 0xd6, 0xee, 0x75, 0xad, 0x2d, 0xc7, 0x52, 0xe0, 0xcf, 0xf0,
+	0x5b, 0x8a, 0x5a, 0xdc, 0xe2, 0xf4, 0xf9, 0x68, 0x9c, 0xda, 0x3d, 0xec, 0x3b, 0xfb, 0x16, 0x23,
+	0x3b, 0x15, 0x54, 0x8a, 0x94, 0x32, 0x69, 0x21, 0xb0, 0xcc, 0x11, 0xc9, 0xbe, 0x33, 0x69, 0xdb,
+	0xeb, 0xaf, 0xbc, 0x7d, 0x2e, 0x93, 0xbe, 0xfb, 0x06, 0xbc, 0xdc, 0x24, 0xf0, 0x4d, 0x06, 0xa6,
+	0x06, 0x8b, 0x08, 0x4c, 0xb3, 0xfa, 0xf4, 0x84, 0xba, 0xc7, 0xbf, 0xb8, 0xfa, 0x73, 0x4f, 0x70,
+	0x0d, 0xd0, 0x0a, 0xc7, 0x79, 0xa7, 0xd7, 0xe9, 0xb1, 0x2d, 0xae, 0xff, 0xb9, 0x19, 0xa7, 0x02,
+	0xaa, 0xb1, 0x78, 0xdc, 0xee, 0x1d, 0x0d, 0x2e, 0x1d, 0xce, 0x54, 0xd0, 0xc0, 0xcb, 0x38, 0xfd,
+	0xed, 0xfd, 0xcf, 0x00, 0x48, 0xca, 0xb4, 0xd4, 0x6b, 0x01, 0x00, 0x00,
+}
@@ -0,0 +1,385 @@
+// Code generated by protoc-gen-protorpc. DO NOT EDIT.
+//
+// plugin: https://github.com/chai2010/protorpc/tree/master/protoc-gen-plugin
+// plugin: https://github.com/chai2010/protorpc/tree/master/protoc-gen-protorpc
+//
+// source: stream.proto
+
+package service
+
+import (
+	context "context"
+	tls "crypto/tls"
+	fmt "fmt"
+	protorpc "github.com/chai2010/protorpc"
+	proto "github.com/golang/protobuf/proto"
+	io "io"
+	log "log"
+	net "net"
+	rpc "net/rpc"
+	time "time"
+)
+
+var (
+	_ = context.Background
+	_ = tls.Client
+	_ = fmt.Sprint
+	_ = io.Reader(nil)
+	_ = log.Print
+	_ = net.Addr(nil)
+	_ = rpc.Call{}
+	_ = time.Second
+
+	_ = proto.String
+	_ = protorpc.Dial
+)
+
+type StreamService interface {
+	Tail(in *TailRequest, stream StreamService_TailServer) error
+	Upload(stream StreamService_UploadServer) error
+	Echo(stream StreamService_EchoServer) error
+}
+
+// AcceptStreamServiceClient accepts connections on the listener and serves requests
+// for each incoming connection.  Accept blocks; the caller typically
+// invokes it in a go statement.
+func AcceptStreamServiceClient(lis net.Listener, x StreamService) {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("StreamService", &streamServiceRPC{x}); err != nil {
+		log.Fatal(err)
+	}
+
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			log.Fatalf("lis.Accept(): %v\n", err)
+		}
+		go srv.ServeCodec(protorpc.NewServerCodec(conn))
+	}
+}
+
+// RegisterStreamService publish the given StreamService implementation on the server.
+func RegisterStreamService(srv *rpc.Server, x StreamService) error {
+	if err := srv.RegisterName("StreamService", &streamServiceRPC{x}); err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewStreamServiceServer returns a new StreamService Server.
+func NewStreamServiceServer(x StreamService) *rpc.Server {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("StreamService", &streamServiceRPC{x}); err != nil {
+		log.Fatal(err)
+	}
+	return srv
+}
+
+// ListenAndServeStreamService listen announces on the local network address laddr
+// and serves the given StreamService implementation.
+func ListenAndServeStreamService(network, addr string, x StreamService) error {
+	return ListenAndServeStreamServiceWithOptions(network, addr, x, nil)
+}
+
+// ListenAndServeStreamServiceWithOptions is like ListenAndServeStreamService
+// but uses the given codec options for each connection.
+func ListenAndServeStreamServiceWithOptions(network, addr string, x StreamService, opts *protorpc.Options) error {
+	lis, err := net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+	defer lis.Close()
+
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("StreamService", &streamServiceRPC{x}); err != nil {
+		return err
+	}
+
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			log.Fatalf("lis.Accept(): %v\n", err)
+		}
+		go srv.ServeCodec(protorpc.NewServerCodecWithOptions(conn, opts))
+	}
+}
+
+// ListenAndServeStreamServiceTLS listen announces on the local network address laddr
+// over TLS and serves the given StreamService implementation, see protorpc.ListenAndServeTLS.
+func ListenAndServeStreamServiceTLS(network, addr string, x StreamService, config *tls.Config) error {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("StreamService", &streamServiceRPC{x}); err != nil {
+		return err
+	}
+	return protorpc.ListenAndServeTLS(network, addr, srv, config, nil)
+}
+
+// ServeStreamService serves the given StreamService implementation.
+func ServeStreamService(conn io.ReadWriteCloser, x StreamService) {
+	ServeStreamServiceWithOptions(conn, x, nil)
+}
+
+// ServeStreamServiceWithOptions serves the given StreamService implementation
+// with the given codec options.
+func ServeStreamServiceWithOptions(conn io.ReadWriteCloser, x StreamService, opts *protorpc.Options) {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("StreamService", &streamServiceRPC{x}); err != nil {
+		log.Fatal(err)
+	}
+	srv.ServeCodec(protorpc.NewServerCodecWithOptions(conn, opts))
+}
+
+// StreamService_TailServer is the server side of the Tail stream.
+type StreamService_TailServer interface {
+	Send(*LogLine) error
+	Context() context.Context
+}
+
+type streamServiceTailServer struct {
+	*protorpc.ServerStream
+}
+
+func (x *streamServiceTailServer) Send(m *LogLine) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// StreamService_UploadServer is the server side of the Upload stream.
+type StreamService_UploadServer interface {
+	SendAndClose(*UploadResult) error
+	Recv() (*UploadChunk, error)
+	Context() context.Context
+}
+
+type streamServiceUploadServer struct {
+	*protorpc.ServerStream
+}
+
+func (x *streamServiceUploadServer) SendAndClose(m *UploadResult) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *streamServiceUploadServer) Recv() (*UploadChunk, error) {
+	m := new(UploadChunk)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// StreamService_EchoServer is the server side of the Echo stream.
+type StreamService_EchoServer interface {
+	Send(*LogLine) error
+	Recv() (*LogLine, error)
+	Context() context.Context
+}
+
+type streamServiceEchoServer struct {
+	*protorpc.ServerStream
+}
+
+func (x *streamServiceEchoServer) Send(m *LogLine) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *streamServiceEchoServer) Recv() (*LogLine, error) {
+	m := new(LogLine)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// streamServiceRPC serves a StreamService with net/rpc, the stream
+// methods are served with protorpc.ServerStream.
+type streamServiceRPC struct {
+	x StreamService
+}
+
+func (s *streamServiceRPC) Tail(stream *protorpc.ServerStream, _ *protorpc.StreamReply) error {
+	in := new(TailRequest)
+	if err := stream.RecvMsg(in); err != nil {
+		return err
+	}
+	return s.x.Tail(in, &streamServiceTailServer{stream})
+}
+
+func (s *streamServiceRPC) Upload(stream *protorpc.ServerStream, _ *protorpc.StreamReply) error {
+	return s.x.Upload(&streamServiceUploadServer{stream})
+}
+
+func (s *streamServiceRPC) Echo(stream *protorpc.ServerStream, _ *protorpc.StreamReply) error {
+	return s.x.Echo(&streamServiceEchoServer{stream})
+}
+
+type StreamServiceClient struct {
+	*rpc.Client
+}
+
+// NewStreamServiceClient returns a StreamService stub to handle
+// requests to the set of StreamService at the other end of the connection.
+func NewStreamServiceClient(conn io.ReadWriteCloser) *StreamServiceClient {
+	c := rpc.NewClientWithCodec(protorpc.NewClientCodec(conn))
+	return &StreamServiceClient{c}
+}
+
+// NewStreamServiceClientWithOptions is like NewStreamServiceClient
+// but uses the given codec options.
+func NewStreamServiceClientWithOptions(conn io.ReadWriteCloser, opts *protorpc.Options) *StreamServiceClient {
+	c := rpc.NewClientWithCodec(protorpc.NewClientCodecWithOptions(conn, opts))
+	return &StreamServiceClient{c}
+}
+
+// Tail opens the Tail stream. The stream is canceled when ctx is done.
+func (c *StreamServiceClient) Tail(ctx context.Context, in *TailRequest, opts ...protorpc.CallOption) (StreamService_TailClient, error) {
+	stream, err := protorpc.NewStream(ctx, c.Client, "StreamService.Tail", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &streamServiceTailClient{stream}
+	if in == nil {
+		in = new(TailRequest)
+	}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// StreamService_TailClient is the client side of the Tail stream.
+type StreamService_TailClient interface {
+	Recv() (*LogLine, error)
+	Context() context.Context
+	Trailer() protorpc.Metadata
+}
+
+type streamServiceTailClient struct {
+	*protorpc.ClientStream
+}
+
+func (x *streamServiceTailClient) Recv() (*LogLine, error) {
+	m := new(LogLine)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Upload opens the Upload stream. The stream is canceled when ctx is done.
+func (c *StreamServiceClient) Upload(ctx context.Context, opts ...protorpc.CallOption) (StreamService_UploadClient, error) {
+	stream, err := protorpc.NewStream(ctx, c.Client, "StreamService.Upload", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &streamServiceUploadClient{stream}
+	return x, nil
+}
+
+// StreamService_UploadClient is the client side of the Upload stream.
+type StreamService_UploadClient interface {
+	Send(*UploadChunk) error
+	CloseAndRecv() (*UploadResult, error)
+	Context() context.Context
+	Trailer() protorpc.Metadata
+}
+
+type streamServiceUploadClient struct {
+	*protorpc.ClientStream
+}
+
+func (x *streamServiceUploadClient) Send(m *UploadChunk) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *streamServiceUploadClient) CloseAndRecv() (*UploadResult, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(UploadResult)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	// wait for the end of the stream, and its trailer
+	if err := x.ClientStream.RecvMsg(new(UploadResult)); err != io.EOF {
+		if err == nil {
+			err = fmt.Errorf("protorpc: StreamService.Upload sent more than one reply")
+		}
+		return nil, err
+	}
+	return m, nil
+}
+
+// Echo opens the Echo stream. The stream is canceled when ctx is done.
+func (c *StreamServiceClient) Echo(ctx context.Context, opts ...protorpc.CallOption) (StreamService_EchoClient, error) {
+	stream, err := protorpc.NewStream(ctx, c.Client, "StreamService.Echo", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &streamServiceEchoClient{stream}
+	return x, nil
+}
+
+// StreamService_EchoClient is the client side of the Echo stream.
+type StreamService_EchoClient interface {
+	Send(*LogLine) error
+	Recv() (*LogLine, error)
+	CloseSend() error
+	Context() context.Context
+	Trailer() protorpc.Metadata
+}
+
+type streamServiceEchoClient struct {
+	*protorpc.ClientStream
+}
+
+func (x *streamServiceEchoClient) Send(m *LogLine) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *streamServiceEchoClient) Recv() (*LogLine, error) {
+	m := new(LogLine)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// DialStreamService connects to an StreamService at the specified network address.
+func DialStreamService(network, addr string) (*StreamServiceClient, error) {
+	c, err := protorpc.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &StreamServiceClient{c}, nil
+}
+
+// DialStreamServiceWithOptions connects to an StreamService at the specified network address,
+// using the given codec options.
+func DialStreamServiceWithOptions(network, addr string, opts *protorpc.Options) (*StreamServiceClient, error) {
+	c, err := protorpc.DialWithOptions(network, addr, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &StreamServiceClient{c}, nil
+}
+
+// DialStreamServiceTimeout connects to an StreamService at the specified network address.
+func DialStreamServiceTimeout(network, addr string, timeout time.Duration) (*StreamServiceClient, error) {
+	c, err := protorpc.DialTimeout(network, addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &StreamServiceClient{c}, nil
+}
+
+// DialStreamServiceTLS connects to an StreamService at the specified network address
+// over TLS.
+func DialStreamServiceTLS(network, addr string, config *tls.Config) (*StreamServiceClient, error) {
+	c, err := protorpc.DialTLS(network, addr, config)
+	if err != nil {
+		return nil, err
+	}
+	return &StreamServiceClient{c}, nil
+}
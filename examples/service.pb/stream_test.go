@@ -0,0 +1,81 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/chai2010/protorpc"
+)
+
+func TestStreamService(t *testing.T) {
+	cliConn, srvConn := net.Pipe()
+	go ServeStreamService(srvConn, new(Stream))
+	c := NewStreamServiceClientWithOptions(cliConn, &protorpc.Options{Handshake: true})
+	defer c.Close()
+	ctx := context.Background()
+
+	// StreamService.Tail
+	tail, err := c.Tail(ctx, &TailRequest{Name: "app.log", Lines: 3})
+	if err != nil {
+		t.Fatalf(`StreamService.Tail: %v`, err)
+	}
+	var lines []string
+	for {
+		line, err := tail.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf(`StreamService.Tail: %v`, err)
+		}
+		lines = append(lines, line.Text)
+	}
+	if len(lines) != 3 || lines[2] != "app.log: line 2" {
+		t.Fatalf(`StreamService.Tail: unexpected lines %q`, lines)
+	}
+
+	// StreamService.Upload
+	upload, err := c.Upload(ctx)
+	if err != nil {
+		t.Fatalf(`StreamService.Upload: %v`, err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := upload.Send(&UploadChunk{Data: make([]byte, 1000)}); err != nil {
+			t.Fatalf(`StreamService.Upload: %v`, err)
+		}
+	}
+	result, err := upload.CloseAndRecv()
+	if err != nil {
+		t.Fatalf(`StreamService.Upload: %v`, err)
+	}
+	if result.Size != 10000 {
+		t.Fatalf(`StreamService.Upload: expected = 10000, got = %d`, result.Size)
+	}
+
+	// StreamService.Echo
+	echo, err := c.Echo(ctx)
+	if err != nil {
+		t.Fatalf(`StreamService.Echo: %v`, err)
+	}
+	for _, s := range []string{"a", "b"} {
+		if err := echo.Send(&LogLine{Text: s}); err != nil {
+			t.Fatalf(`StreamService.Echo: %v`, err)
+		}
+		line, err := echo.Recv()
+		if err != nil || line.Text != s {
+			t.Fatalf(`StreamService.Echo: %v %v`, line, err)
+		}
+	}
+	if err := echo.CloseSend(); err != nil {
+		t.Fatalf(`StreamService.Echo: %v`, err)
+	}
+	if _, err := echo.Recv(); err != io.EOF {
+		t.Fatalf(`StreamService.Echo: expected io.EOF, got %v`, err)
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/golang/protobuf/ptypes/empty"
+)
+
+// Telemetry sums the values of the recorded events by name.
+type Telemetry struct {
+	mutex sync.Mutex
+	sums  map[string]int64
+}
+
+func (t *Telemetry) Record(args *Event, reply *empty.Empty) error {
+	if args.Name == "" {
+		return errors.New("telemetry: event without name")
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.sums == nil {
+		t.sums = make(map[string]int64)
+	}
+	t.sums[args.Name] += args.Value
+	return nil
+}
+
+// Sum returns the sum of the values recorded for name.
+func (t *Telemetry) Sum(name string) int64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.sums[name]
+}
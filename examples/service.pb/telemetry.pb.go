@@ -0,0 +1,60 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: telemetry.proto
+
+package service
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+import _ "github.com/golang/protobuf/ptypes/empty"
+import _ "github.com/chai2010/protorpc/wire.pb"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+type Event struct {
+	Name  string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Value int64  `protobuf:"varint,2,opt,name=value" json:"value,omitempty"`
+}
+
+func (m *Event) Reset()                    { *m = Event{} }
+func (m *Event) String() string            { return proto.CompactTextString(m) }
+func (*Event) ProtoMessage()               {}
+func (*Event) Descriptor() ([]byte, []int) { return fileDescriptor3, []int{0} }
+
+func (m *Event) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Event) GetValue() int64 {
+	if m != nil {
+		return m.Value
+	}
+	return 0
+}
+
+func init() {
+	proto.RegisterType((*Event)(nil), "service.Event")
+}
+
+func init() { proto.RegisterFile("telemetry.proto", fileDescriptor3) }
+
+var fileDescriptor3 = []byte{
+	// 172 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0x2f, 0x49, 0xcd, 0x49,
+	0xcd, 0x4d, 0x2d, 0x29, 0xaa, 0xd4, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62, 0x2f, 0x4e, 0x2d,
+	0x2a, 0xcb, 0x4c, 0x4e, 0x95, 0x92, 0x4e, 0xcf, 0xcf, 0x4f, 0xcf, 0x49, 0xd5, 0x07, 0x0b, 0x27,
+	0x95, 0xa6, 0xe9, 0xa7, 0xe6, 0x16, 0x94, 0x40, 0x55, 0x49, 0xf1, 0x81, 0xa9, 0xa2, 0x82, 0x64,
+	0x08, 0x5f, 0xc9, 0x90, 0x8b, 0xd5, 0xb5, 0x2c, 0x35, 0xaf, 0x44, 0x48, 0x88, 0x8b, 0x25, 0x2f,
+	0x31, 0x37, 0x55, 0x82, 0x51, 0x81, 0x51, 0x83, 0x33, 0x08, 0xcc, 0x16, 0x12, 0xe1, 0x62, 0x2d,
+	0x4b, 0xcc, 0x29, 0x4d, 0x95, 0x60, 0x52, 0x60, 0xd4, 0x60, 0x0e, 0x82, 0x70, 0x8c, 0xbc, 0xb8,
+	0x04, 0x42, 0x60, 0x76, 0x07, 0x43, 0xec, 0x14, 0x32, 0xe3, 0x62, 0x0b, 0x4a, 0x4d, 0xce, 0x2f,
+	0x4a, 0x11, 0xe2, 0xd3, 0x83, 0xba, 0x43, 0x0f, 0x6c, 0xae, 0x94, 0x98, 0x1e, 0xc4, 0x39, 0x7a,
+	0x30, 0xe7, 0xe8, 0xb9, 0x82, 0x9c, 0xa3, 0xc4, 0xb2, 0xa1, 0x57, 0x92, 0x31, 0x89, 0x0d, 0x2c,
+	0x6a, 0x0c, 0x18, 0x00, 0x5d, 0x1a, 0xd7, 0x77, 0xce, 0x00, 0x00, 0x00,
+}
@@ -0,0 +1,209 @@
+// Code generated by protoc-gen-protorpc. DO NOT EDIT.
+//
+// plugin: https://github.com/chai2010/protorpc/tree/master/protoc-gen-plugin
+// plugin: https://github.com/chai2010/protorpc/tree/master/protoc-gen-protorpc
+//
+// source: telemetry.proto
+
+package service
+
+import (
+	context "context"
+	tls "crypto/tls"
+	fmt "fmt"
+	protorpc "github.com/chai2010/protorpc"
+	proto "github.com/golang/protobuf/proto"
+	empty "github.com/golang/protobuf/ptypes/empty"
+	io "io"
+	log "log"
+	net "net"
+	rpc "net/rpc"
+	time "time"
+)
+
+var (
+	_ = context.Background
+	_ = tls.Client
+	_ = fmt.Sprint
+	_ = io.Reader(nil)
+	_ = log.Print
+	_ = net.Addr(nil)
+	_ = rpc.Call{}
+	_ = time.Second
+
+	_ = proto.String
+	_ = protorpc.Dial
+)
+
+type TelemetryService interface {
+	Record(in *Event, out *empty.Empty) error
+}
+
+// AcceptTelemetryServiceClient accepts connections on the listener and serves requests
+// for each incoming connection.  Accept blocks; the caller typically
+// invokes it in a go statement.
+func AcceptTelemetryServiceClient(lis net.Listener, x TelemetryService) {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("TelemetryService", x); err != nil {
+		log.Fatal(err)
+	}
+
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			log.Fatalf("lis.Accept(): %v\n", err)
+		}
+		go srv.ServeCodec(protorpc.NewServerCodec(conn))
+	}
+}
+
+// RegisterTelemetryService publish the given TelemetryService implementation on the server.
+func RegisterTelemetryService(srv *rpc.Server, x TelemetryService) error {
+	if err := srv.RegisterName("TelemetryService", x); err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewTelemetryServiceServer returns a new TelemetryService Server.
+func NewTelemetryServiceServer(x TelemetryService) *rpc.Server {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("TelemetryService", x); err != nil {
+		log.Fatal(err)
+	}
+	return srv
+}
+
+// ListenAndServeTelemetryService listen announces on the local network address laddr
+// and serves the given TelemetryService implementation.
+func ListenAndServeTelemetryService(network, addr string, x TelemetryService) error {
+	return ListenAndServeTelemetryServiceWithOptions(network, addr, x, nil)
+}
+
+// ListenAndServeTelemetryServiceWithOptions is like ListenAndServeTelemetryService
+// but uses the given codec options for each connection.
+func ListenAndServeTelemetryServiceWithOptions(network, addr string, x TelemetryService, opts *protorpc.Options) error {
+	lis, err := net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+	defer lis.Close()
+
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("TelemetryService", x); err != nil {
+		return err
+	}
+
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			log.Fatalf("lis.Accept(): %v\n", err)
+		}
+		go srv.ServeCodec(protorpc.NewServerCodecWithOptions(conn, opts))
+	}
+}
+
+// ListenAndServeTelemetryServiceTLS listen announces on the local network address laddr
+// over TLS and serves the given TelemetryService implementation, see protorpc.ListenAndServeTLS.
+func ListenAndServeTelemetryServiceTLS(network, addr string, x TelemetryService, config *tls.Config) error {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("TelemetryService", x); err != nil {
+		return err
+	}
+	return protorpc.ListenAndServeTLS(network, addr, srv, config, nil)
+}
+
+// ServeTelemetryService serves the given TelemetryService implementation.
+func ServeTelemetryService(conn io.ReadWriteCloser, x TelemetryService) {
+	ServeTelemetryServiceWithOptions(conn, x, nil)
+}
+
+// ServeTelemetryServiceWithOptions serves the given TelemetryService implementation
+// with the given codec options.
+func ServeTelemetryServiceWithOptions(conn io.ReadWriteCloser, x TelemetryService, opts *protorpc.Options) {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("TelemetryService", x); err != nil {
+		log.Fatal(err)
+	}
+	srv.ServeCodec(protorpc.NewServerCodecWithOptions(conn, opts))
+}
+
+type TelemetryServiceClient struct {
+	*rpc.Client
+}
+
+// NewTelemetryServiceClient returns a TelemetryService stub to handle
+// requests to the set of TelemetryService at the other end of the connection.
+func NewTelemetryServiceClient(conn io.ReadWriteCloser) *TelemetryServiceClient {
+	c := rpc.NewClientWithCodec(protorpc.NewClientCodec(conn))
+	return &TelemetryServiceClient{c}
+}
+
+// NewTelemetryServiceClientWithOptions is like NewTelemetryServiceClient
+// but uses the given codec options.
+func NewTelemetryServiceClientWithOptions(conn io.ReadWriteCloser, opts *protorpc.Options) *TelemetryServiceClient {
+	c := rpc.NewClientWithCodec(protorpc.NewClientCodecWithOptions(conn, opts))
+	return &TelemetryServiceClient{c}
+}
+
+// Record sends a one-way call: it returns once the request is sent,
+// and the server sends no reply.
+func (c *TelemetryServiceClient) Record(in *Event) error {
+	return c.RecordContext(context.Background(), in)
+}
+
+// RecordContext is like Record but sends the metadata of ctx.
+func (c *TelemetryServiceClient) RecordContext(ctx context.Context, in *Event, opts ...protorpc.CallOption) error {
+	if in == nil {
+		in = new(Event)
+	}
+
+	type Validator interface {
+		Validate() error
+	}
+	if x, ok := proto.Message(in).(Validator); ok {
+		if err := x.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return protorpc.OneWay(ctx, c.Client, "TelemetryService.Record", in, opts...)
+}
+
+// DialTelemetryService connects to an TelemetryService at the specified network address.
+func DialTelemetryService(network, addr string) (*TelemetryServiceClient, error) {
+	c, err := protorpc.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &TelemetryServiceClient{c}, nil
+}
+
+// DialTelemetryServiceWithOptions connects to an TelemetryService at the specified network address,
+// using the given codec options.
+func DialTelemetryServiceWithOptions(network, addr string, opts *protorpc.Options) (*TelemetryServiceClient, error) {
+	c, err := protorpc.DialWithOptions(network, addr, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &TelemetryServiceClient{c}, nil
+}
+
+// DialTelemetryServiceTimeout connects to an TelemetryService at the specified network address.
+func DialTelemetryServiceTimeout(network, addr string, timeout time.Duration) (*TelemetryServiceClient, error) {
+	c, err := protorpc.DialTimeout(network, addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &TelemetryServiceClient{c}, nil
+}
+
+// DialTelemetryServiceTLS connects to an TelemetryService at the specified network address
+// over TLS.
+func DialTelemetryServiceTLS(network, addr string, config *tls.Config) (*TelemetryServiceClient, error) {
+	c, err := protorpc.DialTLS(network, addr, config)
+	if err != nil {
+		return nil, err
+	}
+	return &TelemetryServiceClient{c}, nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"net/rpc"
+	"testing"
+	"time"
+
+	"github.com/chai2010/protorpc"
+	"github.com/chai2010/protorpc/protorpctest"
+)
+
+func TestTelemetryService(t *testing.T) {
+	telemetry := new(Telemetry)
+	errs := make(chan error, 1)
+	opts := &protorpc.Options{
+		OneWayErrorHandler: func(serviceMethod string, err error) {
+			if serviceMethod == "TelemetryService.Record" {
+				errs <- err
+			}
+		},
+	}
+	srv := protorpctest.StartServer(t, opts, func(srv *rpc.Server) error {
+		return RegisterTelemetryService(srv, telemetry)
+	})
+	conn, err := srv.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewTelemetryServiceClient(conn)
+	defer c.Close()
+
+	for i := 1; i <= 10; i++ {
+		if err := c.Record(&Event{Name: "requests", Value: int64(i)}); err != nil {
+			t.Fatalf(`TelemetryService.Record: %v`, err)
+		}
+	}
+
+	// the calls return before they are served
+	deadline := time.Now().Add(5 * time.Second)
+	for telemetry.Sum("requests") != 55 {
+		if time.Now().After(deadline) {
+			t.Fatalf(`TelemetryService.Record: expected sum = 55, got = %d`, telemetry.Sum("requests"))
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// the error stays on the server
+	if err := c.Record(&Event{}); err != nil {
+		t.Fatalf(`TelemetryService.Record: %v`, err)
+	}
+	select {
+	case err := <-errs:
+		if err.Error() != "telemetry: event without name" {
+			t.Fatalf(`TelemetryService.Record: unexpected error %v`, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal(`TelemetryService.Record: the error handler was not called`)
+	}
+}
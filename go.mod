@@ -7,6 +7,7 @@ module github.com/chai2010/protorpc
 go 1.16
 
 require (
-	github.com/golang/protobuf v1.0.0
+	github.com/golang/protobuf v1.5.2
 	github.com/golang/snappy v0.0.3
+	google.golang.org/protobuf v1.26.0
 )
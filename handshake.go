@@ -0,0 +1,231 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	wire "github.com/chai2010/protorpc/wire.pb"
+	"github.com/golang/protobuf/proto"
+)
+
+// handshakeMagic starts the preamble of a connection. Its first byte is
+// 0, which is never the first byte of a legacy connection.
+const handshakeMagic = "\x00PRPC"
+
+// ErrNotProtorpc is returned by a server codec whose client is not a
+// Protobuf-RPC client, such as an HTTP client.
+var ErrNotProtorpc = errors.New("protorpc: the client is not a Protobuf-RPC client")
+
+// ErrHandshakeTimeout is the error of the calls of a client whose server
+// did not answer the handshake in time. Servers older than the handshake
+// never answer it.
+var ErrHandshakeTimeout = errors.New("protorpc: handshake timeout, the peer does not support the handshake")
+
+// DefaultHandshakeTimeout is the default value of Options.HandshakeTimeout.
+const DefaultHandshakeTimeout = 10 * time.Second
+
+// supportedFeatures are the features of this package.
+const supportedFeatures = uint64(wire.Feature_FEATURE_SNAPPY |
+	wire.Feature_FEATURE_GZIP |
+	wire.Feature_FEATURE_DEFLATE |
+	wire.Feature_FEATURE_CRC32C |
+	wire.Feature_FEATURE_XXHASH64 |
+	wire.Feature_FEATURE_METADATA |
+	wire.Feature_FEATURE_DEADLINE |
+	wire.Feature_FEATURE_CANCEL |
+	wire.Feature_FEATURE_STREAMING |
+	wire.Feature_FEATURE_PING |
+	wire.Feature_FEATURE_MUX |
+	wire.Feature_FEATURE_JSON)
+
+// legacyFeatures are the features used without handshake. Old servers
+// ignore unknown header fields, but not unknown frames, and only know
+// snappy and crc32 (IEEE).
+const legacyFeatures = supportedFeatures &^ uint64(wire.Feature_FEATURE_GZIP|
+	wire.Feature_FEATURE_DEFLATE|
+	wire.Feature_FEATURE_CRC32C|
+	wire.Feature_FEATURE_XXHASH64|
+	wire.Feature_FEATURE_CANCEL|
+	wire.Feature_FEATURE_STREAMING|
+	wire.Feature_FEATURE_PING|
+	wire.Feature_FEATURE_MUX|
+	wire.Feature_FEATURE_JSON)
+
+// handshakeInfo is the result of a handshake.
+type handshakeInfo struct {
+	features     uint64 // the accepted features
+	streamWindow int64  // the receive windows of the peer
+	connWindow   int64
+}
+
+func newHandshakeInfo(features uint64, streamWindow, connWindow uint32) handshakeInfo {
+	return handshakeInfo{
+		features:     features,
+		streamWindow: peerWindow(streamWindow, wire.Const_DEFAULT_STREAM_WINDOW),
+		connWindow:   peerWindow(connWindow, wire.Const_DEFAULT_CONN_WINDOW),
+	}
+}
+
+// compressionFeature returns the feature bit of a compressor id.
+// Uncompressed bodies and user registered compressors have no bit.
+func compressionFeature(id uint32) uint64 {
+	switch wire.CompressionType(id) {
+	case wire.CompressionType_COMPRESSION_SNAPPY:
+		return uint64(wire.Feature_FEATURE_SNAPPY)
+	case wire.CompressionType_COMPRESSION_GZIP:
+		return uint64(wire.Feature_FEATURE_GZIP)
+	case wire.CompressionType_COMPRESSION_DEFLATE:
+		return uint64(wire.Feature_FEATURE_DEFLATE)
+	}
+	return 0
+}
+
+// serializationFeature returns the feature bit of a serialization id.
+// Binary protobuf and user registered serializers have no bit.
+func serializationFeature(id uint32) uint64 {
+	if wire.SerializationType(id) == wire.SerializationType_SERIALIZATION_JSON {
+		return uint64(wire.Feature_FEATURE_JSON)
+	}
+	return 0
+}
+
+// checksumFeature returns the feature bit of a checksum type.
+// crc32 (IEEE) and no checksum have no bit.
+func checksumFeature(typ uint32) uint64 {
+	switch wire.ChecksumType(typ) {
+	case wire.ChecksumType_CHECKSUM_CRC32C:
+		return uint64(wire.Feature_FEATURE_CRC32C)
+	case wire.ChecksumType_CHECKSUM_XXHASH64:
+		return uint64(wire.Feature_FEATURE_XXHASH64)
+	}
+	return 0
+}
+
+// legacyCompression reports whether the servers older than the
+// handshake know a compression.
+func legacyCompression(id uint32) bool {
+	return id == uint32(wire.CompressionType_COMPRESSION_NONE) ||
+		id == uint32(wire.CompressionType_COMPRESSION_SNAPPY)
+}
+
+// legacyChecksum reports whether the servers older than the handshake
+// know a checksum type.
+func legacyChecksum(typ uint32) bool {
+	return typ == uint32(wire.ChecksumType_CHECKSUM_NONE) ||
+		typ == uint32(wire.ChecksumType_CHECKSUM_CRC32_IEEE)
+}
+
+// writeHandshake sends the magic bytes and msg.
+func writeHandshake(w io.Writer, msg proto.Message) error {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if err := write(w, []byte(handshakeMagic), false); err != nil {
+		return err
+	}
+	if err := sendFrame(w, data); err != nil {
+		return err
+	}
+	return flush(w)
+}
+
+// readHandshake receives the magic bytes and msg.
+func readHandshake(r io.Reader, msg proto.Message) error {
+	var magic [len(handshakeMagic)]byte
+	if err := read(r, magic[:]); err != nil {
+		return err
+	}
+	if string(magic[:]) != handshakeMagic {
+		return fmt.Errorf("protorpc: bad handshake magic %q", magic[:])
+	}
+	return recvMessage(r, int(wire.Const_MAX_REQUEST_HEADER_LEN), msg)
+}
+
+// clientHandshake runs the client side of the handshake.
+func clientHandshake(r io.Reader, w io.Writer, opts *Options) (handshakeInfo, error) {
+	err := writeHandshake(w, &wire.Handshake{
+		Version:      uint32(wire.Const_PROTOCOL_VERSION),
+		Features:     supportedFeatures,
+		StreamWindow: uint32(streamWindow(opts)),
+		ConnWindow:   uint32(connWindow(opts)),
+	})
+	if err != nil {
+		return handshakeInfo{}, err
+	}
+
+	var reply wire.HandshakeReply
+	if err := readHandshake(r, &reply); err != nil {
+		return handshakeInfo{}, err
+	}
+	if reply.Error != "" {
+		return handshakeInfo{}, fmt.Errorf("protorpc: handshake: %s", reply.Error)
+	}
+	return newHandshakeInfo(reply.Features&supportedFeatures, reply.StreamWindow, reply.ConnWindow), nil
+}
+
+// httpMethods are the prefixes of HTTP requests. They are not the start
+// of a legacy connection: the byte after the frame length is the tag of
+// a RequestHeader field.
+var httpMethods = []string{"GET ", "HEAD", "POST", "PUT ", "DELE", "CONN", "OPTI", "TRAC", "PATC", "PRI "}
+
+// serverHandshake detects the mode of a new connection and runs the
+// server side of the handshake if the client sent a preamble. The bytes
+// of a legacy connection are peeked, and left in r. No feature is
+// accepted on legacy connections.
+func serverHandshake(r *bufio.Reader, w io.Writer, opts *Options) (handshakeInfo, error) {
+	var none handshakeInfo
+	first, err := r.Peek(1)
+	if err != nil {
+		return none, err
+	}
+
+	if first[0] != handshakeMagic[0] {
+		// legacy connection, check that it is not an HTTP client; the
+		// header frame is longer than 3 bytes
+		if first[0] < 4 || first[0] >= 0x80 {
+			return none, nil
+		}
+		if first, err = r.Peek(4); err != nil {
+			return none, err
+		}
+		for _, m := range httpMethods {
+			if string(first) == m {
+				write(w, []byte("HTTP/1.0 400 Bad Request\r\n\r\n"+ErrNotProtorpc.Error()+"\n"), false)
+				flush(w)
+				return none, ErrNotProtorpc
+			}
+		}
+		return none, nil
+	}
+
+	var hs wire.Handshake
+	if err := readHandshake(r, &hs); err != nil {
+		return none, err
+	}
+	reply := &wire.HandshakeReply{
+		Version:      uint32(wire.Const_PROTOCOL_VERSION),
+		Features:     hs.Features & supportedFeatures,
+		StreamWindow: uint32(streamWindow(opts)),
+		ConnWindow:   uint32(connWindow(opts)),
+	}
+	if hs.Version == 0 {
+		reply.Error = fmt.Sprintf("unsupported protocol version %d", hs.Version)
+	} else if hs.Version < reply.Version {
+		reply.Version = hs.Version
+	}
+	if err := writeHandshake(w, reply); err != nil {
+		return none, err
+	}
+	if reply.Error != "" {
+		return none, fmt.Errorf("protorpc: handshake: %s", reply.Error)
+	}
+	return newHandshakeInfo(reply.Features, hs.StreamWindow, hs.ConnWindow), nil
+}
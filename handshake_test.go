@@ -0,0 +1,182 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/rpc"
+	"strings"
+	"testing"
+	"time"
+
+	msg "github.com/chai2010/protorpc/examples/message.pb"
+	wire "github.com/chai2010/protorpc/wire.pb"
+)
+
+func TestHandshake(t *testing.T) {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("EchoService", new(testEcho)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, handshake := range []bool{false, true} {
+		cliConn, srvConn := net.Pipe()
+		go srv.ServeCodec(NewServerCodec(srvConn))
+
+		codec := NewClientCodecWithOptions(cliConn, &Options{Handshake: handshake}).(*clientCodec)
+		client := rpc.NewClientWithCodec(codec)
+
+		var reply msg.EchoResponse
+		if err := client.Call("EchoService.Echo", &msg.EchoRequest{Msg: "hi"}, &reply); err != nil {
+			t.Fatalf("handshake = %v: %v", handshake, err)
+		}
+		if reply.Msg != "hi" {
+			t.Fatalf("handshake = %v: expect %q, got %q", handshake, "hi", reply.Msg)
+		}
+		want := uint64(legacyFeatures)
+		if handshake {
+			want = supportedFeatures
+		}
+		if codec.features != want {
+			t.Fatalf("handshake = %v: expect features %b, got %b", handshake, want, codec.features)
+		}
+		client.Close()
+	}
+}
+
+func TestHandshakeFeatures(t *testing.T) {
+	cliConn, srvConn := net.Pipe()
+	defer srvConn.Close()
+
+	codec := NewClientCodecWithOptions(cliConn, &Options{
+		Handshake:      true,
+		Compression:    CompressionGzip,
+		MinCompressLen: 1,
+		Checksum:       ChecksumXXHash64,
+	})
+	client := rpc.NewClientWithCodec(codec)
+	defer client.Close()
+	go client.Go("EchoService.Echo", &msg.EchoRequest{Msg: strings.Repeat("x", 100)}, new(msg.EchoResponse), nil)
+
+	// a server without gzip and xxhash64
+	var hs wire.Handshake
+	if err := readHandshake(srvConn, &hs); err != nil {
+		t.Fatal(err)
+	}
+	if hs.Version != uint32(wire.Const_PROTOCOL_VERSION) || hs.Features != supportedFeatures {
+		t.Fatalf("unexpected handshake: %v", &hs)
+	}
+	features := supportedFeatures &^ uint64(wire.Feature_FEATURE_GZIP|wire.Feature_FEATURE_XXHASH64)
+	if err := writeHandshake(srvConn, &wire.HandshakeReply{Version: hs.Version, Features: features}); err != nil {
+		t.Fatal(err)
+	}
+
+	var header wire.RequestHeader
+	if err := readRequestHeader(srvConn, DefaultOptions(), &header); err != nil {
+		t.Fatal(err)
+	}
+	if header.Compression != uint32(wire.CompressionType_COMPRESSION_NONE) {
+		t.Fatalf("expect no compression, got %d", header.Compression)
+	}
+	if header.ChecksumType != wire.ChecksumType_CHECKSUM_CRC32_IEEE {
+		t.Fatalf("expect crc32, got %v", header.ChecksumType)
+	}
+}
+
+func TestHandshakeTimeout(t *testing.T) {
+	cliConn, srvConn := net.Pipe()
+	defer srvConn.Close()
+
+	// a server older than the handshake reads the preamble as the start
+	// of a frame, and waits for the rest of it
+	go io.Copy(ioutil.Discard, srvConn)
+
+	client := NewClientWithOptions(cliConn, &Options{Handshake: true, HandshakeTimeout: 50 * time.Millisecond})
+	defer client.Close()
+	err := client.Call("EchoService.Echo", &msg.EchoRequest{Msg: "hi"}, new(msg.EchoResponse))
+	if err == nil || err.Error() != ErrHandshakeTimeout.Error() {
+		t.Fatalf("expect %v, got %v", ErrHandshakeTimeout, err)
+	}
+}
+
+func TestHandshakeLegacyEncoding(t *testing.T) {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("EchoService", new(testEcho)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, opts := range []*Options{
+		{Compression: CompressionGzip},
+		{Compression: CompressionDeflate},
+		{Checksum: ChecksumCRC32C},
+		{Checksum: ChecksumXXHash64},
+	} {
+		cliConn, srvConn := net.Pipe()
+		go srv.ServeCodec(NewServerCodec(srvConn))
+		client := NewClientWithOptions(cliConn, opts)
+
+		// old servers do not know the encoding: the call fails up front
+		err := client.Call("EchoService.Echo", &msg.EchoRequest{Msg: "hi"}, new(msg.EchoResponse))
+		if err == nil || !strings.Contains(err.Error(), "needs Options.Handshake") {
+			t.Fatalf("%+v: expect a handshake error, got %v", *opts, err)
+		}
+
+		client.Close()
+	}
+}
+
+// testConn reads from in and writes to out.
+type testConn struct {
+	in  *bytes.Reader
+	out bytes.Buffer
+}
+
+func (c *testConn) Read(p []byte) (int, error)  { return c.in.Read(p) }
+func (c *testConn) Write(p []byte) (int, error) { return c.out.Write(p) }
+func (c *testConn) Close() error                { return nil }
+
+func TestHandshakeNotProtorpc(t *testing.T) {
+	// HTTP clients get an HTTP error
+	conn := &testConn{in: bytes.NewReader([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))}
+	err := NewServerCodec(conn).ReadRequestHeader(new(rpc.Request))
+	if err != ErrNotProtorpc {
+		t.Fatalf("HTTP: expect ErrNotProtorpc, got %v", err)
+	}
+	resp, err := bufio.NewReader(&conn.out).ReadString('\n')
+	if err != nil || !strings.HasPrefix(resp, "HTTP/1.0 400 ") {
+		t.Fatalf("HTTP: unexpected response %q, %v", resp, err)
+	}
+
+	// gob clients get a clear error
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&rpc.Request{ServiceMethod: "EchoService.Echo", Seq: 1}); err != nil {
+		t.Fatal(err)
+	}
+	conn = &testConn{in: bytes.NewReader(buf.Bytes())}
+	err = NewServerCodec(conn).ReadRequestHeader(new(rpc.Request))
+	if !errors.Is(err, ErrNotProtorpc) {
+		t.Fatalf("gob: expect ErrNotProtorpc, got %v", err)
+	}
+
+	// bad protocol version
+	var preamble bytes.Buffer
+	if err := writeHandshake(&preamble, &wire.Handshake{Version: 0}); err != nil {
+		t.Fatal(err)
+	}
+	conn = &testConn{in: bytes.NewReader(preamble.Bytes())}
+	if err := NewServerCodec(conn).ReadRequestHeader(new(rpc.Request)); err == nil {
+		t.Fatalf("version 0: expect error")
+	}
+	var reply wire.HandshakeReply
+	if err := readHandshake(&conn.out, &reply); err != nil || reply.Error == "" {
+		t.Fatalf("version 0: expect error reply, got %v, %v", &reply, err)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrKeepaliveTimeout is the error of the pending calls of a connection
+// whose peer stopped answering pings.
+var ErrKeepaliveTimeout = errors.New("protorpc: keepalive timeout, the peer is not responding")
+
+// DefaultKeepaliveTimeout is the default value of Options.KeepaliveTimeout.
+const DefaultKeepaliveTimeout = 20 * time.Second
+
+// pingID is the id of ping frames. It is never the id of a call.
+const pingID = ^uint64(0)
+
+// keepalive pings the peer when the connection is idle, and calls dead
+// when the peer does not answer.
+type keepalive struct {
+	interval time.Duration
+	timeout  time.Duration
+	lastRecv int64 // unix nano time of the last frame received, atomic
+
+	ping func() error
+	dead func(err error)
+	done <-chan struct{}
+}
+
+func newKeepalive(opts *Options, ping func() error, dead func(err error), done <-chan struct{}) *keepalive {
+	k := &keepalive{
+		interval: opts.KeepaliveInterval,
+		timeout:  opts.KeepaliveTimeout,
+		ping:     ping,
+		dead:     dead,
+		done:     done,
+	}
+	if k.timeout <= 0 {
+		k.timeout = DefaultKeepaliveTimeout
+	}
+	k.received()
+	return k
+}
+
+// received records that a frame was received from the peer.
+func (k *keepalive) received() {
+	atomic.StoreInt64(&k.lastRecv, time.Now().UnixNano())
+}
+
+func (k *keepalive) idle() time.Duration {
+	return time.Duration(time.Now().UnixNano() - atomic.LoadInt64(&k.lastRecv))
+}
+
+func (k *keepalive) run() {
+	timer := time.NewTimer(k.interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+		case <-k.done:
+			return
+		}
+		if idle := k.idle(); idle < k.interval {
+			timer.Reset(k.interval - idle)
+			continue
+		}
+
+		// the ping may block on a dead connection, dead unblocks it
+		sent := time.Now().UnixNano()
+		go func() {
+			if err := k.ping(); err != nil {
+				k.dead(err)
+			}
+		}()
+
+		timer.Reset(k.timeout)
+		select {
+		case <-timer.C:
+		case <-k.done:
+			return
+		}
+		if atomic.LoadInt64(&k.lastRecv) < sent {
+			k.dead(ErrKeepaliveTimeout)
+			return
+		}
+		timer.Reset(k.interval - k.idle())
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"net/rpc"
+	"testing"
+	"time"
+
+	msg "github.com/chai2010/protorpc/examples/message.pb"
+	wire "github.com/chai2010/protorpc/wire.pb"
+)
+
+var testKeepaliveOptions = &Options{
+	Handshake:         true,
+	KeepaliveInterval: 10 * time.Millisecond,
+	KeepaliveTimeout:  50 * time.Millisecond,
+}
+
+func TestKeepalive(t *testing.T) {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("EchoService", new(testEcho)); err != nil {
+		t.Fatal(err)
+	}
+	cliConn, srvConn := net.Pipe()
+	go srv.ServeCodec(NewServerCodecWithOptions(srvConn, testKeepaliveOptions))
+	client := NewClientWithOptions(cliConn, testKeepaliveOptions)
+	defer client.Close()
+
+	// an idle healthy connection survives many intervals
+	var reply msg.EchoResponse
+	for i := 0; i < 3; i++ {
+		time.Sleep(100 * time.Millisecond)
+		if err := client.Call("EchoService.Echo", &msg.EchoRequest{Msg: "hi"}, &reply); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestKeepaliveClientTimeout(t *testing.T) {
+	cliConn, srvConn := net.Pipe()
+	defer srvConn.Close()
+	client := NewClientWithOptions(cliConn, testKeepaliveOptions)
+	defer client.Close()
+
+	// a server which accepts pings but never answers
+	go func() {
+		var hs wire.Handshake
+		if err := readHandshake(srvConn, &hs); err != nil {
+			return
+		}
+		writeHandshake(srvConn, &wire.HandshakeReply{Version: hs.Version, Features: hs.Features})
+		io.Copy(ioutil.Discard, srvConn)
+	}()
+
+	call := client.Go("EchoService.Echo", &msg.EchoRequest{Msg: "hi"}, new(msg.EchoResponse), nil)
+	select {
+	case <-call.Done:
+		if call.Error != ErrKeepaliveTimeout {
+			t.Fatalf("expect ErrKeepaliveTimeout, got %v", call.Error)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the call did not fail")
+	}
+}
+
+func TestKeepaliveServerTimeout(t *testing.T) {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("EchoService", new(testEcho)); err != nil {
+		t.Fatal(err)
+	}
+	cliConn, srvConn := net.Pipe()
+	defer cliConn.Close()
+	served := make(chan struct{})
+	go func() {
+		srv.ServeCodec(NewServerCodecWithOptions(srvConn, testKeepaliveOptions))
+		close(served)
+	}()
+
+	// a client which accepts pings but never answers
+	info, err := clientHandshake(cliConn, cliConn, &Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.features&uint64(wire.Feature_FEATURE_PING) == 0 {
+		t.Fatalf("ping not accepted: %b", info.features)
+	}
+	go io.Copy(ioutil.Discard, cliConn)
+
+	select {
+	case <-served:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the server did not close the connection")
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	wire "github.com/chai2010/protorpc/wire.pb"
+)
+
+// The default limits of Options.
+const (
+	// DefaultMaxHeaderLen is the max size of a response header, without
+	// the trailer: the limit of the trailer is added to it.
+	DefaultMaxHeaderLen = 64 << 10
+
+	// DefaultMaxBodyLen is the default value of Options.MaxBodyLen.
+	DefaultMaxBodyLen = 64 << 20
+
+	// DefaultMaxInFlightLen is the default value of Options.MaxInFlightLen.
+	DefaultMaxInFlightLen = 128 << 20
+)
+
+// The errors of a LimitError.
+var (
+	ErrHeaderTooLarge   = errors.New("protorpc: header too large")
+	ErrMetadataTooLarge = errors.New("protorpc: metadata too large")
+	ErrBodyTooLarge     = errors.New("protorpc: body too large")
+	ErrFrameTooLarge    = errors.New("protorpc: frame too large")
+	ErrInFlightTooLarge = errors.New("protorpc: too many bytes in flight")
+)
+
+// A LimitError reports a frame larger than a limit of Options. A codec
+// receiving it closes the connection.
+//
+// Use errors.Is to test the kind of limit: errors.Is(err, ErrBodyTooLarge).
+type LimitError struct {
+	Err   error // ErrHeaderTooLarge, ErrMetadataTooLarge, ...
+	Size  int64 // the received or announced size
+	Limit int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%v: %d bytes, limit %d", e.Err, e.Size, e.Limit)
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+func limitError(err error, size, limit int) error {
+	return &LimitError{Err: err, Size: int64(size), Limit: int64(limit)}
+}
+
+// requestHeaderLimit returns the max size of a request header.
+func requestHeaderLimit(opts *Options) int {
+	return maxLen(opts.MaxHeaderLen, int(wire.Const_MAX_REQUEST_HEADER_LEN)+metadataLimit(opts))
+}
+
+// responseHeaderLimit returns the max size of a response header.
+func responseHeaderLimit(opts *Options) int {
+	return maxLen(opts.MaxHeaderLen, DefaultMaxHeaderLen+metadataLimit(opts))
+}
+
+// metadataLimit returns the max size of request metadata and response trailers.
+func metadataLimit(opts *Options) int {
+	return maxLen(opts.MaxMetadataLen, int(wire.Const_MAX_METADATA_LEN))
+}
+
+// maxBodyLen returns the max size of a body, raw or compressed.
+func maxBodyLen(opts *Options) int {
+	return maxLen(opts.MaxBodyLen, DefaultMaxBodyLen)
+}
+
+// bodyLimit returns the max size of a body whose header announced headerLen.
+func bodyLimit(opts *Options, headerLen uint32) int {
+	if max := maxBodyLen(opts); headerLen == 0 || int64(headerLen) > int64(max) {
+		return max
+	}
+	return int(headerLen)
+}
+
+// checkBodyLen checks the body lengths announced by a header, before
+// the body is received or decompressed.
+func checkBodyLen(opts *Options, rawLen, compressedLen uint32) error {
+	n, max := maxUint32(rawLen, compressedLen), maxBodyLen(opts)
+	if int64(n) > int64(max) {
+		return &LimitError{Err: ErrBodyTooLarge, Size: int64(n), Limit: int64(max)}
+	}
+	return nil
+}
+
+// flightLimit counts the received body bytes a connection holds before
+// they are read: the bodies being received in chunks, the messages
+// queued on streams, and the requests waiting for the scheduler.
+type flightLimit struct {
+	n   int64 // atomic
+	max int64
+}
+
+func newFlightLimit(opts *Options) *flightLimit {
+	max := maxLen(opts.MaxInFlightLen, DefaultMaxInFlightLen)
+	if body := maxBodyLen(opts); max < body {
+		max = body
+	}
+	return &flightLimit{max: int64(max)}
+}
+
+// add counts n more bytes, it fails if they are over the limit.
+func (f *flightLimit) add(n int) error {
+	if size := atomic.AddInt64(&f.n, int64(n)); size > f.max {
+		return &LimitError{Err: ErrInFlightTooLarge, Size: size, Limit: f.max}
+	}
+	return nil
+}
+
+// sub uncounts n bytes, which were read or dropped.
+func (f *flightLimit) sub(n int) {
+	atomic.AddInt64(&f.n, -int64(n))
+}
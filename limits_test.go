@@ -0,0 +1,148 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"net/rpc"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	msg "github.com/chai2010/protorpc/examples/message.pb"
+	wire "github.com/chai2010/protorpc/wire.pb"
+	"github.com/golang/protobuf/proto"
+)
+
+type testLimits int
+
+// Fail fails with the request message.
+func (t *testLimits) Fail(args *msg.EchoRequest, reply *msg.EchoResponse) error {
+	return errors.New(args.Msg)
+}
+
+func TestLimitResponseHeader(t *testing.T) {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("LimitService", new(testLimits)); err != nil {
+		t.Fatal(err)
+	}
+	cliConn, srvConn := net.Pipe()
+	go srv.ServeCodec(NewServerCodec(srvConn))
+	client := NewClientWithOptions(cliConn, &Options{MaxHeaderLen: 1000})
+	defer client.Close()
+
+	var reply msg.EchoResponse
+	err := client.Call("LimitService.Fail", &msg.EchoRequest{Msg: strings.Repeat("x", 2000)}, &reply)
+	if !errors.Is(err, ErrHeaderTooLarge) {
+		t.Fatalf("expect ErrHeaderTooLarge, got %v", err)
+	}
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != 1000 {
+		t.Fatalf("expect a LimitError of limit 1000, got %#v", err)
+	}
+}
+
+func TestLimitSentResponseHeader(t *testing.T) {
+	client := newTestClient(t, nil, &Options{MaxHeaderLen: 1000}, func(srv *rpc.Server) error {
+		return srv.RegisterName("LimitService", new(testLimits))
+	})
+
+	// the call fails, the connection serves the next calls
+	var reply msg.EchoResponse
+	err := client.Call("LimitService.Fail", &msg.EchoRequest{Msg: strings.Repeat("x", 2000)}, &reply)
+	if s := StatusFromError(err); s.Code != CodeResourceExhausted {
+		t.Fatalf("expect ResourceExhausted, got %v", err)
+	}
+	err = client.Call("LimitService.Fail", &msg.EchoRequest{Msg: "short"}, &reply)
+	if s := StatusFromError(err); s.Code != CodeUnknown || s.Message != "short" {
+		t.Fatalf("expect the error of the call, got %v", err)
+	}
+}
+
+func TestLimitAnnouncedBody(t *testing.T) {
+	cliConn, srvConn := net.Pipe()
+	defer cliConn.Close()
+	codec := NewServerCodecWithOptions(srvConn, &Options{MaxBodyLen: 1 << 20})
+	defer codec.Close()
+
+	// the header of the second request announces a body of 4GB, the
+	// codec fails before reading it
+	go func() {
+		writeRequest(cliConn, DefaultOptions(), nil, noneCompressor{}, &wire.RequestHeader{Id: 1, Method: "LimitService.Fail"}, nil)
+		pbHeader, _ := proto.Marshal(&wire.RequestHeader{
+			Id:            2,
+			Method:        "LimitService.Fail",
+			RawRequestLen: 1<<32 - 1,
+		})
+		var fw frameWriter
+		fw.write(cliConn, pbHeader, nil)
+	}()
+	var r rpc.Request
+	if err := codec.ReadRequestHeader(&r); err != nil {
+		t.Fatal(err)
+	}
+	if err := codec.ReadRequestBody(nil); err != nil {
+		t.Fatal(err)
+	}
+	err := codec.ReadRequestHeader(&r)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Err != ErrBodyTooLarge || limitErr.Size != 1<<32-1 {
+		t.Fatalf("expect ErrBodyTooLarge, got %v", err)
+	}
+}
+
+func TestRecvBody(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 30000)
+	var buf bytes.Buffer
+	WriteFrame(&buf, data)
+	body, err := recvBody(&buf, 0)
+	if err != nil || !bytes.Equal(body, data) {
+		t.Fatalf("recvBody: %v, got %d bytes, expect %d", err, len(body), len(data))
+	}
+
+	// a frame announcing 1GB, without the data
+	buf.Reset()
+	buf.Write([]byte{0x80, 0x80, 0x80, 0x80, 0x04})
+	buf.WriteString("abc")
+	if _, err := recvBody(&buf, 0); err == nil {
+		t.Fatalf("expect a truncated frame to fail")
+	}
+	buf.Write([]byte{0x80, 0x80, 0x80, 0x80, 0x04})
+	if _, err := recvBody(&buf, 1<<20); !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("expect ErrBodyTooLarge, got %v", err)
+	}
+}
+
+func TestLimitInFlight(t *testing.T) {
+	a := newAssembler(&flightLimit{max: 100})
+	chunk := make([]byte, 60)
+	if err := a.start(1, nil, chunk, 1000, time.Time{}, false); err != nil {
+		t.Fatal(err)
+	}
+	// the bytes held by streams are counted by the stream queues
+	if err := a.start(2, nil, chunk, 1000, time.Time{}, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.start(3, nil, chunk, 1000, time.Time{}, false); !errors.Is(err, ErrInFlightTooLarge) {
+		t.Fatalf("expect ErrInFlightTooLarge, got %v", err)
+	}
+	a.remove(1)
+	a.remove(3)
+	if err := a.start(4, nil, chunk, 1000, time.Time{}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// a body started twice is rejected, its bytes stay counted once
+	if err := a.start(4, nil, chunk, 1000, time.Time{}, false); err == nil {
+		t.Fatal("expect an error for a body started twice")
+	}
+	a.remove(4)
+	if n := atomic.LoadInt64(&a.flight.n); n != 0 {
+		t.Fatalf("expect no bytes in flight, got %d", n)
+	}
+}
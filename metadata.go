@@ -0,0 +1,135 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	wire "github.com/chai2010/protorpc/wire.pb"
+)
+
+// Metadata is the metadata of a request, or the trailer of a response.
+// Keys are lower case; a key may have several values.
+type Metadata map[string][]string
+
+// NewMetadata returns the metadata of the given key, value pairs.
+// NewMetadata panics if len(kv) is odd.
+func NewMetadata(kv ...string) Metadata {
+	if len(kv)%2 == 1 {
+		panic(fmt.Sprintf("protorpc.NewMetadata: odd number of arguments: %d", len(kv)))
+	}
+	md := Metadata{}
+	for i := 0; i < len(kv); i += 2 {
+		md.Add(kv[i], kv[i+1])
+	}
+	return md
+}
+
+// Get returns the first value of key, or "" if there is none.
+func (md Metadata) Get(key string) string {
+	if v := md[strings.ToLower(key)]; len(v) != 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// Values returns all values of key.
+func (md Metadata) Values(key string) []string {
+	return md[strings.ToLower(key)]
+}
+
+// Set sets the values of key, replacing the old values.
+func (md Metadata) Set(key string, values ...string) {
+	md[strings.ToLower(key)] = values
+}
+
+// Add appends values to the values of key.
+func (md Metadata) Add(key string, values ...string) {
+	key = strings.ToLower(key)
+	md[key] = append(md[key], values...)
+}
+
+// Copy returns a deep copy of md.
+func (md Metadata) Copy() Metadata {
+	x := make(Metadata, len(md))
+	for k, v := range md {
+		x[k] = append([]string(nil), v...)
+	}
+	return x
+}
+
+// size returns the size of md checked against Options.MaxMetadataLen.
+func (md Metadata) size() int {
+	n := 0
+	for k, v := range md {
+		for _, s := range v {
+			n += len(k) + len(s)
+		}
+	}
+	return n
+}
+
+func (md Metadata) toWire() []*wire.KeyValue {
+	if len(md) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(md))
+	for k := range md {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var kvs []*wire.KeyValue
+	for _, k := range keys {
+		for _, v := range md[k] {
+			kvs = append(kvs, &wire.KeyValue{Key: k, Value: v})
+		}
+	}
+	return kvs
+}
+
+func metadataFromWire(kvs []*wire.KeyValue) Metadata {
+	if len(kvs) == 0 {
+		return nil
+	}
+	md := Metadata{}
+	for _, kv := range kvs {
+		md.Add(kv.Key, kv.Value)
+	}
+	return md
+}
+
+func wireMetadataSize(kvs []*wire.KeyValue) int {
+	n := 0
+	for _, kv := range kvs {
+		n += len(kv.Key) + len(kv.Value)
+	}
+	return n
+}
+
+type outgoingMetadataKey struct{}
+type incomingMetadataKey struct{}
+
+// NewOutgoingContext returns a copy of ctx carrying md, which CallContext
+// sends with the request.
+func NewOutgoingContext(ctx context.Context, md Metadata) context.Context {
+	return context.WithValue(ctx, outgoingMetadataKey{}, md)
+}
+
+// MetadataFromOutgoingContext returns the metadata set by NewOutgoingContext.
+func MetadataFromOutgoingContext(ctx context.Context) (Metadata, bool) {
+	md, ok := ctx.Value(outgoingMetadataKey{}).(Metadata)
+	return md, ok
+}
+
+// MetadataFromIncomingContext returns the metadata of the request whose
+// context is ctx, see RequestContext.
+func MetadataFromIncomingContext(ctx context.Context) (Metadata, bool) {
+	md, ok := ctx.Value(incomingMetadataKey{}).(Metadata)
+	return md, ok
+}
@@ -0,0 +1,92 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc_test
+
+import (
+	"context"
+	"net/rpc"
+	"strings"
+	"testing"
+
+	"github.com/chai2010/protorpc"
+	msg "github.com/chai2010/protorpc/examples/message.pb"
+)
+
+type MetadataService int
+
+// Echo replies with the tenant of the request, and sends it back in the trailer.
+func (t *MetadataService) Echo(args *msg.EchoRequest, reply *msg.EchoResponse) error {
+	ctx := protorpc.RequestContext(args)
+	md, ok := protorpc.MetadataFromIncomingContext(ctx)
+	if !ok {
+		return protorpc.Errorf(protorpc.CodeInternal, "no request context")
+	}
+	reply.Msg = args.Msg + ":" + md.Get("Tenant")
+	return protorpc.SetTrailer(ctx, protorpc.NewMetadata(
+		"tenant", md.Get("tenant"),
+		"locale", strings.Join(md.Values("locale"), ","),
+	))
+}
+
+// Trailer sends the request message in the trailer.
+func (t *MetadataService) Trailer(args *msg.EchoRequest, reply *msg.EchoResponse) error {
+	return protorpc.SetTrailer(protorpc.RequestContext(args), protorpc.NewMetadata("data", args.Msg))
+}
+
+func registerMetadataService(srv *rpc.Server) error {
+	return srv.RegisterName("MetadataService", new(MetadataService))
+}
+
+func TestMetadata(t *testing.T) {
+	client := newTestClient(t, nil, nil, registerMetadataService)
+
+	md := protorpc.NewMetadata("Tenant", "acme", "locale", "en", "locale", "fr")
+	ctx := protorpc.NewOutgoingContext(context.Background(), md)
+
+	var reply msg.EchoResponse
+	var trailer protorpc.Metadata
+	err := protorpc.CallContext(ctx, client, "MetadataService.Echo", &msg.EchoRequest{Msg: "hi"}, &reply, protorpc.Trailer(&trailer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Msg != "hi:acme" {
+		t.Fatalf("expect %q, got %q", "hi:acme", reply.Msg)
+	}
+	if trailer.Get("tenant") != "acme" || trailer.Get("locale") != "en,fr" {
+		t.Fatalf("unexpected trailer: %v", trailer)
+	}
+
+	// plain calls have no metadata
+	if err := client.Call("MetadataService.Echo", &msg.EchoRequest{Msg: "hi"}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Msg != "hi:" {
+		t.Fatalf("expect %q, got %q", "hi:", reply.Msg)
+	}
+
+	// args are not left in the call registry
+	if ctx := protorpc.RequestContext(&msg.EchoRequest{}); ctx != context.Background() {
+		t.Fatalf("expect background context")
+	}
+}
+
+func TestMetadataLimit(t *testing.T) {
+	client := newTestClient(t, nil, &protorpc.Options{MaxMetadataLen: 100}, registerMetadataService)
+
+	var reply msg.EchoResponse
+	big := strings.Repeat("x", 200)
+
+	// the client refuses to send metadata larger than its limit
+	ctx := protorpc.NewOutgoingContext(context.Background(), protorpc.NewMetadata("tenant", strings.Repeat(big, 100)))
+	if err := protorpc.CallContext(ctx, client, "MetadataService.Echo", &msg.EchoRequest{}, &reply); err == nil {
+		t.Fatalf("expect metadata too large error")
+	}
+
+	// the server refuses to send a trailer larger than its limit
+	err := client.Call("MetadataService.Trailer", &msg.EchoRequest{Msg: big}, &reply)
+	if s := protorpc.StatusFromError(err); s.Code != protorpc.CodeInternal {
+		t.Fatalf("expect internal error, got %v", err)
+	}
+}
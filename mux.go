@@ -0,0 +1,613 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	wire "github.com/chai2010/protorpc/wire.pb"
+	"github.com/golang/protobuf/proto"
+)
+
+// chunkLen is the max size of the body chunks sent on a connection with
+// the mux feature.
+const chunkLen = 16 << 10
+
+var (
+	// errMuxClosed is returned by mux.send after the connection is closed.
+	errMuxClosed = errors.New("protorpc: connection closed")
+
+	// errMuxDropped is returned by mux.send if the message is dropped
+	// before it is written, because its call is done.
+	errMuxDropped = errors.New("protorpc: call done")
+)
+
+// muxMsg is a header and a body queued for sending.
+type muxMsg struct {
+	id     uint64
+	header proto.Message // *wire.RequestHeader or *wire.ResponseHeader
+	body   []byte
+	sent   int  // body bytes sent
+	final  bool // the last message of id, its window is dropped once sent
+
+	done     chan error // receives the result of a waited message
+	notified bool
+}
+
+// notify sends the result of msg once, the mux is locked.
+func (msg *muxMsg) notify(err error) {
+	if msg.done != nil && !msg.notified {
+		msg.notified = true
+		msg.done <- err
+	}
+}
+
+// mux sends the frames of a connection from a goroutine, so that codecs
+// never block on the connection while holding net/rpc locks.
+//
+// With the mux feature, bodies are split into chunks which are
+// interleaved across ids, and sent within the windows granted by the
+// peer. The frames of an id are sent in order.
+type mux struct {
+	w      io.Writer
+	c      io.Closer // closed when writing fails
+	client bool      // sends request headers, or response headers
+
+	flow       bool  // the mux feature is accepted
+	recvStream int64 // the receive windows announced to the peer
+	recvConn   int64
+
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	control []*muxMsg // not flow controlled, sent first
+	queue   []*muxMsg // the messages of all ids, in order for each id
+	next    int       // round robin position in queue
+	dirty   bool      // frames were written since the last flush
+	idle    bool      // nothing is being written
+	err     error     // the connection is closed or broken
+	stalled error     // the peer grants no more window
+
+	// used by run only
+	hbuf *proto.Buffer // marshals the headers
+	fw   frameWriter
+
+	// With Options.WriteBatchLatency, the written frames are flushed by
+	// timer, at most latency after the first of them.
+	latency  time.Duration
+	timer    *time.Timer
+	armed    bool // the timer is running
+	flushDue bool // the timer fired
+
+	// send windows granted by the peer
+	sendStream int64 // initial window of an id
+	sendConn   int64
+	windows    map[uint64]int64
+
+	// received bytes not granted back to the peer yet
+	credits    map[uint64]int64
+	connCredit int64
+}
+
+// newMux returns a mux sending to w, without flow control until the
+// handshake enables it.
+func newMux(w io.Writer, c io.Closer, client bool, opts *Options) *mux {
+	m := &mux{
+		w:          w,
+		c:          c,
+		client:     client,
+		recvStream: streamWindow(opts),
+		recvConn:   connWindow(opts),
+		windows:    make(map[uint64]int64),
+		credits:    make(map[uint64]int64),
+		hbuf:       proto.NewBuffer(nil),
+		latency:    opts.WriteBatchLatency,
+	}
+	m.cond = sync.NewCond(&m.mutex)
+	go m.run()
+	return m
+}
+
+// enableFlow turns on chunking and flow control, with the windows
+// announced by the peer. It is called before anything is sent.
+func (m *mux) enableFlow(info handshakeInfo) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.flow = true
+	m.sendStream = info.streamWindow
+	m.sendConn = info.connWindow
+}
+
+// streamWindow returns the receive window of each id.
+func streamWindow(opts *Options) int64 {
+	return int64(maxLen(opts.StreamWindow, int(wire.Const_DEFAULT_STREAM_WINDOW)))
+}
+
+// connWindow returns the receive window of the connection.
+func connWindow(opts *Options) int64 {
+	return int64(maxLen(opts.ConnWindow, int(wire.Const_DEFAULT_CONN_WINDOW)))
+}
+
+// peerWindow returns a window announced in a handshake.
+func peerWindow(window uint32, def wire.Const) int64 {
+	if window == 0 {
+		return int64(def)
+	}
+	return int64(window)
+}
+
+// send queues header and body. If wait is set, send waits until they
+// are written. final is set for the last message of id.
+func (m *mux) send(id uint64, header proto.Message, body []byte, final, wait bool) error {
+	msg := &muxMsg{id: id, header: header, body: body, final: final}
+	if wait {
+		msg.done = make(chan error, 1)
+	}
+
+	m.mutex.Lock()
+	if m.err != nil {
+		err := m.err
+		m.mutex.Unlock()
+		return err
+	}
+	if _, ok := m.windows[id]; !ok && m.flow && len(body) != 0 {
+		m.windows[id] = m.sendStream
+	}
+	if final {
+		delete(m.credits, id)
+	}
+	m.queue = append(m.queue, msg)
+	m.cond.Broadcast()
+	m.mutex.Unlock()
+
+	if !wait {
+		return nil
+	}
+	return <-msg.done
+}
+
+// sendControl queues a frame with an empty body, sent before the queued
+// messages.
+func (m *mux) sendControl(header proto.Message) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.err != nil {
+		return m.err
+	}
+	m.control = append(m.control, &muxMsg{header: header})
+	m.cond.Broadcast()
+	return nil
+}
+
+// drop removes the queued messages of id, whose call is done, and its
+// window. The rest of a message being sent is not sent.
+func (m *mux) drop(id uint64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	queue := m.queue[:0]
+	for _, msg := range m.queue {
+		if msg.id != id {
+			queue = append(queue, msg)
+		} else {
+			msg.notify(errMuxDropped)
+		}
+	}
+	for i := len(queue); i < len(m.queue); i++ {
+		m.queue[i] = nil
+	}
+	m.queue = queue
+	m.next = 0
+	delete(m.windows, id)
+	delete(m.credits, id)
+}
+
+// finish drops the window of id once its queued messages are sent.
+func (m *mux) finish(id uint64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.credits, id)
+	for i := len(m.queue) - 1; i >= 0; i-- {
+		if m.queue[i].id == id {
+			m.queue[i].final = true
+			return
+		}
+	}
+	delete(m.windows, id)
+}
+
+// update adds the window granted by the peer.
+func (m *mux) update(id uint64, window, conn uint32) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if w, ok := m.windows[id]; ok && window != 0 {
+		m.windows[id] = w + int64(window)
+	}
+	m.sendConn += int64(conn)
+	m.cond.Broadcast()
+}
+
+// credit grants n received bytes back to the peer. stream is not set
+// for the bytes of unknown ids, only the connection window is granted.
+func (m *mux) credit(id uint64, n int, stream bool) {
+	if !m.flow || n == 0 {
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var window, conn int64
+	if stream {
+		window = m.credits[id] + int64(n)
+		if window >= m.recvStream/4 {
+			delete(m.credits, id)
+		} else {
+			m.credits[id] = window
+			window = 0
+		}
+	}
+	m.connCredit += int64(n)
+	if m.connCredit >= m.recvConn/4 {
+		conn, m.connCredit = m.connCredit, 0
+	}
+	if window == 0 && conn == 0 {
+		return
+	}
+
+	var header proto.Message
+	if m.client {
+		header = &wire.RequestHeader{Id: id, FrameType: wire.FrameType_FRAME_WINDOW_UPDATE, Window: uint32(window), ConnWindow: uint32(conn)}
+	} else {
+		header = &wire.ResponseHeader{Id: id, FrameType: wire.FrameType_FRAME_WINDOW_UPDATE, Window: uint32(window), ConnWindow: uint32(conn)}
+	}
+	m.control = append(m.control, &muxMsg{header: header})
+	m.cond.Broadcast()
+}
+
+// close stops the mux, the queued messages are not sent.
+func (m *mux) close(err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.closeLocked(err)
+}
+
+func (m *mux) closeLocked(err error) {
+	if m.err != nil {
+		return
+	}
+	m.err = err
+	for _, msg := range m.queue {
+		msg.notify(err)
+	}
+	m.queue, m.control = nil, nil
+	if m.timer != nil {
+		m.timer.Stop()
+	}
+	m.cond.Broadcast()
+}
+
+// stall fails with err the messages waiting for window, and those which
+// would wait later: the peer does not read the connection anymore, and
+// grants no more window. The other messages are still sent.
+func (m *mux) stall(err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.stalled == nil {
+		m.stalled = err
+	}
+	m.cond.Broadcast()
+}
+
+// dropStalled removes the messages waiting for window after stall.
+func (m *mux) dropStalled() {
+	queue := m.queue[:0]
+	sending := make(map[uint64]bool) // ids whose first message is kept
+	for _, msg := range m.queue {
+		if !sending[msg.id] && msg.sent < len(msg.body) && (m.windows[msg.id] <= 0 || m.sendConn <= 0) {
+			msg.notify(m.stalled)
+			continue
+		}
+		sending[msg.id] = true
+		queue = append(queue, msg)
+	}
+	if len(queue) == len(m.queue) {
+		return
+	}
+	for i := len(queue); i < len(m.queue); i++ {
+		m.queue[i] = nil
+	}
+	m.queue = queue
+	m.next = 0
+}
+
+// wait waits until the queued messages are written and flushed, or the
+// mux is closed.
+func (m *mux) wait() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for m.err == nil && !(m.idle && len(m.queue) == 0 && len(m.control) == 0) {
+		m.cond.Wait()
+	}
+}
+
+// pick waits for the next chunk to send: the message and the chunk
+// length. It returns nil when the mux is closed.
+func (m *mux) pick() (*muxMsg, int) {
+	for {
+		if m.err != nil {
+			return nil, 0
+		}
+		if m.stalled != nil && m.flow {
+			m.dropStalled()
+		}
+		if len(m.control) != 0 {
+			msg := m.control[0]
+			m.control[0] = nil
+			m.control = m.control[1:]
+			return msg, 0
+		}
+
+	scan:
+		for k := 0; k < len(m.queue); k++ {
+			i := (m.next + k) % len(m.queue)
+			msg := m.queue[i]
+			for j := 0; j < i; j++ {
+				if m.queue[j].id == msg.id {
+					continue scan // not the first message of its id
+				}
+			}
+
+			n := len(msg.body) - msg.sent
+			if m.flow && n != 0 {
+				n = int(min64(int64(minInt(n, chunkLen)), min64(m.windows[msg.id], m.sendConn)))
+				if n <= 0 {
+					continue
+				}
+				m.windows[msg.id] -= int64(n)
+				m.sendConn -= int64(n)
+			}
+
+			msg.sent += n
+			if msg.sent == len(msg.body) {
+				copy(m.queue[i:], m.queue[i+1:])
+				m.queue[len(m.queue)-1] = nil
+				m.queue = m.queue[:len(m.queue)-1]
+				m.next = i
+				if msg.final {
+					delete(m.windows, msg.id)
+				}
+			} else {
+				m.next = i + 1
+			}
+			return msg, n
+		}
+
+		// nothing to send, flush the written frames before waiting
+		if m.dirty && m.latency > 0 && !m.flushDue {
+			// batch the frames of the next messages
+			if !m.armed {
+				m.armed = true
+				if m.timer == nil {
+					m.timer = time.AfterFunc(m.latency, m.flushTimeout)
+				} else {
+					m.timer.Reset(m.latency)
+				}
+			}
+			m.cond.Wait()
+			continue
+		}
+		if m.dirty {
+			m.dirty = false
+			m.flushDue = false
+			m.mutex.Unlock()
+			err := flush(m.w)
+			m.mutex.Lock()
+			if err != nil {
+				m.fail(err)
+			}
+			continue
+		}
+		m.idle = true
+		m.cond.Broadcast()
+		m.cond.Wait()
+		m.idle = false
+	}
+}
+
+func (m *mux) run() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for {
+		msg, n := m.pick()
+		if msg == nil {
+			return
+		}
+
+		m.mutex.Unlock()
+		err := m.write(msg, n)
+		m.mutex.Lock()
+
+		m.dirty = true
+		if err != nil {
+			m.fail(err)
+		}
+		if msg.sent == len(msg.body) {
+			// the body is a pooled buffer, only released once written:
+			// a dropped message may still be in write
+			putBuffer(msg.body)
+			msg.notify(err)
+		}
+	}
+}
+
+// flushTimeout ends the batching of the written frames.
+func (m *mux) flushTimeout() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.armed = false
+	m.flushDue = true
+	m.cond.Broadcast()
+}
+
+// fail breaks the connection after a write error.
+func (m *mux) fail(err error) {
+	if m.err != nil {
+		return
+	}
+	m.closeLocked(err)
+	m.c.Close()
+}
+
+// write sends the next n bytes of the body of msg, with the header of
+// msg for the first chunk.
+func (m *mux) write(msg *muxMsg, n int) error {
+	start := msg.sent - n
+	more := msg.sent < len(msg.body)
+
+	header := msg.header
+	if start != 0 {
+		if m.client {
+			header = &wire.RequestHeader{Id: msg.id, FrameType: wire.FrameType_FRAME_CHUNK, More: more}
+		} else {
+			header = &wire.ResponseHeader{Id: msg.id, FrameType: wire.FrameType_FRAME_CHUNK, More: more}
+		}
+	} else {
+		switch h := header.(type) {
+		case *wire.RequestHeader:
+			h.More = more
+		case *wire.ResponseHeader:
+			h.More = more
+		}
+	}
+
+	m.hbuf.Reset()
+	if err := m.hbuf.Marshal(header); err != nil {
+		return err
+	}
+	return m.fw.write(m.w, m.hbuf.Bytes(), msg.body[start:msg.sent])
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// chunkedBody is a body being received in chunks.
+type chunkedBody struct {
+	first   interface{} // the first frame, set by the codec
+	body    []byte
+	limit   int       // the max size of the body
+	time    time.Time // when the first frame was read
+	counted int       // the bytes counted in the flight limit, protected by the assembler
+	held    bool      // the bytes are counted by a stream queue instead
+}
+
+// assembler assembles the bodies received in chunks, by id.
+type assembler struct {
+	mutex  sync.Mutex
+	bodies map[uint64]*chunkedBody
+	flight *flightLimit // counts the bodies being assembled
+}
+
+func newAssembler(flight *flightLimit) *assembler {
+	return &assembler{bodies: make(map[uint64]*chunkedBody), flight: flight}
+}
+
+// start begins the body of id with its first frame and chunk, read at t.
+// The bytes of a message held by a stream queue are counted by the queue.
+// A body started again before its last chunk is a protocol error.
+func (a *assembler) start(id uint64, first interface{}, chunk []byte, limit int, t time.Time, held bool) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if _, ok := a.bodies[id]; ok {
+		return fmt.Errorf("protorpc: body %d started again before its last chunk", id)
+	}
+	b := &chunkedBody{first: first, body: chunk, limit: limit, time: t, held: held}
+	a.bodies[id] = b
+	return a.count(b, len(chunk))
+}
+
+func (a *assembler) count(b *chunkedBody, n int) error {
+	if b.held {
+		return nil
+	}
+	b.counted += n
+	return a.flight.add(n)
+}
+
+// remove drops the body of id, whose call is done.
+func (a *assembler) remove(id uint64) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if b, ok := a.bodies[id]; ok {
+		delete(a.bodies, id)
+		a.flight.sub(b.counted)
+	}
+}
+
+// recv receives the body of a FRAME_CHUNK header, and returns the body
+// of id (nil if unknown, the chunk is dropped) and the chunk length. The
+// body is complete when more is not set.
+func (a *assembler) recv(r io.Reader, opts *Options, id uint64, more bool) (*chunkedBody, int, error) {
+	a.mutex.Lock()
+	b := a.bodies[id]
+	a.mutex.Unlock()
+
+	limit := bodyLimit(opts, 0)
+	if b != nil {
+		limit = b.limit - len(b.body)
+		if limit <= 0 {
+			limit = 1 // any chunk is too large
+		}
+	}
+	chunk, err := recvBody(r, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer putBuffer(chunk)
+	if b == nil {
+		return nil, len(chunk), nil
+	}
+	if len(b.body)+len(chunk) > b.limit {
+		return nil, 0, limitError(ErrBodyTooLarge, len(b.body)+len(chunk), b.limit)
+	}
+
+	// only the reader of the connection changes the body
+	b.body = appendBuffer(b.body, chunk)
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.bodies[id] != b {
+		return nil, len(chunk), nil // removed while received
+	}
+	if err := a.count(b, len(chunk)); err != nil {
+		return nil, 0, err
+	}
+	if !more {
+		delete(a.bodies, id)
+		a.flight.sub(b.counted)
+	}
+	return b, len(chunk), nil
+}
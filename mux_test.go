@@ -0,0 +1,253 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/rpc"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	msg "github.com/chai2010/protorpc/examples/message.pb"
+	wire "github.com/chai2010/protorpc/wire.pb"
+	"github.com/golang/protobuf/proto"
+)
+
+func TestMuxInterleave(t *testing.T) {
+	cliConn, srvConn := net.Pipe()
+	defer cliConn.Close()
+	defer srvConn.Close()
+
+	m := newMux(cliConn, cliConn, true, DefaultOptions())
+	m.enableFlow(handshakeInfo{streamWindow: 1 << 20, connWindow: 1 << 20})
+	defer m.close(errMuxClosed)
+
+	// the first chunk of the large body blocks the writer until read
+	large := make([]byte, 4*chunkLen)
+	m.send(1, &wire.RequestHeader{Id: 1}, large, true, false)
+	time.Sleep(10 * time.Millisecond)
+	m.send(3, &wire.RequestHeader{Id: 3}, []byte("small"), true, false)
+
+	var ids []uint64
+	for len(ids) < 5 {
+		var header wire.RequestHeader
+		if err := readRequestHeader(srvConn, DefaultOptions(), &header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := recvFrame(srvConn, 0); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, header.Id)
+	}
+	if ids[1] != 3 {
+		t.Fatalf("the small body is not interleaved: %v", ids)
+	}
+}
+
+type testMux struct {
+	sent int32
+}
+
+// Flood sends 100 messages of 8KB.
+func (t *testMux) Flood(stream *ServerStream, reply *StreamReply) error {
+	data := strings.Repeat("x", 8<<10)
+	for i := 0; i < 100; i++ {
+		if err := stream.SendMsg(&msg.EchoResponse{Msg: data}); err != nil {
+			return err
+		}
+		atomic.AddInt32(&t.sent, 1)
+	}
+	return nil
+}
+
+func (t *testMux) Echo(args *msg.EchoRequest, reply *msg.EchoResponse) error {
+	reply.Msg = args.Msg
+	return nil
+}
+
+func (t *testMux) register(srv *rpc.Server) error {
+	return srv.RegisterName("MuxService", t)
+}
+
+func TestMuxLargeBody(t *testing.T) {
+	client := newTestClient(t, &Options{Handshake: true}, nil, new(testMux).register)
+
+	// larger than the connection window
+	args := &msg.EchoRequest{Msg: strings.Repeat("abc", 1<<20)}
+	var reply msg.EchoResponse
+	if err := client.Call("MuxService.Echo", args, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Msg != args.Msg {
+		t.Fatalf("reply of %d bytes, expect %d", len(reply.Msg), len(args.Msg))
+	}
+}
+
+func TestMuxSlowReader(t *testing.T) {
+	service := new(testMux)
+	client := newTestClient(t, &Options{Handshake: true}, nil, service.register)
+
+	stream, err := NewStream(context.Background(), client, "MuxService.Flood")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.CloseSend()
+
+	// the stream is not read: the server is stopped by the window of
+	// the stream, the other calls proceed
+	time.Sleep(50 * time.Millisecond)
+	var reply msg.EchoResponse
+	if err := client.Call("MuxService.Echo", &msg.EchoRequest{Msg: "hi"}, &reply); err != nil || reply.Msg != "hi" {
+		t.Fatalf("Echo: %v %q", err, reply.Msg)
+	}
+	limit := int32(2*wire.Const_DEFAULT_STREAM_WINDOW/(8<<10)) + 1
+	if n := atomic.LoadInt32(&service.sent); n > limit {
+		t.Fatalf("%d messages sent to a slow reader, expect at most %d", n, limit)
+	}
+
+	n := 0
+	for {
+		var m msg.EchoResponse
+		err := stream.RecvMsg(&m)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if n != 100 {
+		t.Fatalf("received %d messages, expect 100", n)
+	}
+}
+
+func TestMuxHangUp(t *testing.T) {
+	srv := rpc.NewServer()
+	if err := new(testMux).register(srv); err != nil {
+		t.Fatal(err)
+	}
+	cliConn, srvConn := net.Pipe()
+	served := make(chan struct{})
+	go func() {
+		srv.ServeCodec(NewServerCodec(srvConn))
+		close(served)
+	}()
+
+	client := NewClientWithOptions(cliConn, &Options{Handshake: true})
+	defer client.Close()
+	stream, err := NewStream(context.Background(), client, "MuxService.Flood")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.CloseSend()
+
+	// the client stops reading, and hangs up while the server waits for
+	// the window of the stream
+	time.Sleep(50 * time.Millisecond)
+	cliConn.Close()
+	select {
+	case <-served:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the server is blocked on the window of a client which hung up")
+	}
+}
+
+func TestMuxStall(t *testing.T) {
+	cliConn, srvConn := net.Pipe()
+	defer cliConn.Close()
+	defer srvConn.Close()
+	go io.Copy(ioutil.Discard, srvConn)
+
+	m := newMux(cliConn, cliConn, true, DefaultOptions())
+	m.enableFlow(handshakeInfo{streamWindow: chunkLen, connWindow: 1 << 20})
+	defer m.close(errMuxClosed)
+
+	// the second chunk waits for window, the small body is still sent
+	done := make(chan error, 1)
+	go func() { done <- m.send(1, &wire.RequestHeader{Id: 1}, make([]byte, 2*chunkLen), true, true) }()
+	time.Sleep(10 * time.Millisecond)
+	m.stall(errMuxClosed)
+	if err := <-done; err != errMuxClosed {
+		t.Fatalf("expect %v, got %v", errMuxClosed, err)
+	}
+	if err := m.send(3, &wire.RequestHeader{Id: 3}, []byte("small"), true, true); err != nil {
+		t.Fatal(err)
+	}
+	m.wait()
+}
+
+func TestMuxLegacy(t *testing.T) {
+	cliConn, srvConn := net.Pipe()
+	defer cliConn.Close()
+	defer srvConn.Close()
+
+	// without the mux feature, bodies are sent in one frame
+	m := newMux(cliConn, cliConn, true, DefaultOptions())
+	defer m.close(errMuxClosed)
+	large := make([]byte, 4*chunkLen)
+	go m.send(1, &wire.RequestHeader{Id: 1}, large, true, true)
+
+	var header wire.RequestHeader
+	if err := readRequestHeader(srvConn, DefaultOptions(), &header); err != nil {
+		t.Fatal(err)
+	}
+	body, err := recvFrame(srvConn, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.More || len(body) != len(large) {
+		t.Fatalf("got a body of %d bytes, more %v", len(body), header.More)
+	}
+	if !proto.Equal(&header, &wire.RequestHeader{Id: 1}) {
+		t.Fatalf("unexpected header %v", &header)
+	}
+}
+
+// countConn counts the writes to a connection.
+type countConn struct {
+	net.Conn
+	writes int32
+}
+
+func (c *countConn) Write(p []byte) (int, error) {
+	atomic.AddInt32(&c.writes, 1)
+	return c.Conn.Write(p)
+}
+
+func TestMuxWriteBatch(t *testing.T) {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("MuxService", new(testMux)); err != nil {
+		t.Fatal(err)
+	}
+	cliConn, srvConn := net.Pipe()
+	conn := &countConn{Conn: srvConn}
+	go srv.ServeCodec(NewServerCodecWithOptions(conn, &Options{WriteBatchLatency: 20 * time.Millisecond}))
+	client := NewClient(cliConn)
+	defer client.Close()
+
+	const calls = 50
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var reply msg.EchoResponse
+			if err := client.Call("MuxService.Echo", &msg.EchoRequest{Msg: "hi"}, &reply); err != nil || reply.Msg != "hi" {
+				t.Errorf("Echo: %v %q", err, reply.Msg)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&conn.writes); n >= calls/2 {
+		t.Fatalf("%d writes for %d responses, expect them batched", n, calls)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/rpc"
+	"testing"
+	"time"
+
+	msg "github.com/chai2010/protorpc/examples/message.pb"
+	wire "github.com/chai2010/protorpc/wire.pb"
+)
+
+type testOneWay struct {
+	calls chan string
+}
+
+// Record fails if the message is "fail".
+func (t *testOneWay) Record(args *msg.EchoRequest, reply *msg.EchoResponse) error {
+	t.calls <- args.Msg
+	if args.Msg == "fail" {
+		return errors.New("record failed")
+	}
+	reply.Msg = args.Msg
+	return nil
+}
+
+func (t *testOneWay) register(srv *rpc.Server) error {
+	return srv.RegisterName("OneWayService", t)
+}
+
+func TestOneWay(t *testing.T) {
+	oneWay := &testOneWay{calls: make(chan string, 10)}
+	client := newTestClient(t, &Options{Handshake: true}, nil, oneWay.register)
+	ctx := context.Background()
+
+	if err := OneWay(ctx, client, "OneWayService.Record", &msg.EchoRequest{Msg: "abc"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-oneWay.calls; got != "abc" {
+		t.Fatalf("expected = %q, got = %q", "abc", got)
+	}
+
+	// the connection serves the next calls
+	var reply msg.EchoResponse
+	if err := CallContext(ctx, client, "OneWayService.Record", &msg.EchoRequest{Msg: "def"}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	<-oneWay.calls
+
+	// the errors of the client are returned
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := OneWay(canceled, client, "OneWayService.Record", &msg.EchoRequest{}); StatusFromError(err).Code != CodeCanceled {
+		t.Fatalf("expected Canceled, got %v", err)
+	}
+	client.Close()
+	if err := OneWay(ctx, client, "OneWayService.Record", &msg.EchoRequest{}); err != rpc.ErrShutdown {
+		t.Fatalf("expected rpc.ErrShutdown, got %v", err)
+	}
+}
+
+func TestOneWayPending(t *testing.T) {
+	cliConn, srvConn := net.Pipe()
+	defer srvConn.Close()
+	go func() {
+		r := bufio.NewReader(srvConn)
+		for {
+			if _, err := ReadFrame(r, nil, 1<<20); err != nil {
+				return
+			}
+		}
+	}()
+	codec := NewClientCodec(cliConn).(*clientCodec)
+	client := rpc.NewClientWithCodec(codec)
+	defer client.Close()
+
+	for i := 0; i < 10; i++ {
+		if err := OneWay(context.Background(), client, "OneWayService.Record", &msg.EchoRequest{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	codec.mutex.Lock()
+	n := len(codec.pending)
+	codec.mutex.Unlock()
+	if n != 0 {
+		t.Fatalf("expected no pending request, got %d", n)
+	}
+}
+
+func TestOneWayServer(t *testing.T) {
+	oneWay := &testOneWay{calls: make(chan string, 10)}
+	errs := make(chan string, 10)
+	cliConn := dialTestServer(t, &Options{
+		OneWayErrorHandler: func(serviceMethod string, err error) {
+			errs <- serviceMethod + ": " + err.Error()
+		},
+	}, oneWay.register)
+	defer cliConn.Close()
+
+	opts := DefaultOptions()
+	for i, method := range []string{"OneWayService.Record", "OneWayService.Record", "OneWayService.Unknown", "OneWayService.Record"} {
+		header := &wire.RequestHeader{Id: uint64(i + 1), Method: method, OneWay: i < 3}
+		args := &msg.EchoRequest{Msg: "abc"}
+		if i == 1 {
+			args.Msg = "fail"
+		}
+		if err := writeRequest(cliConn, opts, nil, noneCompressor{}, header, args); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// only the last request gets a response
+	cliConn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var header wire.ResponseHeader
+	if err := readResponseHeader(cliConn, opts, &header); err != nil {
+		t.Fatal(err)
+	}
+	if header.Id != 4 || header.Error != "" {
+		t.Fatalf("expected the response of request 4, got = %v", &header)
+	}
+
+	got := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			got[err] = true
+		case <-time.After(5 * time.Second):
+			t.Fatal("the error handler was not called")
+		}
+	}
+	if !got["OneWayService.Record: record failed"] || len(got) != 2 {
+		t.Fatalf("unexpected errors %v", got)
+	}
+}
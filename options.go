@@ -0,0 +1,203 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"bufio"
+	"io"
+	"time"
+)
+
+// Options configures a single Protobuf-RPC client or server codec.
+//
+// Each codec keeps its own copy of the options, so connections in the
+// same process may use different settings. A nil *Options means
+// DefaultOptions().
+type Options struct {
+	// Compression is the name of the compressor used for request bodies,
+	// see RegisterCompressor. The server always replies with the
+	// compressor of the request. Without Handshake, only snappy and none
+	// are allowed, other compressors fail the calls.
+	Compression string
+
+	// Serialization is the name of the serializer of request messages,
+	// see RegisterSerializer. Empty means SerializationProto. The server
+	// always replies with the serializer of the request.
+	Serialization string
+
+	// MinCompressLen is the size below which bodies are sent uncompressed.
+	MinCompressLen int
+
+	// AdaptiveCompression turns compression off for a method while the
+	// compressed bodies of the method are not much smaller than the raw ones.
+	AdaptiveCompression bool
+
+	// Checksum is the name of the checksum algorithm protecting request
+	// bodies (ChecksumCRC32, ...). The server always replies with the
+	// checksum algorithm of the request. Without Handshake, only crc32
+	// and none are allowed, other algorithms fail the calls.
+	Checksum string
+
+	// MaxHeaderLen limits the size of a received header.
+	// Zero means the protocol default: wire.Const_MAX_REQUEST_HEADER_LEN
+	// plus the metadata limit for request headers, DefaultMaxHeaderLen
+	// plus the metadata limit for response headers.
+	//
+	// A received frame over MaxHeaderLen, MaxMetadataLen, MaxBodyLen or
+	// MaxInFlightLen fails with a *LimitError and closes the connection.
+	// A response header over MaxHeaderLen is not sent: its call fails
+	// with CodeResourceExhausted.
+	MaxHeaderLen int
+
+	// MaxMetadataLen limits the size of request metadata and response
+	// trailers, sent or received. Zero means wire.Const_MAX_METADATA_LEN.
+	MaxMetadataLen int
+
+	// MaxBodyLen limits the size of a received body, compressed or
+	// decompressed. Zero means DefaultMaxBodyLen.
+	MaxBodyLen int
+
+	// MaxInFlightLen limits the received body bytes a connection holds
+	// before they are read: the bodies being received in chunks, the
+	// messages queued on streams and the requests waiting for the
+	// Scheduler. Zero means DefaultMaxInFlightLen, it is at least
+	// MaxBodyLen.
+	MaxInFlightLen int
+
+	// Handshake makes the client start the connection with a preamble
+	// negotiating the protocol version and features. Servers always
+	// accept connections with or without preamble, but servers older
+	// than the preamble do not understand it.
+	//
+	// Cancel and ping frames are only sent to servers which accepted
+	// them in the handshake, and streams need the handshake.
+	Handshake bool
+
+	// HandshakeTimeout is the time to wait for the answer to the
+	// preamble. The connection is then closed, and the calls fail with
+	// ErrHandshakeTimeout. Zero means DefaultHandshakeTimeout.
+	HandshakeTimeout time.Duration
+
+	// KeepaliveInterval is the idle time after which the peer is pinged.
+	// If the peer does not answer within KeepaliveTimeout, the connection
+	// is closed and its pending calls fail with ErrKeepaliveTimeout.
+	// Zero means no keepalive. Only peers which negotiated the ping
+	// feature in the handshake are pinged.
+	KeepaliveInterval time.Duration
+
+	// KeepaliveTimeout is the time to wait for an answer to a ping.
+	// Zero means DefaultKeepaliveTimeout.
+	KeepaliveTimeout time.Duration
+
+	// StreamWindow and ConnWindow are the bytes the peer may send for
+	// each call or stream, and for the whole connection, before it waits
+	// for more window. They are used on connections whose handshake
+	// accepted the mux feature: bodies are then sent in chunks, and a
+	// large body does not block the other calls. Zero means
+	// DEFAULT_STREAM_WINDOW and DEFAULT_CONN_WINDOW of the wire package.
+	StreamWindow int
+	ConnWindow   int
+
+	// ReadBufferSize is the size of the buffer the connection is read
+	// through. Zero means DefaultReadBufferSize.
+	ReadBufferSize int
+
+	// WriteBufferSize is the size of the bufio buffer wrapped around
+	// the connection for writing. Zero means unbuffered, or
+	// DefaultWriteBufferSize with WriteBatchLatency.
+	//
+	// The frames written while others wait to be sent are buffered
+	// together, the buffer is flushed when no frame is waiting.
+	WriteBufferSize int
+
+	// WriteBatchLatency delays the flush of the write buffer by at most
+	// this time, so that the responses of concurrent calls are merged
+	// into fewer writes under load. Zero means the buffer is flushed as
+	// soon as no frame is waiting.
+	WriteBatchLatency time.Duration
+
+	// OneWayErrorHandler is called by servers with the errors of one-way
+	// calls (see OneWay), which are not sent to the client: the error of
+	// the handler, or the error of a request that could not be served.
+	// It is called before the next response of the connection is sent,
+	// and should not block. Nil means the errors are dropped.
+	OneWayErrorHandler func(serviceMethod string, err error)
+
+	// Scheduler limits the calls served at once by the server codecs
+	// sharing it, and starts the waiting calls by priority. Nil means
+	// the calls are served as soon as they are received.
+	Scheduler *Scheduler
+
+	// Tracer starts a span for each call of the codec: clients from the
+	// write of the request to the read of the response, servers from
+	// the read of the request to the write of the response. Nil means
+	// no span, the trace context of the calls (see NewTraceContext) is
+	// still sent and received.
+	Tracer Tracer
+
+	// Credentials attach credentials to each request of a client, unless
+	// the call has the CallCredentials option.
+	Credentials Credentials
+
+	// Authenticator authenticates each request of a server before it is
+	// served. It is called by the goroutine reading the connection, the
+	// next requests are read once it returns. Nil means the requests are
+	// not authenticated.
+	Authenticator Authenticator
+}
+
+// DefaultMinCompressLen is the default value of Options.MinCompressLen.
+const DefaultMinCompressLen = 64
+
+// DefaultReadBufferSize is the default value of Options.ReadBufferSize.
+const DefaultReadBufferSize = 4096
+
+// DefaultWriteBufferSize is the value of Options.WriteBufferSize used
+// with Options.WriteBatchLatency.
+const DefaultWriteBufferSize = 32 << 10
+
+// DefaultOptions returns the options used by NewClientCodec and NewServerCodec.
+func DefaultOptions() *Options {
+	opts := &Options{
+		Compression:    CompressionSnappy,
+		MinCompressLen: DefaultMinCompressLen,
+		Checksum:       ChecksumCRC32,
+	}
+	if !UseSnappy {
+		opts.Compression = CompressionNone
+	}
+	if !UseCrc32ChecksumIEEE {
+		opts.Checksum = ChecksumNone
+	}
+	return opts
+}
+
+// clone returns a private copy of opts, so later changes made by the
+// caller can not race with the codec.
+func (opts *Options) clone() *Options {
+	if opts == nil {
+		return DefaultOptions()
+	}
+	x := *opts
+	return &x
+}
+
+func (opts *Options) newReader(r io.Reader) *bufio.Reader {
+	return bufio.NewReaderSize(r, maxLen(opts.ReadBufferSize, DefaultReadBufferSize))
+}
+
+func (opts *Options) newWriter(w io.Writer) io.Writer {
+	if opts.WriteBufferSize > 0 || opts.WriteBatchLatency > 0 {
+		return bufio.NewWriterSize(w, maxLen(opts.WriteBufferSize, DefaultWriteBufferSize))
+	}
+	return w
+}
+
+func maxLen(limit, protocolDefault int) int {
+	if limit > 0 {
+		return limit
+	}
+	return protocolDefault
+}
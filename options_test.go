@@ -0,0 +1,72 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc_test
+
+import (
+	"net"
+	"net/rpc"
+	"testing"
+
+	"github.com/chai2010/protorpc"
+)
+
+func listenAndServeWithOptions(t *testing.T, opts *protorpc.Options) net.Listener {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf(`net.Listen("tcp", "127.0.0.1:0"): %v`, err)
+	}
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("ArithService", new(Arith)); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.RegisterName("EchoService", new(Echo)); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go srv.ServeCodec(protorpc.NewServerCodecWithOptions(conn, opts))
+		}
+	}()
+	return lis
+}
+
+func TestOptionsPerConnection(t *testing.T) {
+	lis := listenAndServeWithOptions(t, &protorpc.Options{
+		Compression:    protorpc.CompressionSnappy,
+		ReadBufferSize: 4096,
+	})
+	defer lis.Close()
+
+	optsList := []*protorpc.Options{
+		nil,
+		{},
+		{Compression: protorpc.CompressionSnappy},
+		{Checksum: protorpc.ChecksumCRC32C, WriteBufferSize: 4096, Handshake: true},
+		{Compression: protorpc.CompressionSnappy, Checksum: protorpc.ChecksumXXHash64, ReadBufferSize: 64, WriteBufferSize: 64, Handshake: true},
+	}
+
+	var clients []*rpc.Client
+	for i, opts := range optsList {
+		client, err := protorpc.DialWithOptions("tcp", lis.Addr().String(), opts)
+		if err != nil {
+			t.Fatalf("%d: protorpc.DialWithOptions: %v", i, err)
+		}
+		defer client.Close()
+		clients = append(clients, client)
+	}
+
+	// all clients share the same server and process
+	for i := 0; i < 3; i++ {
+		for _, client := range clients {
+			testArithClient(t, client)
+			testEchoClient(t, client)
+			testArithClientAsync(t, client)
+		}
+	}
+}
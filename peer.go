@@ -0,0 +1,71 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+)
+
+// Peer is the client of a request served by a Protobuf-RPC server codec.
+type Peer struct {
+	Addr net.Addr // nil if the connection is not a net.Conn
+
+	// TLS is the state of the connection if it is a *tls.Conn, nil
+	// otherwise.
+	TLS *tls.ConnectionState
+}
+
+// Certificate returns the certificate of the peer verified by the TLS
+// handshake, or nil if the peer sent no certificate or the server does
+// not verify it (see tls.Config.ClientAuth).
+func (p *Peer) Certificate() *x509.Certificate {
+	if p.TLS == nil || len(p.TLS.VerifiedChains) == 0 || len(p.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return p.TLS.VerifiedChains[0][0]
+}
+
+// Subject returns the subject of the verified certificate of the peer,
+// like "CN=alice,O=Acme", or "" if Certificate returns nil.
+func (p *Peer) Subject() string {
+	cert := p.Certificate()
+	if cert == nil {
+		return ""
+	}
+	return cert.Subject.String()
+}
+
+type peerKey struct{}
+
+// PeerFromContext returns the peer of the request whose context is ctx,
+// see RequestContext:
+//
+//	func (t *Echo) Echo(args *EchoRequest, reply *EchoResponse) error {
+//		p, ok := protorpc.PeerFromContext(protorpc.RequestContext(args))
+//		if !ok || p.Certificate() == nil {
+//			return protorpc.Errorf(protorpc.CodeUnauthenticated, "no client certificate")
+//		}
+//		...
+//	}
+func PeerFromContext(ctx context.Context) (*Peer, bool) {
+	p, ok := ctx.Value(peerKey{}).(*Peer)
+	return p, ok
+}
+
+// newPeer returns the peer of conn, whose TLS handshake, if any, is done.
+func newPeer(conn interface{}) *Peer {
+	p := &Peer{}
+	if c, ok := conn.(net.Conn); ok {
+		p.Addr = c.RemoteAddr()
+	}
+	if c, ok := conn.(*tls.Conn); ok {
+		state := c.ConnectionState()
+		p.TLS = &state
+	}
+	return p
+}
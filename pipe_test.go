@@ -0,0 +1,35 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"net"
+	"net/rpc"
+	"testing"
+)
+
+// dialTestServer serves the services registered by register with
+// serverOpts on one end of a pipe, and returns the other end. The
+// server stops when the test ends.
+func dialTestServer(t *testing.T, serverOpts *Options, register func(srv *rpc.Server) error) net.Conn {
+	t.Helper()
+	srv := rpc.NewServer()
+	if err := register(srv); err != nil {
+		t.Fatal(err)
+	}
+	cliConn, srvConn := net.Pipe()
+	go srv.ServeCodec(NewServerCodecWithOptions(srvConn, serverOpts))
+	t.Cleanup(func() { srvConn.Close() })
+	return cliConn
+}
+
+// newTestClient returns a client with clientOpts of a server started by
+// dialTestServer. The client is closed when the test ends.
+func newTestClient(t *testing.T, clientOpts, serverOpts *Options, register func(srv *rpc.Server) error) *rpc.Client {
+	t.Helper()
+	client := NewClientWithOptions(dialTestServer(t, serverOpts, register), clientOpts)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
@@ -0,0 +1,92 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"math/bits"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// The marshaled, compressed and received bodies are taken from pools of
+// buffers by size class: the capacity of a buffer is a power of two from
+// 1<<minPoolShift to 1<<maxPoolShift. Larger buffers are not pooled.
+const (
+	minPoolShift = 8
+	maxPoolShift = 24
+)
+
+var bufferPools [maxPoolShift - minPoolShift + 1]sync.Pool
+
+// poolClass returns the index in bufferPools of the buffers of capacity
+// at least n, or -1 if they are not pooled.
+func poolClass(n int) int {
+	if n <= 1<<minPoolShift {
+		return 0
+	}
+	shift := bits.Len(uint(n - 1))
+	if shift > maxPoolShift {
+		return -1
+	}
+	return shift - minPoolShift
+}
+
+// getBuffer returns a buffer of length n.
+func getBuffer(n int) []byte {
+	class := poolClass(n)
+	if class < 0 {
+		return make([]byte, n)
+	}
+	if p, ok := bufferPools[class].Get().(*[]byte); ok {
+		return (*p)[:n]
+	}
+	return make([]byte, n, 1<<(class+minPoolShift))
+}
+
+// putBuffer returns buf to its pool. buf must not be used after, buffers
+// whose capacity is not a size class are dropped.
+func putBuffer(buf []byte) {
+	c := cap(buf)
+	if c < 1<<minPoolShift || c&(c-1) != 0 {
+		return
+	}
+	class := poolClass(c)
+	if class < 0 {
+		return
+	}
+	buf = buf[:0]
+	bufferPools[class].Put(&buf)
+}
+
+// appendBuffer appends data to buf, which is grown with a pooled buffer.
+func appendBuffer(buf, data []byte) []byte {
+	return append(growBuffer(buf, len(data)), data...)
+}
+
+// growBuffer returns buf with room for n more bytes, in a pooled buffer
+// if it is grown.
+func growBuffer(buf []byte, n int) []byte {
+	if len(buf)+n <= cap(buf) {
+		return buf
+	}
+	size := 2 * cap(buf)
+	if size < len(buf)+n {
+		size = len(buf) + n
+	}
+	grown := getBuffer(size)[:len(buf)]
+	copy(grown, buf)
+	putBuffer(buf)
+	return grown
+}
+
+// marshal marshals m into a pooled buffer.
+func marshal(m proto.Message) ([]byte, error) {
+	b := proto.NewBuffer(getBuffer(proto.Size(m))[:0])
+	if err := b.Marshal(m); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,40 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBufferPool(t *testing.T) {
+	for _, tt := range []struct{ n, cap int }{
+		{0, 256},
+		{1, 256},
+		{256, 256},
+		{257, 512},
+		{1 << 20, 1 << 20},
+		{1<<24 + 1, 1<<24 + 1}, // not pooled
+	} {
+		buf := getBuffer(tt.n)
+		if len(buf) != tt.n || cap(buf) != tt.cap {
+			t.Fatalf("getBuffer(%d): len %d cap %d, expect cap %d", tt.n, len(buf), cap(buf), tt.cap)
+		}
+		putBuffer(buf)
+	}
+	putBuffer(make([]byte, 300)) // not a size class, dropped
+}
+
+func TestAppendBuffer(t *testing.T) {
+	var buf, want []byte
+	chunk := bytes.Repeat([]byte("x"), 100)
+	for i := 0; i < 50; i++ {
+		buf = appendBuffer(buf, chunk)
+		want = append(want, chunk...)
+	}
+	if !bytes.Equal(buf, want) {
+		t.Fatalf("appendBuffer: got %d bytes, expect %d", len(buf), len(want))
+	}
+}
@@ -5,8 +5,7 @@
 package plugin
 
 import (
-	"github.com/golang/protobuf/protoc-gen-go/descriptor"
-	"github.com/golang/protobuf/protoc-gen-go/generator"
+	"google.golang.org/protobuf/compiler/protogen"
 )
 
 var pkgCodeGeneratorList []CodeGenerator
@@ -15,9 +14,12 @@ type CodeGenerator interface {
 	Name() string
 	FileNameExt() string
 
-	HeaderCode(g *generator.Generator, file *generator.FileDescriptor) string
-	ServiceCode(g *generator.Generator, file *generator.FileDescriptor, svc *descriptor.ServiceDescriptorProto) string
-	MessageCode(g *generator.Generator, file *generator.FileDescriptor, msg *descriptor.DescriptorProto) string
+	// HeaderCode returns the package clause and the declarations before
+	// the code of the messages and services. The imports are added by g:
+	// the identifiers of other packages are qualified by g.QualifiedGoIdent.
+	HeaderCode(g *protogen.GeneratedFile, file *protogen.File) string
+	ServiceCode(g *protogen.GeneratedFile, file *protogen.File, svc *protogen.Service) string
+	MessageCode(g *protogen.GeneratedFile, file *protogen.File, msg *protogen.Message) string
 }
 
 func RegisterCodeGenerator(g CodeGenerator) {
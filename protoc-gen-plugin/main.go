@@ -5,102 +5,109 @@
 package plugin
 
 import (
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
 	"strings"
 
-	"github.com/golang/protobuf/proto"
-	"github.com/golang/protobuf/protoc-gen-go/generator"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/pluginpb"
 )
 
 func Main() {
-	mainPlugin := new(mainPlugin)
-	generator.RegisterPlugin(mainPlugin)
-
-	// Begin by allocating a generator. The request and response structures are stored there
-	// so we can do error handling easily - the response structure contains the field to
-	// report failure.
-	g := generator.New()
 	if len(getAllCodeGenerator()) == 0 {
-		g.Fail("no code generator plugin")
+		log.Fatal("protoc-gen-plugin: no code generator plugin")
 	}
 
-	pkgReadRequetFromStdin(g)
-	pkgGenerateAllFiles(g, mainPlugin)
-	pkgWriteResponseToStdout(g)
+	req := pkgReadRequetFromStdin()
+	pkgWriteResponseToStdout(pkgGenerateAllFiles(req))
 }
 
-func pkgGenerateAllFiles(g *generator.Generator, plugin *mainPlugin) {
-	// set default plugins
-	// protoc --xxx_out=. x.proto
-	plugin.InitService(pkgGetUserPlugin(g))
-
-	// parse command line parameters
-	g.CommandLineParameters("plugins=" + plugin.Name())
-
-	// Create a wrapped version of the Descriptors and EnumDescriptors that
-	// point to the file that defines them.
-	g.WrapTypes()
-
-	g.SetPackageNames()
-	g.BuildTypeNameMap()
+// pkgGenerateAllFiles runs the code generator selected by the plugin
+// parameter on the files to generate. The parameters of protoc-gen-go
+// (M import mappings, paths, module, import_path, ...) are understood.
+func pkgGenerateAllFiles(req *pluginpb.CodeGeneratorRequest) *pluginpb.CodeGeneratorResponse {
+	var userPluginName, importPrefix string
+	legacy := pkgLegacyFiles(req)
+	importPaths := make(map[protogen.GoImportPath]bool)
+
+	opts := protogen.Options{
+		ParamFunc: func(name, value string) error {
+			switch name {
+			case "plugin":
+				userPluginName = value
+			case "import_prefix":
+				importPrefix = value
+			}
+			return nil
+		},
+		ImportRewriteFunc: func(importPath protogen.GoImportPath) protogen.GoImportPath {
+			if !importPaths[importPath] {
+				return importPath
+			}
+			return protogen.GoImportPath(importPrefix) + legacyImportPath(importPath)
+		},
+	}
 
-	g.GenerateAllFiles()
+	gen, err := opts.New(pkgLegacyRequest(req, legacy))
+	if err != nil {
+		return &pluginpb.CodeGeneratorResponse{Error: proto.String(err.Error())}
+	}
+	for _, file := range gen.Files {
+		importPaths[file.GoImportPath] = true
+	}
 
-	// skip non *.pb.xxx.go
-	respFileList := g.Response.File[:0]
-	for _, file := range g.Response.File {
-		fileName := file.GetName()
-		extName := plugin.FileNameExt()
+	userPlugin := pkgGetUserPlugin(userPluginName)
+	if userPlugin == nil {
+		gen.Error(fmt.Errorf("invalid plugin option: %q, registered plugins: %v",
+			userPluginName, getAllServiceGeneratorNames(),
+		))
+		return gen.Response()
+	}
 
-		if strings.HasSuffix(fileName, extName) {
-			respFileList = append(respFileList, file)
+	for _, file := range gen.Files {
+		if file.Generate {
+			generateFile(gen, userPlugin, file, goFileName(gen, userPlugin, file, legacy))
 		}
 	}
-	g.Response.File = respFileList
+	return gen.Response()
 }
 
-func pkgReadRequetFromStdin(g *generator.Generator) {
+func pkgReadRequetFromStdin() *pluginpb.CodeGeneratorRequest {
 	data, err := ioutil.ReadAll(os.Stdin)
 	if err != nil {
-		g.Error(err, "reading input")
+		log.Fatal("protoc-gen-plugin: reading input: ", err)
 	}
 
-	if err := proto.Unmarshal(data, g.Request); err != nil {
-		g.Error(err, "parsing input proto")
+	req := new(pluginpb.CodeGeneratorRequest)
+	if err := proto.Unmarshal(data, req); err != nil {
+		log.Fatal("protoc-gen-plugin: parsing input proto: ", err)
 	}
 
-	if len(g.Request.FileToGenerate) == 0 {
-		g.Fail("no files to generate")
+	if len(req.FileToGenerate) == 0 {
+		log.Fatal("protoc-gen-plugin: no files to generate")
 	}
+	return req
 }
 
-func pkgWriteResponseToStdout(g *generator.Generator) {
-	data, err := proto.Marshal(g.Response)
+func pkgWriteResponseToStdout(resp *pluginpb.CodeGeneratorResponse) {
+	data, err := proto.Marshal(resp)
 	if err != nil {
-		g.Error(err, "failed to marshal output proto")
+		log.Fatal("protoc-gen-plugin: failed to marshal output proto: ", err)
 	}
 	_, err = os.Stdout.Write(data)
 	if err != nil {
-		g.Error(err, "failed to write output proto")
+		log.Fatal("protoc-gen-plugin: failed to write output proto: ", err)
 	}
 }
 
-func pkgGetUserPlugin(g *generator.Generator) CodeGenerator {
-	args := g.Request.GetParameter()
-	userPluginName := pkgGetParameterValue(args, "plugin")
-	if userPluginName == "" {
-		userPluginName = getFirstServiceGeneratorName()
+func pkgGetUserPlugin(name string) CodeGenerator {
+	if name == "" {
+		name = getFirstServiceGeneratorName()
 	}
-
-	userPlugin := getCodeGenerator(userPluginName)
-	if userPlugin == nil {
-		log.Print("protoc-gen-plugin: registor plugins:", getAllServiceGeneratorNames())
-		g.Fail("invalid plugin option:", userPluginName)
-	}
-
-	return userPlugin
+	return getCodeGenerator(name)
 }
 
 func pkgGetParameterValue(parameter, key string) string {
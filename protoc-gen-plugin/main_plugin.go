@@ -5,99 +5,156 @@
 package plugin
 
 import (
-	"bytes"
 	"fmt"
-	"go/format"
 	"path"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
-	"github.com/golang/protobuf/proto"
-	"github.com/golang/protobuf/protoc-gen-go/generator"
-	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
 )
 
-// mainPlugin produce the Service interface.
-type mainPlugin struct {
-	*generator.Generator
-	CodeGenerator
-}
+// legacyImportPathPrefix starts the import paths given to the legacy files,
+// protogen refuses the import paths without a slash.
+const legacyImportPathPrefix = "./"
 
-// Name returns the name of the plugin.
-func (p *mainPlugin) Name() string { return "main-plugin" }
+// generateFile generates the code of cg for file.
+// rpc service can't handle other proto message!!!
+func generateFile(gen *protogen.Plugin, cg CodeGenerator, file *protogen.File, filename string) {
+	g := gen.NewGeneratedFile(filename, file.GoImportPath)
+	g.P(cg.HeaderCode(g, file))
 
-// Init is called once after data structures are built but before
-// code generation begins.
-func (p *mainPlugin) Init(g *generator.Generator) {
-	p.Generator = g
-}
+	for _, msg := range file.Messages {
+		g.P(cg.MessageCode(g, file, msg))
+	}
 
-func (p *mainPlugin) InitService(g CodeGenerator) {
-	p.CodeGenerator = g
+	for _, svc := range file.Services {
+		g.P(cg.ServiceCode(g, file, svc))
+	}
 }
 
-// Generate produces the code generated by the plugin for this file.
-func (p *mainPlugin) GenerateImports(file *generator.FileDescriptor) {
-	// skip
-}
+// goFileName returns the name of the generated file of file: in the
+// directory of its import path, or next to the .proto file with
+// paths=source_relative and for the legacy files.
+func goFileName(gen *protogen.Plugin, cg CodeGenerator, file *protogen.File, legacy map[string]bool) string {
+	if !legacy[file.Desc.Path()] {
+		return file.GeneratedFilenamePrefix + cg.FileNameExt()
+	}
 
-// Generate generates the Service interface.
-// rpc service can't handle other proto message!!!
-func (p *mainPlugin) Generate(file *generator.FileDescriptor) {
-	if !p.isFileNeedGenerate(file) {
-		return
+	name := file.Desc.Path()
+	if ext := path.Ext(name); ext == ".proto" || ext == ".protodevel" {
+		name = name[:len(name)-len(ext)]
 	}
+	name += cg.FileNameExt()
 
-	var buf bytes.Buffer
-	fmt.Fprintln(&buf, p.HeaderCode(p.Generator, file))
+	// With module=prefix, the prefix is removed from the filename by gen.
+	if prefix := pkgGetParameterValue(gen.Request.GetParameter(), "module"); prefix != "" {
+		name = prefix + "/" + name
+	}
+	return name
+}
 
-	for _, msg := range file.MessageType {
-		fmt.Fprintln(&buf, p.MessageCode(p.Generator, file, msg))
+// pkgLegacyFiles returns the files which have neither an M mapping nor an
+// import path in their "go_package" option. protoc-gen-go v1.3 accepted
+// them, protogen requires an import path.
+func pkgLegacyFiles(req *pluginpb.CodeGeneratorRequest) map[string]bool {
+	mapped := make(map[string]bool)
+	for _, p := range strings.Split(req.GetParameter(), ",") {
+		if i := strings.Index(p, "="); i > 0 && p[0] == 'M' {
+			mapped[p[1:i]] = true
+		}
 	}
 
-	for _, svc := range file.Service {
-		fmt.Fprintln(&buf, p.ServiceCode(p.Generator, file, svc))
+	legacy := make(map[string]bool)
+	for _, file := range req.ProtoFile {
+		if impPath, _, _ := goPackageOption(file); impPath == "" && !mapped[file.GetName()] {
+			legacy[file.GetName()] = true
+		}
 	}
+	return legacy
+}
 
-	fileContent := buf.String()
-	if code, err := format.Source(buf.Bytes()); err == nil {
-		fileContent = string(code)
+// pkgLegacyRequest returns req with the M mappings of the legacy files,
+// like protoc-gen-go v1.3: the files to generate are in the package of the
+// import_path parameter, the other files in the directory of the .proto
+// file, and the package is named by the "go_package" option, the
+// import_path parameter, the proto package or the file name.
+func pkgLegacyRequest(req *pluginpb.CodeGeneratorRequest, legacy map[string]bool) *pluginpb.CodeGeneratorRequest {
+	if len(legacy) == 0 {
+		return req
 	}
 
-	p.Generator.Response.File = append(p.Generator.Response.File, &plugin.CodeGeneratorResponse_File{
-		Name:    proto.String(p.goFileName(file)),
-		Content: proto.String(fileContent),
-	})
-}
+	generate := make(map[string]bool)
+	for _, name := range req.FileToGenerate {
+		generate[name] = true
+	}
 
-func (p *mainPlugin) isFileNeedGenerate(file *generator.FileDescriptor) bool {
-	for _, v := range p.Generator.Request.FileToGenerate {
-		if v == file.GetName() {
-			return true
+	var params []string
+	if p := req.GetParameter(); p != "" {
+		params = append(params, p)
+	}
+	for _, file := range req.ProtoFile {
+		if !legacy[file.GetName()] {
+			continue
+		}
+		impPath := ""
+		if generate[file.GetName()] {
+			impPath = pkgGetParameterValue(req.GetParameter(), "import_path")
 		}
+		pkg := legacyPackageName(file, impPath)
+		if impPath == "" {
+			impPath = path.Dir(file.GetName())
+		}
+		params = append(params, fmt.Sprintf("M%s=%s%s;%s",
+			file.GetName(), legacyImportPathPrefix, impPath, pkg,
+		))
 	}
-	return false
+
+	req = proto.Clone(req).(*pluginpb.CodeGeneratorRequest)
+	req.Parameter = proto.String(strings.Join(params, ","))
+	return req
 }
 
-func (p *mainPlugin) goFileName(file *generator.FileDescriptor) string {
-	name := *file.Name
-	if ext := path.Ext(name); ext == ".proto" || ext == ".protodevel" {
-		name = name[:len(name)-len(ext)]
+func legacyPackageName(file *descriptorpb.FileDescriptorProto, importPath string) string {
+	_, pkg, ok := goPackageOption(file)
+	switch {
+	case ok:
+	case importPath != "":
+		pkg = path.Base(importPath)
+	case file.GetPackage() != "":
+		pkg = file.GetPackage()
+	default:
+		name := path.Base(file.GetName())
+		pkg = strings.TrimSuffix(name, path.Ext(name))
 	}
-	name += p.FileNameExt()
+	return cleanPackageName(pkg)
+}
 
-	// Does the file have a "go_package" option?
-	// If it does, it may override the filename.
-	if impPath, _, ok := p.goPackageOption(file); ok && impPath != "" {
-		// Replace the existing dirname with the declared import path.
-		_, name = path.Split(name)
-		name = path.Join(impPath, name)
-		return name
-	}
+// legacyImportPath returns the import path of the legacy files without
+// the legacyImportPathPrefix.
+func legacyImportPath(importPath protogen.GoImportPath) protogen.GoImportPath {
+	return protogen.GoImportPath(strings.TrimPrefix(string(importPath), legacyImportPathPrefix))
+}
 
+// cleanPackageName returns name with the characters not allowed in a Go
+// identifier replaced by underscores.
+func cleanPackageName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name)
+	if r, _ := utf8.DecodeRuneInString(name); unicode.IsDigit(r) {
+		name = "_" + name
+	}
 	return name
 }
 
-func (p *mainPlugin) goPackageOption(file *generator.FileDescriptor) (impPath, pkg string, ok bool) {
+func goPackageOption(file *descriptorpb.FileDescriptorProto) (impPath, pkg string, ok bool) {
 	pkg = file.GetOptions().GetGoPackage()
 	if pkg == "" {
 		return
@@ -0,0 +1,234 @@
+// Copyright 2017 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a Apache
+// license that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func init() {
+	RegisterCodeGenerator(new(testPlugin))
+}
+
+// testPlugin declares a variable of the args and reply types of each method.
+type testPlugin struct{}
+
+func (p *testPlugin) Name() string        { return "test" }
+func (p *testPlugin) FileNameExt() string { return ".pb.test.go" }
+
+func (p *testPlugin) HeaderCode(g *protogen.GeneratedFile, file *protogen.File) string {
+	return fmt.Sprintf("package %s\n\nvar _ %s\n",
+		file.GoPackageName, g.QualifiedGoIdent(protogen.GoImportPath("context").Ident("Context")),
+	)
+}
+
+func (p *testPlugin) ServiceCode(g *protogen.GeneratedFile, file *protogen.File, svc *protogen.Service) string {
+	var code string
+	for _, m := range svc.Methods {
+		code += fmt.Sprintf("var _ *%s\nvar _ *%s\n",
+			g.QualifiedGoIdent(m.Input.GoIdent), g.QualifiedGoIdent(m.Output.GoIdent),
+		)
+	}
+	return code
+}
+
+func (p *testPlugin) MessageCode(g *protogen.GeneratedFile, file *protogen.File, msg *protogen.Message) string {
+	return ""
+}
+
+// testProtoFile returns a file of the proto package pkg with a message Msg
+// and, if reply is not empty, a service S with a method Call(Msg) returns (reply).
+func testProtoFile(name, pkg, goPackage, reply string, deps ...string) *descriptorpb.FileDescriptorProto {
+	file := &descriptorpb.FileDescriptorProto{
+		Name:        proto.String(name),
+		Package:     proto.String(pkg),
+		Dependency:  deps,
+		MessageType: []*descriptorpb.DescriptorProto{{Name: proto.String("Msg")}},
+		Syntax:      proto.String("proto3"),
+	}
+	if goPackage != "" {
+		file.Options = &descriptorpb.FileOptions{GoPackage: proto.String(goPackage)}
+	}
+	if reply != "" {
+		file.Service = []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("S"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("Call"),
+				InputType:  proto.String("." + pkg + ".Msg"),
+				OutputType: proto.String(reply),
+			}},
+		}}
+	}
+	return file
+}
+
+func TestGenerateAllFiles(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		parameter string
+		files     []*descriptorpb.FileDescriptorProto
+		wantName  string
+		want      []string
+	}{
+		{
+			name: "go_package with package name",
+			files: []*descriptorpb.FileDescriptorProto{
+				testProtoFile("dir/a.proto", "a", "example.com/x;y", ".a.Msg"),
+			},
+			wantName: "example.com/x/a.pb.test.go",
+			want:     []string{"package y\n", "var _ *Msg\n"},
+		},
+		{
+			name: "go_package",
+			files: []*descriptorpb.FileDescriptorProto{
+				testProtoFile("dir/a.proto", "a", "example.com/x", ".a.Msg"),
+			},
+			wantName: "example.com/x/a.pb.test.go",
+			want:     []string{"package x\n"},
+		},
+		{
+			name:      "paths=source_relative",
+			parameter: "paths=source_relative",
+			files: []*descriptorpb.FileDescriptorProto{
+				testProtoFile("dir/a.proto", "a", "example.com/x;y", ".a.Msg"),
+			},
+			wantName: "dir/a.pb.test.go",
+			want:     []string{"package y\n"},
+		},
+		{
+			name:      "module",
+			parameter: "module=example.com",
+			files: []*descriptorpb.FileDescriptorProto{
+				testProtoFile("dir/a.proto", "a", "example.com/x;y", ".a.Msg"),
+			},
+			wantName: "x/a.pb.test.go",
+			want:     []string{"package y\n"},
+		},
+		{
+			name:      "M",
+			parameter: "Mdir/a.proto=example.com/m,plugin=test",
+			files: []*descriptorpb.FileDescriptorProto{
+				testProtoFile("dir/a.proto", "a", "example.com/x;y", ".a.Msg"),
+			},
+			wantName: "example.com/m/a.pb.test.go",
+			want:     []string{"package y\n"},
+		},
+		{
+			name:      "M without go_package",
+			parameter: "Mdir/a.proto=example.com/m",
+			files: []*descriptorpb.FileDescriptorProto{
+				testProtoFile("dir/a.proto", "a", "", ".a.Msg"),
+			},
+			wantName: "example.com/m/a.pb.test.go",
+			want:     []string{"package m\n"},
+		},
+		{
+			name: "without go_package",
+			files: []*descriptorpb.FileDescriptorProto{
+				testProtoFile("dir/a.proto", "foo.bar", "", ".foo.bar.Msg"),
+			},
+			wantName: "dir/a.pb.test.go",
+			want:     []string{"package foo_bar\n", "var _ *Msg\n"},
+		},
+		{
+			name: "go_package without import path",
+			files: []*descriptorpb.FileDescriptorProto{
+				testProtoFile("dir/a.proto", "a", "y", ".a.Msg"),
+			},
+			wantName: "dir/a.pb.test.go",
+			want:     []string{"package y\n"},
+		},
+		{
+			name:      "import_path",
+			parameter: "import_path=example.com/imp",
+			files: []*descriptorpb.FileDescriptorProto{
+				testProtoFile("dir/a.proto", "a", "", ".a.Msg"),
+			},
+			wantName: "dir/a.pb.test.go",
+			want:     []string{"package imp\n"},
+		},
+		{
+			name: "reply of other package",
+			files: []*descriptorpb.FileDescriptorProto{
+				testProtoFile("b.proto", "b", "example.com/b;bpb", ""),
+				testProtoFile("dir/a.proto", "a", "example.com/x;y", ".b.Msg", "b.proto"),
+			},
+			wantName: "example.com/x/a.pb.test.go",
+			want: []string{
+				"\tb \"example.com/b\"\n", "\tcontext \"context\"\n",
+				"var _ *Msg\n", "var _ *b.Msg\n",
+			},
+		},
+		{
+			name:      "reply of other package with M",
+			parameter: "Mb.proto=example.com/m",
+			files: []*descriptorpb.FileDescriptorProto{
+				testProtoFile("b.proto", "b", "", ""),
+				testProtoFile("dir/a.proto", "a", "", ".b.Msg", "b.proto"),
+			},
+			wantName: "dir/a.pb.test.go",
+			want:     []string{"\tm \"example.com/m\"\n", "var _ *m.Msg\n"},
+		},
+		{
+			name:      "reply of other package without go_package",
+			parameter: "import_prefix=example.com/",
+			files: []*descriptorpb.FileDescriptorProto{
+				testProtoFile("other/b.proto", "b", "", ""),
+				testProtoFile("dir/a.proto", "a", "", ".b.Msg", "other/b.proto"),
+			},
+			wantName: "dir/a.pb.test.go",
+			want: []string{
+				"\tother \"example.com/other\"\n", "\tcontext \"context\"\n",
+				"var _ *other.Msg\n",
+			},
+		},
+	} {
+		req := &pluginpb.CodeGeneratorRequest{
+			FileToGenerate: []string{"dir/a.proto"},
+			Parameter:      proto.String(tt.parameter),
+			ProtoFile:      tt.files,
+		}
+		resp := pkgGenerateAllFiles(req)
+		if resp.Error != nil {
+			t.Errorf("%s: %s", tt.name, resp.GetError())
+			continue
+		}
+		if len(resp.File) != 1 {
+			t.Errorf("%s: got %d files, want 1", tt.name, len(resp.File))
+			continue
+		}
+		if name := resp.File[0].GetName(); name != tt.wantName {
+			t.Errorf("%s: file name: got %q, want %q", tt.name, name, tt.wantName)
+		}
+		content := resp.File[0].GetContent()
+		for _, s := range tt.want {
+			if !strings.Contains(content, s) {
+				t.Errorf("%s: missing %q in:\n%s", tt.name, s, content)
+			}
+		}
+	}
+}
+
+func TestGenerateAllFilesError(t *testing.T) {
+	for _, parameter := range []string{"plugin=unknown", "paths=unknown"} {
+		req := &pluginpb.CodeGeneratorRequest{
+			FileToGenerate: []string{"a.proto"},
+			Parameter:      proto.String(parameter),
+			ProtoFile: []*descriptorpb.FileDescriptorProto{
+				testProtoFile("a.proto", "a", "example.com/x", ".a.Msg"),
+			},
+		}
+		if resp := pkgGenerateAllFiles(req); resp.Error == nil {
+			t.Errorf("%s: no error", parameter)
+		}
+	}
+}
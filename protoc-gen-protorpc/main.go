@@ -8,11 +8,14 @@ import (
 	"bytes"
 	"log"
 	"os"
+	"strings"
 	"text/template"
 
 	plugin "github.com/chai2010/protorpc/protoc-gen-plugin"
-	"github.com/golang/protobuf/protoc-gen-go/descriptor"
-	"github.com/golang/protobuf/protoc-gen-go/generator"
+	wire "github.com/chai2010/protorpc/wire.pb"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/types/descriptorpb"
 )
 
 var flagPrefix = os.Getenv(ENV_PROTOC_GEN_PROTORPC_FLAG_PREFIX)
@@ -30,9 +33,29 @@ type protorpcPlugin struct{}
 func (p *protorpcPlugin) Name() string        { return "protorpc-go" }
 func (p *protorpcPlugin) FileNameExt() string { return ".pb.protorpc.go" }
 
-func (p *protorpcPlugin) HeaderCode(g *generator.Generator, file *generator.FileDescriptor) string {
+// headerImports are the packages used by the generated code.
+var headerImports = []protogen.GoImportPath{
+	"context",
+	"crypto/tls",
+	"fmt",
+	"io",
+	"log",
+	"net",
+	"net/rpc",
+	"time",
+
+	"github.com/chai2010/protorpc",
+	"github.com/golang/protobuf/proto",
+}
+
+func (p *protorpcPlugin) HeaderCode(g *protogen.GeneratedFile, file *protogen.File) string {
+	// import the packages before the types of other proto packages,
+	// the templates use their names
+	for _, importPath := range headerImports {
+		g.QualifiedGoIdent(importPath.Ident(""))
+	}
+
 	const tmpl = `
-{{- $G := .G -}}
 {{- $File := .File -}}
 
 // Code generated by protoc-gen-protorpc. DO NOT EDIT.
@@ -40,23 +63,13 @@ func (p *protorpcPlugin) HeaderCode(g *generator.Generator, file *generator.File
 // plugin: https://github.com/chai2010/protorpc/tree/master/protoc-gen-plugin
 // plugin: https://github.com/chai2010/protorpc/tree/master/protoc-gen-protorpc
 //
-// source: {{$File.GetName}}
-
-package {{$File.PackageName}}
+// source: {{$File.Desc.Path}}
 
-import (
-	"fmt"
-	"io"
-	"log"
-	"net"
-	"net/rpc"
-	"time"
-
-	"github.com/chai2010/protorpc"
-	"github.com/golang/protobuf/proto"
-)
+package {{.PackageName}}
 
 var (
+	_ = context.Background
+	_ = tls.Client
 	_ = fmt.Sprint
 	_ = io.Reader(nil)
 	_ = log.Print
@@ -72,13 +85,13 @@ var (
 	t := template.Must(template.New("").Parse(tmpl))
 	err := t.Execute(&buf,
 		struct {
-			G      *generator.Generator
-			File   *generator.FileDescriptor
-			Prefix string
+			File        *protogen.File
+			PackageName string
+			Prefix      string
 		}{
-			G:      g,
-			File:   file,
-			Prefix: flagPrefix,
+			File:        file,
+			PackageName: string(file.GoPackageName),
+			Prefix:      flagPrefix,
 		},
 	)
 	if err != nil {
@@ -88,7 +101,7 @@ var (
 	return buf.String()
 }
 
-func (p *protorpcPlugin) ServiceCode(g *generator.Generator, file *generator.FileDescriptor, svc *descriptor.ServiceDescriptorProto) string {
+func (p *protorpcPlugin) ServiceCode(g *protogen.GeneratedFile, file *protogen.File, svc *protogen.Service) string {
 	var code string
 	code += p.genServiceInterface(g, file, svc)
 	code += p.genServiceServer(g, file, svc)
@@ -96,14 +109,14 @@ func (p *protorpcPlugin) ServiceCode(g *generator.Generator, file *generator.Fil
 	return code
 }
 
-func (p *protorpcPlugin) MessageCode(g *generator.Generator, file *generator.FileDescriptor, msg *descriptor.DescriptorProto) string {
+func (p *protorpcPlugin) MessageCode(g *protogen.GeneratedFile, file *protogen.File, msg *protogen.Message) string {
 	return ""
 }
 
 func (p *protorpcPlugin) genServiceInterface(
-	g *generator.Generator,
-	file *generator.FileDescriptor,
-	svc *descriptor.ServiceDescriptorProto,
+	g *protogen.GeneratedFile,
+	file *protogen.File,
+	svc *protogen.Service,
 ) string {
 	const serviceInterfaceTmpl = `
 type {{.Prefix}}{{.ServiceName}} interface {
@@ -111,30 +124,37 @@ type {{.Prefix}}{{.ServiceName}} interface {
 }
 `
 	const callMethodTmpl = `
-{{.MethodName}}(in *{{.ArgsType}}, out *{{.ReplyType}}) error`
+{{- if .ClientStreaming}}
+{{.MethodName}}(stream {{.Prefix}}{{.ServiceName}}_{{.MethodName}}Server) error
+{{- else if .ServerStreaming}}
+{{.MethodName}}(in *{{.ArgsType}}, stream {{.Prefix}}{{.ServiceName}}_{{.MethodName}}Server) error
+{{- else}}
+{{.MethodName}}(in *{{.ArgsType}}, out *{{.ReplyType}}) error
+{{- end}}`
 
 	// gen call method list
 	var callMethodList string
-	for _, m := range svc.Method {
+	for _, m := range svc.Methods {
 		out := bytes.NewBuffer([]byte{})
 		t := template.Must(template.New("").Parse(callMethodTmpl))
 		t.Execute(out, &struct {
-			Prefix      string
-			ServiceName string
-			MethodName  string
-			ArgsType    string
-			ReplyType   string
+			Prefix          string
+			ServiceName     string
+			MethodName      string
+			ArgsType        string
+			ReplyType       string
+			ClientStreaming bool
+			ServerStreaming bool
 		}{
-			Prefix:      flagPrefix,
-			ServiceName: generator.CamelCase(svc.GetName()),
-			MethodName:  generator.CamelCase(m.GetName()),
-			ArgsType:    g.TypeName(g.ObjectNamed(m.GetInputType())),
-			ReplyType:   g.TypeName(g.ObjectNamed(m.GetOutputType())),
+			Prefix:          flagPrefix,
+			ServiceName:     svc.GoName,
+			MethodName:      m.GoName,
+			ArgsType:        g.QualifiedGoIdent(m.Input.GoIdent),
+			ReplyType:       g.QualifiedGoIdent(m.Output.GoIdent),
+			ClientStreaming: m.Desc.IsStreamingClient(),
+			ServerStreaming: m.Desc.IsStreamingServer(),
 		})
 		callMethodList += out.String()
-
-		g.RecordTypeUse(m.GetInputType())
-		g.RecordTypeUse(m.GetOutputType())
 	}
 
 	// gen all interface code
@@ -147,7 +167,7 @@ type {{.Prefix}}{{.ServiceName}} interface {
 			CallMethodList string
 		}{
 			Prefix:         flagPrefix,
-			ServiceName:    generator.CamelCase(svc.GetName()),
+			ServiceName:    svc.GoName,
 			CallMethodList: callMethodList,
 		})
 
@@ -156,9 +176,9 @@ type {{.Prefix}}{{.ServiceName}} interface {
 }
 
 func (p *protorpcPlugin) genServiceServer(
-	g *generator.Generator,
-	file *generator.FileDescriptor,
-	svc *descriptor.ServiceDescriptorProto,
+	g *protogen.GeneratedFile,
+	file *protogen.File,
+	svc *protogen.Service,
 ) string {
 	const serviceHelperFunTmpl = `
 // {{.Prefix}}Accept{{.ServiceName}}Client accepts connections on the listener and serves requests
@@ -166,7 +186,7 @@ func (p *protorpcPlugin) genServiceServer(
 // invokes it in a go statement.
 func {{.Prefix}}Accept{{.ServiceName}}Client(lis net.Listener, x {{.Prefix}}{{.ServiceName}}) {
 	srv := rpc.NewServer()
-	if err := srv.RegisterName("{{.ServiceRegisterName}}", x); err != nil {
+	if err := srv.RegisterName("{{.ServiceRegisterName}}", {{.Receiver}}); err != nil {
 		log.Fatal(err)
 	}
 
@@ -181,7 +201,7 @@ func {{.Prefix}}Accept{{.ServiceName}}Client(lis net.Listener, x {{.Prefix}}{{.S
 
 // {{.Prefix}}Register{{.ServiceName}} publish the given {{.Prefix}}{{.ServiceName}} implementation on the server.
 func {{.Prefix}}Register{{.ServiceName}}(srv *rpc.Server, x {{.Prefix}}{{.ServiceName}}) error {
-	if err := srv.RegisterName("{{.ServiceRegisterName}}", x); err != nil {
+	if err := srv.RegisterName("{{.ServiceRegisterName}}", {{.Receiver}}); err != nil {
 		return err
 	}
 	return nil
@@ -190,7 +210,7 @@ func {{.Prefix}}Register{{.ServiceName}}(srv *rpc.Server, x {{.Prefix}}{{.Servic
 // {{.Prefix}}New{{.ServiceName}}Server returns a new {{.Prefix}}{{.ServiceName}} Server.
 func {{.Prefix}}New{{.ServiceName}}Server(x {{.Prefix}}{{.ServiceName}}) *rpc.Server {
 	srv := rpc.NewServer()
-	if err := srv.RegisterName("{{.ServiceRegisterName}}", x); err != nil {
+	if err := srv.RegisterName("{{.ServiceRegisterName}}", {{.Receiver}}); err != nil {
 		log.Fatal(err)
 	}
 	return srv
@@ -199,6 +219,12 @@ func {{.Prefix}}New{{.ServiceName}}Server(x {{.Prefix}}{{.ServiceName}}) *rpc.Se
 // {{.Prefix}}ListenAndServe{{.ServiceName}} listen announces on the local network address laddr
 // and serves the given {{.ServiceName}} implementation.
 func {{.Prefix}}ListenAndServe{{.ServiceName}}(network, addr string, x {{.Prefix}}{{.ServiceName}}) error {
+	return {{.Prefix}}ListenAndServe{{.ServiceName}}WithOptions(network, addr, x, nil)
+}
+
+// {{.Prefix}}ListenAndServe{{.ServiceName}}WithOptions is like {{.Prefix}}ListenAndServe{{.ServiceName}}
+// but uses the given codec options for each connection.
+func {{.Prefix}}ListenAndServe{{.ServiceName}}WithOptions(network, addr string, x {{.Prefix}}{{.ServiceName}}, opts *protorpc.Options) error {
 	lis, err := net.Listen(network, addr)
 	if err != nil {
 		return err
@@ -206,7 +232,7 @@ func {{.Prefix}}ListenAndServe{{.ServiceName}}(network, addr string, x {{.Prefix
 	defer lis.Close()
 
 	srv := rpc.NewServer()
-	if err := srv.RegisterName("{{.ServiceRegisterName}}", x); err != nil {
+	if err := srv.RegisterName("{{.ServiceRegisterName}}", {{.Receiver}}); err != nil {
 		return err
 	}
 
@@ -215,19 +241,157 @@ func {{.Prefix}}ListenAndServe{{.ServiceName}}(network, addr string, x {{.Prefix
 		if err != nil {
 			log.Fatalf("lis.Accept(): %v\n", err)
 		}
-		go srv.ServeCodec(protorpc.NewServerCodec(conn))
+		go srv.ServeCodec(protorpc.NewServerCodecWithOptions(conn, opts))
 	}
 }
 
+// {{.Prefix}}ListenAndServe{{.ServiceName}}TLS listen announces on the local network address laddr
+// over TLS and serves the given {{.ServiceName}} implementation, see protorpc.ListenAndServeTLS.
+func {{.Prefix}}ListenAndServe{{.ServiceName}}TLS(network, addr string, x {{.Prefix}}{{.ServiceName}}, config *tls.Config) error {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("{{.ServiceRegisterName}}", {{.Receiver}}); err != nil {
+		return err
+	}
+	return protorpc.ListenAndServeTLS(network, addr, srv, config, nil)
+}
+
 // {{.Prefix}}Serve{{.ServiceName}} serves the given {{.Prefix}}{{.ServiceName}} implementation.
 func {{.Prefix}}Serve{{.ServiceName}}(conn io.ReadWriteCloser, x {{.Prefix}}{{.ServiceName}}) {
+	{{.Prefix}}Serve{{.ServiceName}}WithOptions(conn, x, nil)
+}
+
+// {{.Prefix}}Serve{{.ServiceName}}WithOptions serves the given {{.Prefix}}{{.ServiceName}} implementation
+// with the given codec options.
+func {{.Prefix}}Serve{{.ServiceName}}WithOptions(conn io.ReadWriteCloser, x {{.Prefix}}{{.ServiceName}}, opts *protorpc.Options) {
 	srv := rpc.NewServer()
-	if err := srv.RegisterName("{{.ServiceRegisterName}}", x); err != nil {
+	if err := srv.RegisterName("{{.ServiceRegisterName}}", {{.Receiver}}); err != nil {
 		log.Fatal(err)
 	}
-	srv.ServeCodec(protorpc.NewServerCodec(conn))
+	srv.ServeCodec(protorpc.NewServerCodecWithOptions(conn, opts))
 }
 `
+	const streamServerTmpl = `
+// {{.Prefix}}{{.ServiceName}}_{{.MethodName}}Server is the server side of the {{.MethodName}} stream.
+type {{.Prefix}}{{.ServiceName}}_{{.MethodName}}Server interface {
+{{- if .ServerStreaming}}
+	Send(*{{.ReplyType}}) error
+{{- else}}
+	SendAndClose(*{{.ReplyType}}) error
+{{- end}}
+{{- if .ClientStreaming}}
+	Recv() (*{{.ArgsType}}, error)
+{{- end}}
+	Context() context.Context
+}
+
+type {{.StreamType}}Server struct {
+	*protorpc.ServerStream
+}
+{{if .ServerStreaming}}
+func (x *{{.StreamType}}Server) Send(m *{{.ReplyType}}) error {
+	return x.ServerStream.SendMsg(m)
+}
+{{else}}
+func (x *{{.StreamType}}Server) SendAndClose(m *{{.ReplyType}}) error {
+	return x.ServerStream.SendMsg(m)
+}
+{{end}}
+{{- if .ClientStreaming}}
+func (x *{{.StreamType}}Server) Recv() (*{{.ArgsType}}, error) {
+	m := new({{.ArgsType}})
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+{{end}}`
+
+	const adapterTmpl = `
+// {{.AdapterType}} serves a {{.Prefix}}{{.ServiceName}} with net/rpc, the stream
+// methods are served with protorpc.ServerStream.
+type {{.AdapterType}} struct {
+	x {{.Prefix}}{{.ServiceName}}
+}
+{{.MethodList}}`
+
+	const adapterMethodTmpl = `
+{{- if .ClientStreaming}}
+func (s *{{.AdapterType}}) {{.MethodName}}(stream *protorpc.ServerStream, _ *protorpc.StreamReply) error {
+	return s.x.{{.MethodName}}(&{{.StreamType}}Server{stream})
+}
+{{else if .ServerStreaming}}
+func (s *{{.AdapterType}}) {{.MethodName}}(stream *protorpc.ServerStream, _ *protorpc.StreamReply) error {
+	in := new({{.ArgsType}})
+	if err := stream.RecvMsg(in); err != nil {
+		return err
+	}
+	return s.x.{{.MethodName}}(in, &{{.StreamType}}Server{stream})
+}
+{{else}}
+func (s *{{.AdapterType}}) {{.MethodName}}(in *{{.ArgsType}}, out *{{.ReplyType}}) error {
+	return s.x.{{.MethodName}}(in, out)
+}
+{{end}}`
+
+	serviceName := svc.GoName
+	adapterType := unexport(flagPrefix+serviceName) + "RPC"
+
+	// gen stream types and the net/rpc adapter of services with streams
+	var streamCode, adapterMethodList string
+	for _, m := range svc.Methods {
+		data := &struct {
+			Prefix          string
+			ServiceName     string
+			MethodName      string
+			ArgsType        string
+			ReplyType       string
+			ClientStreaming bool
+			ServerStreaming bool
+			StreamType      string
+			AdapterType     string
+		}{
+			Prefix:          flagPrefix,
+			ServiceName:     serviceName,
+			MethodName:      m.GoName,
+			ArgsType:        g.QualifiedGoIdent(m.Input.GoIdent),
+			ReplyType:       g.QualifiedGoIdent(m.Output.GoIdent),
+			ClientStreaming: m.Desc.IsStreamingClient(),
+			ServerStreaming: m.Desc.IsStreamingServer(),
+			StreamType:      unexport(flagPrefix + serviceName + m.GoName),
+			AdapterType:     adapterType,
+		}
+		if isStream(m) {
+			out := bytes.NewBuffer([]byte{})
+			t := template.Must(template.New("").Parse(streamServerTmpl))
+			t.Execute(out, data)
+			streamCode += out.String()
+		}
+
+		out := bytes.NewBuffer([]byte{})
+		t := template.Must(template.New("").Parse(adapterMethodTmpl))
+		t.Execute(out, data)
+		adapterMethodList += out.String()
+	}
+
+	receiver := "x"
+	if streamCode != "" {
+		out := bytes.NewBuffer([]byte{})
+		t := template.Must(template.New("").Parse(adapterTmpl))
+		t.Execute(out, &struct {
+			Prefix      string
+			ServiceName string
+			AdapterType string
+			MethodList  string
+		}{
+			Prefix:      flagPrefix,
+			ServiceName: serviceName,
+			AdapterType: adapterType,
+			MethodList:  adapterMethodList,
+		})
+		streamCode += out.String()
+		receiver = "&" + adapterType + "{x}"
+	}
+
 	{
 		out := bytes.NewBuffer([]byte{})
 		t := template.Must(template.New("").Parse(serviceHelperFunTmpl))
@@ -236,23 +400,25 @@ func {{.Prefix}}Serve{{.ServiceName}}(conn io.ReadWriteCloser, x {{.Prefix}}{{.S
 			PackageName         string
 			ServiceName         string
 			ServiceRegisterName string
+			Receiver            string
 		}{
 			Prefix:      flagPrefix,
-			PackageName: file.GetPackage(),
-			ServiceName: generator.CamelCase(svc.GetName()),
+			PackageName: string(file.Desc.Package()),
+			ServiceName: serviceName,
 			ServiceRegisterName: p.makeServiceRegisterName(
-				file, file.GetPackage(), generator.CamelCase(svc.GetName()),
+				file, string(file.Desc.Package()), serviceName,
 			),
+			Receiver: receiver,
 		})
 
-		return out.String()
+		return out.String() + streamCode
 	}
 }
 
 func (p *protorpcPlugin) genServiceClient(
-	g *generator.Generator,
-	file *generator.FileDescriptor,
-	svc *descriptor.ServiceDescriptorProto,
+	g *protogen.GeneratedFile,
+	file *protogen.File,
+	svc *protogen.Service,
 ) string {
 	const clientHelperFuncTmpl = `
 type {{.Prefix}}{{.ServiceName}}Client struct {
@@ -266,6 +432,13 @@ func {{.Prefix}}New{{.ServiceName}}Client(conn io.ReadWriteCloser) (*{{.Prefix}}
 	return &{{.Prefix}}{{.ServiceName}}Client{c}
 }
 
+// {{.Prefix}}New{{.ServiceName}}ClientWithOptions is like {{.Prefix}}New{{.ServiceName}}Client
+// but uses the given codec options.
+func {{.Prefix}}New{{.ServiceName}}ClientWithOptions(conn io.ReadWriteCloser, opts *protorpc.Options) (*{{.Prefix}}{{.ServiceName}}Client) {
+	c := rpc.NewClientWithCodec(protorpc.NewClientCodecWithOptions(conn, opts))
+	return &{{.Prefix}}{{.ServiceName}}Client{c}
+}
+
 {{.MethodList}}
 
 // {{.Prefix}}Dial{{.ServiceName}} connects to an {{.Prefix}}{{.ServiceName}} at the specified network address.
@@ -277,6 +450,16 @@ func {{.Prefix}}Dial{{.ServiceName}}(network, addr string) (*{{.Prefix}}{{.Servi
 	return &{{.Prefix}}{{.ServiceName}}Client{c}, nil
 }
 
+// {{.Prefix}}Dial{{.ServiceName}}WithOptions connects to an {{.Prefix}}{{.ServiceName}} at the specified network address,
+// using the given codec options.
+func {{.Prefix}}Dial{{.ServiceName}}WithOptions(network, addr string, opts *protorpc.Options) (*{{.Prefix}}{{.ServiceName}}Client, error) {
+	c, err := protorpc.DialWithOptions(network, addr, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &{{.Prefix}}{{.ServiceName}}Client{c}, nil
+}
+
 // {{.Prefix}}Dial{{.ServiceName}}Timeout connects to an {{.Prefix}}{{.ServiceName}} at the specified network address.
 func {{.Prefix}}Dial{{.ServiceName}}Timeout(network, addr string, timeout time.Duration) (*{{.Prefix}}{{.ServiceName}}Client, error) {
 	c, err := protorpc.DialTimeout(network, addr, timeout)
@@ -285,6 +468,16 @@ func {{.Prefix}}Dial{{.ServiceName}}Timeout(network, addr string, timeout time.D
 	}
 	return &{{.Prefix}}{{.ServiceName}}Client{c}, nil
 }
+
+// {{.Prefix}}Dial{{.ServiceName}}TLS connects to an {{.Prefix}}{{.ServiceName}} at the specified network address
+// over TLS.
+func {{.Prefix}}Dial{{.ServiceName}}TLS(network, addr string, config *tls.Config) (*{{.Prefix}}{{.ServiceName}}Client, error) {
+	c, err := protorpc.DialTLS(network, addr, config)
+	if err != nil {
+		return nil, err
+	}
+	return &{{.Prefix}}{{.ServiceName}}Client{c}, nil
+}
 `
 	const clientMethodTmpl = `
 func (c *{{.Prefix}}{{.ServiceName}}Client) {{.MethodName}}(in *{{.ArgsType}}) (out *{{.ReplyType}}, err error) {
@@ -315,6 +508,36 @@ func (c *{{.Prefix}}{{.ServiceName}}Client) {{.MethodName}}(in *{{.ArgsType}}) (
 	return out, nil
 }
 
+// {{.MethodName}}Context is like {{.MethodName}} but sends the metadata of ctx,
+// and cancels the call when ctx is done.
+func (c *{{.Prefix}}{{.ServiceName}}Client) {{.MethodName}}Context(ctx context.Context, in *{{.ArgsType}}, opts ...protorpc.CallOption) (out *{{.ReplyType}}, err error) {
+	if in == nil {
+		in = new({{.ArgsType}})
+	}
+
+	type Validator interface {
+		Validate() error
+	}
+	if x, ok := proto.Message(in).(Validator); ok {
+		if err := x.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	out = new({{.ReplyType}})
+	if err = protorpc.CallContext(ctx, c.Client, "{{.ServiceRegisterName}}.{{.MethodName}}", in, out, opts...); err != nil {
+		return nil, err
+	}
+
+	if x, ok := proto.Message(out).(Validator); ok {
+		if err := x.Validate(); err != nil {
+			return out, err
+		}
+	}
+
+	return out, nil
+}
+
 func (c *{{.Prefix}}{{.ServiceName}}Client) Async{{.MethodName}}(in *{{.ArgsType}}, out *{{.ReplyType}}, done chan *rpc.Call) *rpc.Call {
 	if in == nil {
 		in = new({{.ArgsType}})
@@ -325,13 +548,134 @@ func (c *{{.Prefix}}{{.ServiceName}}Client) Async{{.MethodName}}(in *{{.ArgsType
 		done,
 	)
 }
+
+// Async{{.MethodName}}Context is like Async{{.MethodName}} but sends the metadata of ctx,
+// and cancels the call when ctx is done.
+func (c *{{.Prefix}}{{.ServiceName}}Client) Async{{.MethodName}}Context(ctx context.Context, in *{{.ArgsType}}, out *{{.ReplyType}}, done chan *rpc.Call, opts ...protorpc.CallOption) *rpc.Call {
+	if in == nil {
+		in = new({{.ArgsType}})
+	}
+	return protorpc.GoContext(
+		ctx, c.Client,
+		"{{.ServiceRegisterName}}.{{.MethodName}}",
+		in, out,
+		done, opts...,
+	)
+}
 `
 
+	const clientOneWayTmpl = `
+// {{.MethodName}} sends a one-way call: it returns once the request is sent,
+// and the server sends no reply.
+func (c *{{.Prefix}}{{.ServiceName}}Client) {{.MethodName}}(in *{{.ArgsType}}) error {
+	return c.{{.MethodName}}Context(context.Background(), in)
+}
+
+// {{.MethodName}}Context is like {{.MethodName}} but sends the metadata of ctx.
+func (c *{{.Prefix}}{{.ServiceName}}Client) {{.MethodName}}Context(ctx context.Context, in *{{.ArgsType}}, opts ...protorpc.CallOption) error {
+	if in == nil {
+		in = new({{.ArgsType}})
+	}
+
+	type Validator interface {
+		Validate() error
+	}
+	if x, ok := proto.Message(in).(Validator); ok {
+		if err := x.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return protorpc.OneWay(ctx, c.Client, "{{.ServiceRegisterName}}.{{.MethodName}}", in, opts...)
+}
+`
+
+	const clientStreamTmpl = `
+// {{.MethodName}} opens the {{.MethodName}} stream. The stream is canceled when ctx is done.
+func (c *{{.Prefix}}{{.ServiceName}}Client) {{.MethodName}}(ctx context.Context, {{if not .ClientStreaming}}in *{{.ArgsType}}, {{end}}opts ...protorpc.CallOption) ({{.Prefix}}{{.ServiceName}}_{{.MethodName}}Client, error) {
+	stream, err := protorpc.NewStream(ctx, c.Client, "{{.ServiceRegisterName}}.{{.MethodName}}", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &{{.StreamType}}Client{stream}
+{{- if not .ClientStreaming}}
+	if in == nil {
+		in = new({{.ArgsType}})
+	}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+{{- end}}
+	return x, nil
+}
+
+// {{.Prefix}}{{.ServiceName}}_{{.MethodName}}Client is the client side of the {{.MethodName}} stream.
+type {{.Prefix}}{{.ServiceName}}_{{.MethodName}}Client interface {
+{{- if .ClientStreaming}}
+	Send(*{{.ArgsType}}) error
+{{- end}}
+{{- if .ServerStreaming}}
+	Recv() (*{{.ReplyType}}, error)
+{{- end}}
+{{- if and .ClientStreaming .ServerStreaming}}
+	CloseSend() error
+{{- else if .ClientStreaming}}
+	CloseAndRecv() (*{{.ReplyType}}, error)
+{{- end}}
+	Context() context.Context
+	Trailer() protorpc.Metadata
+}
+
+type {{.StreamType}}Client struct {
+	*protorpc.ClientStream
+}
+{{if .ClientStreaming}}
+func (x *{{.StreamType}}Client) Send(m *{{.ArgsType}}) error {
+	return x.ClientStream.SendMsg(m)
+}
+{{end}}
+{{- if .ServerStreaming}}
+func (x *{{.StreamType}}Client) Recv() (*{{.ReplyType}}, error) {
+	m := new({{.ReplyType}})
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+{{else}}
+func (x *{{.StreamType}}Client) CloseAndRecv() (*{{.ReplyType}}, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new({{.ReplyType}})
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	// wait for the end of the stream, and its trailer
+	if err := x.ClientStream.RecvMsg(new({{.ReplyType}})); err != io.EOF {
+		if err == nil {
+			err = fmt.Errorf("protorpc: {{.ServiceRegisterName}}.{{.MethodName}} sent more than one reply")
+		}
+		return nil, err
+	}
+	return m, nil
+}
+{{end}}`
+
 	// gen client method list
 	var methodList string
-	for _, m := range svc.Method {
+	for _, m := range svc.Methods {
+		tmpl := clientMethodTmpl
+		if isStream(m) {
+			tmpl = clientStreamTmpl
+		} else if isOneWay(m) {
+			tmpl = clientOneWayTmpl
+		}
 		out := bytes.NewBuffer([]byte{})
-		t := template.Must(template.New("").Parse(clientMethodTmpl))
+		t := template.Must(template.New("").Parse(tmpl))
 		t.Execute(out, &struct {
 			Prefix              string
 			ServiceName         string
@@ -339,15 +683,21 @@ func (c *{{.Prefix}}{{.ServiceName}}Client) Async{{.MethodName}}(in *{{.ArgsType
 			MethodName          string
 			ArgsType            string
 			ReplyType           string
+			ClientStreaming     bool
+			ServerStreaming     bool
+			StreamType          string
 		}{
 			Prefix:      flagPrefix,
-			ServiceName: generator.CamelCase(svc.GetName()),
+			ServiceName: svc.GoName,
 			ServiceRegisterName: p.makeServiceRegisterName(
-				file, file.GetPackage(), generator.CamelCase(svc.GetName()),
+				file, string(file.Desc.Package()), svc.GoName,
 			),
-			MethodName: generator.CamelCase(m.GetName()),
-			ArgsType:   g.TypeName(g.ObjectNamed(m.GetInputType())),
-			ReplyType:  g.TypeName(g.ObjectNamed(m.GetOutputType())),
+			MethodName:      m.GoName,
+			ArgsType:        g.QualifiedGoIdent(m.Input.GoIdent),
+			ReplyType:       g.QualifiedGoIdent(m.Output.GoIdent),
+			ClientStreaming: m.Desc.IsStreamingClient(),
+			ServerStreaming: m.Desc.IsStreamingServer(),
+			StreamType:      unexport(flagPrefix + svc.GoName + m.GoName),
 		})
 		methodList += out.String()
 	}
@@ -363,8 +713,8 @@ func (c *{{.Prefix}}{{.ServiceName}}Client) Async{{.MethodName}}(in *{{.ArgsType
 			MethodList  string
 		}{
 			Prefix:      flagPrefix,
-			PackageName: file.GetPackage(),
-			ServiceName: generator.CamelCase(svc.GetName()),
+			PackageName: string(file.Desc.Package()),
+			ServiceName: svc.GoName,
 			MethodList:  methodList,
 		})
 
@@ -373,9 +723,38 @@ func (c *{{.Prefix}}{{.ServiceName}}Client) Async{{.MethodName}}(in *{{.ArgsType
 }
 
 func (p *protorpcPlugin) makeServiceRegisterName(
-	file *generator.FileDescriptor,
+	file *protogen.File,
 	packageName, serviceName string,
 ) string {
 	// return packageName + "." + serviceName
 	return serviceName
 }
+
+// isStream reports whether m is a client, server or bidirectional
+// streaming method.
+func isStream(m *protogen.Method) bool {
+	return m.Desc.IsStreamingClient() || m.Desc.IsStreamingServer()
+}
+
+// isOneWay reports whether m has the one_way option of protorpc.proto.
+// Streaming methods are never one-way.
+func isOneWay(m *protogen.Method) bool {
+	opts, _ := m.Desc.Options().(*descriptorpb.MethodOptions)
+	if isStream(m) || opts == nil {
+		return false
+	}
+	v, err := proto.GetExtension(opts, wire.E_OneWay)
+	if err != nil {
+		return false
+	}
+	oneWay, ok := v.(*bool)
+	return ok && oneWay != nil && *oneWay
+}
+
+// unexport returns name with a lower case first letter.
+func unexport(name string) string {
+	if name == "" {
+		return name
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
@@ -0,0 +1,383 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpctest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"net"
+	"net/rpc"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chai2010/protorpc"
+	wire "github.com/chai2010/protorpc/wire.pb"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+)
+
+// The conformance suite calls the conformance services, which a server
+// of the protocol serves to run it:
+//
+//	EchoService.Echo(EchoRequest) returns (EchoResponse)
+//		replies the msg of the request
+//	ArithService.Div(ArithRequest) returns (ArithResponse)
+//		replies a / b, or the error "divide by zero" if b is 0
+//
+// The messages are defined in conformance.proto, and are the messages
+// of examples/message.pb on the wire.
+
+// RegisterConformanceServices registers the conformance services on srv.
+func RegisterConformanceServices(srv *rpc.Server) error {
+	if err := srv.RegisterName("EchoService", new(conformanceEcho)); err != nil {
+		return err
+	}
+	return srv.RegisterName("ArithService", new(conformanceArith))
+}
+
+type conformanceEcho int
+
+func (*conformanceEcho) Echo(args *EchoRequest, reply *EchoResponse) error {
+	reply.Msg = args.Msg
+	return nil
+}
+
+type conformanceArith int
+
+func (*conformanceArith) Div(args *ArithRequest, reply *ArithResponse) error {
+	if args.B == 0 {
+		return errors.New("divide by zero")
+	}
+	reply.C = args.A / args.B
+	return nil
+}
+
+const (
+	conformanceTimeout  = 10 * time.Second
+	conformanceFrameLen = 64 << 20
+	conformanceLargeLen = 4 << 20
+)
+
+// RunConformance runs the wire conformance suite against the server
+// dialed by dial, which serves the conformance services. The suite
+// writes and reads the frames itself, like a client without the
+// handshake, so it checks the wire format of the server rather than
+// the one of this package.
+func RunConformance(t *testing.T, dial func() (net.Conn, error)) {
+	for _, tt := range []struct {
+		name string
+		test func(t *testing.T, c *rawConn)
+	}{
+		{"Framing", testFraming},
+		{"EmptyBody", testEmptyBody},
+		{"Pipelining", testPipelining},
+		{"Checksum", testChecksum},
+		{"BadChecksum", testBadChecksum},
+		{"Compression", testCompression},
+		{"LargeBody", testLargeBody},
+		{"Error", testError},
+		{"UnknownMethod", testUnknownMethod},
+		{"OneWay", testOneWay},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := dial()
+			if err != nil {
+				t.Fatalf("dial: %v", err)
+			}
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(conformanceTimeout))
+			tt.test(t, &rawConn{conn: conn, r: bufio.NewReader(conn)})
+		})
+	}
+}
+
+func testFraming(t *testing.T, c *rawConn) {
+	c.echo(t, 1, "Hello, Protobuf-RPC", false)
+	c.echo(t, 2, "你好, 世界", false)
+}
+
+func testEmptyBody(t *testing.T, c *rawConn) {
+	c.echo(t, 1, "", false)
+}
+
+func testPipelining(t *testing.T, c *rawConn) {
+	const n = 16
+	for id := uint64(1); id <= n; id++ {
+		header, body, err := newRequest(id, "EchoService.Echo", &EchoRequest{Msg: fmt.Sprint("msg ", id)}, id%2 == 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.writeRequest(header, body); err != nil {
+			t.Fatalf("request %d: %v", id, err)
+		}
+	}
+
+	// the responses may come in any order
+	seen := make(map[uint64]bool)
+	for i := 0; i < n; i++ {
+		var reply EchoResponse
+		header := c.readReply(t, &reply)
+		if header.Id < 1 || header.Id > n || seen[header.Id] {
+			t.Fatalf("unexpected response id %d", header.Id)
+		}
+		seen[header.Id] = true
+		if want := fmt.Sprint("msg ", header.Id); reply.Msg != want {
+			t.Fatalf("response %d: expected = %q, got = %q", header.Id, want, reply.Msg)
+		}
+	}
+}
+
+func testChecksum(t *testing.T, c *rawConn) {
+	// a zero checksum means no checksum
+	header, body, err := newRequest(1, "EchoService.Echo", &EchoRequest{Msg: "abc"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header.Checksum = 0
+	if err := c.writeRequest(header, body); err != nil {
+		t.Fatal(err)
+	}
+	var reply EchoResponse
+	if c.readReply(t, &reply); reply.Msg != "abc" {
+		t.Fatalf("expected = %q, got = %q", "abc", reply.Msg)
+	}
+
+	// the checksum of a compressed body is the one of the sent bytes
+	c.echo(t, 2, strings.Repeat("abc", 100), true)
+}
+
+func testBadChecksum(t *testing.T, c *rawConn) {
+	header, body, err := newRequest(1, "EchoService.Echo", &EchoRequest{Msg: "abc"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header.Checksum ^= 1
+	if err := c.writeRequest(header, body); err != nil {
+		t.Fatal(err)
+	}
+
+	// the server replies an error, and may close the connection
+	resp, _, err := c.readResponse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Id != 1 || resp.Error == "" {
+		t.Fatalf("expected the error of request 1, got = %v", resp)
+	}
+}
+
+func testCompression(t *testing.T, c *rawConn) {
+	c.echo(t, 1, strings.Repeat("Hello, 世界.", 100), true)
+
+	// incompressible bodies may be sent compressed too
+	c.echo(t, 2, "x", true)
+}
+
+func testLargeBody(t *testing.T, c *rawConn) {
+	s := strings.Repeat("0123456789abcdef", conformanceLargeLen/16)
+	c.echo(t, 1, s, false)
+	c.echo(t, 2, s, true)
+}
+
+func testError(t *testing.T, c *rawConn) {
+	header, body, err := newRequest(1, "ArithService.Div", &ArithRequest{A: 1, B: 0}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.writeRequest(header, body); err != nil {
+		t.Fatal(err)
+	}
+	resp, raw, err := c.readResponse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Id != 1 || resp.Error != "divide by zero" {
+		t.Fatalf(`expected the error "divide by zero" of request 1, got = %v`, resp)
+	}
+	if len(raw) != 0 {
+		t.Fatalf("expected an empty body with the error, got %d bytes", len(raw))
+	}
+
+	// the connection is still usable
+	header, body, err = newRequest(2, "ArithService.Div", &ArithRequest{A: 7, B: 2}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.writeRequest(header, body); err != nil {
+		t.Fatal(err)
+	}
+	var reply ArithResponse
+	if c.readReply(t, &reply); reply.C != 3 {
+		t.Fatalf("ArithService.Div: expected = %d, got = %d", 3, reply.C)
+	}
+}
+
+func testUnknownMethod(t *testing.T, c *rawConn) {
+	header, body, err := newRequest(1, "EchoService.Unknown", &EchoRequest{Msg: "abc"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.writeRequest(header, body); err != nil {
+		t.Fatal(err)
+	}
+	resp, _, err := c.readResponse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Id != 1 || resp.Error == "" {
+		t.Fatalf("expected the error of request 1, got = %v", resp)
+	}
+
+	// the body of the unknown method is discarded
+	c.echo(t, 2, "abc", false)
+}
+
+func testOneWay(t *testing.T, c *rawConn) {
+	// the error of a one-way call is not sent either
+	for i, args := range []proto.Message{&EchoRequest{Msg: "abc"}, &ArithRequest{A: 1, B: 0}} {
+		id, method := uint64(i+1), "EchoService.Echo"
+		if i == 1 {
+			method = "ArithService.Div"
+		}
+		header, body, err := newRequest(id, method, args, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		header.OneWay = true
+		if err := c.writeRequest(header, body); err != nil {
+			t.Fatalf("request %d: %v", id, err)
+		}
+	}
+
+	// the first response is the one of the next call
+	c.echo(t, 3, "def", false)
+}
+
+// rawConn writes and reads the frames of the protocol.
+type rawConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// newRequest returns the header and body of a request, with a crc32
+// checksum, compressed by snappy if compress is set.
+func newRequest(id uint64, method string, args proto.Message, compress bool) (*wire.RequestHeader, []byte, error) {
+	raw, err := proto.Marshal(args)
+	if err != nil {
+		return nil, nil, err
+	}
+	header := &wire.RequestHeader{
+		Id:            id,
+		Method:        method,
+		RawRequestLen: uint32(len(raw)),
+	}
+	body := raw
+	if compress {
+		body = snappy.Encode(nil, raw)
+		header.SnappyCompressedRequestLen = uint32(len(body))
+	}
+	header.Checksum = crc32.ChecksumIEEE(body)
+	return header, body, nil
+}
+
+func (c *rawConn) writeRequest(header *wire.RequestHeader, body []byte) error {
+	data, err := proto.Marshal(header)
+	if err != nil {
+		return err
+	}
+	if err := protorpc.WriteFrame(c.conn, data); err != nil {
+		return err
+	}
+	return protorpc.WriteFrame(c.conn, body)
+}
+
+// readResponse reads a response, and returns its header and its body,
+// checked and decompressed.
+func (c *rawConn) readResponse() (*wire.ResponseHeader, []byte, error) {
+	data, err := protorpc.ReadFrame(c.r, nil, conformanceFrameLen)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read response header: %v", err)
+	}
+	header := new(wire.ResponseHeader)
+	if err := proto.Unmarshal(data, header); err != nil {
+		return nil, nil, fmt.Errorf("decode response header: %v", err)
+	}
+	body, err := protorpc.ReadFrame(c.r, nil, conformanceFrameLen)
+	if err != nil {
+		return nil, nil, fmt.Errorf("response %d: read body: %v", header.Id, err)
+	}
+
+	// the response has the checksum type of the request: crc32
+	switch header.ChecksumType {
+	case wire.ChecksumType_CHECKSUM_DEFAULT:
+		if header.Checksum != 0 && crc32.ChecksumIEEE(body) != header.Checksum {
+			return nil, nil, fmt.Errorf("response %d: checksum mismatch", header.Id)
+		}
+	case wire.ChecksumType_CHECKSUM_CRC32_IEEE:
+		if crc32.ChecksumIEEE(body) != header.Checksum {
+			return nil, nil, fmt.Errorf("response %d: checksum mismatch", header.Id)
+		}
+	default:
+		return nil, nil, fmt.Errorf("response %d: unexpected checksum type %v", header.Id, header.ChecksumType)
+	}
+
+	// the response is compressed by snappy, like the request, or not
+	if header.SnappyCompressedResponseLen != 0 {
+		switch wire.CompressionType(header.Compression) {
+		case wire.CompressionType_COMPRESSION_DEFAULT, wire.CompressionType_COMPRESSION_SNAPPY:
+		default:
+			return nil, nil, fmt.Errorf("response %d: unexpected compression %d", header.Id, header.Compression)
+		}
+		if body, err = snappy.Decode(nil, body); err != nil {
+			return nil, nil, fmt.Errorf("response %d: decompress body: %v", header.Id, err)
+		}
+	}
+	if uint32(len(body)) != header.RawResponseLen {
+		return nil, nil, fmt.Errorf("response %d: expected body length = %d, got = %d", header.Id, header.RawResponseLen, len(body))
+	}
+	return header, body, nil
+}
+
+// readReply reads a successful response into reply.
+func (c *rawConn) readReply(t *testing.T, reply proto.Message) *wire.ResponseHeader {
+	t.Helper()
+
+	header, body, err := c.readResponse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Error != "" {
+		t.Fatalf("response %d: %s", header.Id, header.Error)
+	}
+	if err := proto.Unmarshal(body, reply); err != nil {
+		t.Fatalf("response %d: decode body: %v", header.Id, err)
+	}
+	return header
+}
+
+// echo calls EchoService.Echo, and checks the reply.
+func (c *rawConn) echo(t *testing.T, id uint64, s string, compress bool) {
+	t.Helper()
+
+	header, body, err := newRequest(id, "EchoService.Echo", &EchoRequest{Msg: s}, compress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.writeRequest(header, body); err != nil {
+		t.Fatalf("request %d: %v", id, err)
+	}
+	var reply EchoResponse
+	resp := c.readReply(t, &reply)
+	if resp.Id != id {
+		t.Fatalf("expected response id = %d, got = %d", id, resp.Id)
+	}
+	if reply.Msg != s {
+		t.Fatalf("response %d: expected %d bytes, got %d bytes", id, len(s), len(reply.Msg))
+	}
+}
@@ -0,0 +1,128 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: protorpctest/conformance.proto
+
+/*
+Package protorpctest is a generated protocol buffer package.
+
+It is generated from these files:
+
+	protorpctest/conformance.proto
+
+It has these top-level messages:
+
+	EchoRequest
+	EchoResponse
+	ArithRequest
+	ArithResponse
+*/
+package protorpctest
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+type EchoRequest struct {
+	Msg string `protobuf:"bytes,1,opt,name=msg" json:"msg,omitempty"`
+}
+
+func (m *EchoRequest) Reset()                    { *m = EchoRequest{} }
+func (m *EchoRequest) String() string            { return proto.CompactTextString(m) }
+func (*EchoRequest) ProtoMessage()               {}
+func (*EchoRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+func (m *EchoRequest) GetMsg() string {
+	if m != nil {
+		return m.Msg
+	}
+	return ""
+}
+
+type EchoResponse struct {
+	Msg string `protobuf:"bytes,1,opt,name=msg" json:"msg,omitempty"`
+}
+
+func (m *EchoResponse) Reset()                    { *m = EchoResponse{} }
+func (m *EchoResponse) String() string            { return proto.CompactTextString(m) }
+func (*EchoResponse) ProtoMessage()               {}
+func (*EchoResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *EchoResponse) GetMsg() string {
+	if m != nil {
+		return m.Msg
+	}
+	return ""
+}
+
+type ArithRequest struct {
+	A int32 `protobuf:"varint,1,opt,name=a" json:"a,omitempty"`
+	B int32 `protobuf:"varint,2,opt,name=b" json:"b,omitempty"`
+}
+
+func (m *ArithRequest) Reset()                    { *m = ArithRequest{} }
+func (m *ArithRequest) String() string            { return proto.CompactTextString(m) }
+func (*ArithRequest) ProtoMessage()               {}
+func (*ArithRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+func (m *ArithRequest) GetA() int32 {
+	if m != nil {
+		return m.A
+	}
+	return 0
+}
+
+func (m *ArithRequest) GetB() int32 {
+	if m != nil {
+		return m.B
+	}
+	return 0
+}
+
+type ArithResponse struct {
+	C int32 `protobuf:"varint,1,opt,name=c" json:"c,omitempty"`
+}
+
+func (m *ArithResponse) Reset()                    { *m = ArithResponse{} }
+func (m *ArithResponse) String() string            { return proto.CompactTextString(m) }
+func (*ArithResponse) ProtoMessage()               {}
+func (*ArithResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *ArithResponse) GetC() int32 {
+	if m != nil {
+		return m.C
+	}
+	return 0
+}
+
+func init() {
+	proto.RegisterType((*EchoRequest)(nil), "protorpctest.EchoRequest")
+	proto.RegisterType((*EchoResponse)(nil), "protorpctest.EchoResponse")
+	proto.RegisterType((*ArithRequest)(nil), "protorpctest.ArithRequest")
+	proto.RegisterType((*ArithResponse)(nil), "protorpctest.ArithResponse")
+}
+
+func init() { proto.RegisterFile("protorpctest/conformance.proto", fileDescriptor0) }
+
+var fileDescriptor0 = []byte{
+	// 152 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x92, 0x2b, 0x28, 0xca, 0x2f,
+	0xc9, 0x2f, 0x2a, 0x48, 0x2e, 0x49, 0x2d, 0x2e, 0xd1, 0x4f, 0xce, 0xcf, 0x4b, 0xcb, 0x2f, 0xca,
+	0x4d, 0xcc, 0x4b, 0x4e, 0xd5, 0x03, 0x4b, 0x08, 0xf1, 0x20, 0xcb, 0x2b, 0xc9, 0x73, 0x71, 0xbb,
+	0x26, 0x67, 0xe4, 0x07, 0xa5, 0x16, 0x96, 0xa6, 0x16, 0x97, 0x08, 0x09, 0x70, 0x31, 0xe7, 0x16,
+	0xa7, 0x4b, 0x30, 0x2a, 0x30, 0x6a, 0x70, 0x06, 0x81, 0x98, 0x4a, 0x0a, 0x5c, 0x3c, 0x10, 0x05,
+	0xc5, 0x05, 0xf9, 0x79, 0xc5, 0xa9, 0x58, 0x54, 0x68, 0x71, 0xf1, 0x38, 0x16, 0x65, 0x96, 0x64,
+	0xc0, 0xcc, 0xe0, 0xe1, 0x62, 0x4c, 0x04, 0xcb, 0xb3, 0x06, 0x31, 0x26, 0x82, 0x78, 0x49, 0x12,
+	0x4c, 0x10, 0x5e, 0x92, 0x92, 0x2c, 0x17, 0x2f, 0x54, 0x2d, 0xd4, 0x38, 0x1e, 0x2e, 0xc6, 0x64,
+	0x98, 0xe2, 0x64, 0x27, 0xbe, 0x28, 0x14, 0xd7, 0x25, 0xb1, 0x81, 0x79, 0xc6, 0x80, 0x01, 0x00,
+	0x8d, 0x9c, 0x54, 0x5a, 0xd4, 0x00, 0x00, 0x00,
+}
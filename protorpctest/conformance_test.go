@@ -0,0 +1,62 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpctest
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/chai2010/protorpc"
+)
+
+func TestConformance(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts *protorpc.Options
+	}{
+		{"Default", nil},
+		{"WriteBatch", &protorpc.Options{WriteBatchLatency: time.Millisecond}},
+		{"Scheduler", &protorpc.Options{Scheduler: protorpc.NewScheduler(1, 0)}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := StartServer(t, tt.opts, RegisterConformanceServices)
+			RunConformance(t, srv.Dial)
+		})
+	}
+}
+
+func TestServer(t *testing.T) {
+	srv := StartServer(t, nil, RegisterConformanceServices)
+
+	for _, opts := range []*protorpc.Options{nil, {Handshake: true, Compression: protorpc.CompressionGzip}} {
+		client, err := srv.NewClient(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var reply EchoResponse
+		if err := client.Call("EchoService.Echo", &EchoRequest{Msg: "abc"}, &reply); err != nil {
+			t.Fatal(err)
+		}
+		if reply.Msg != "abc" {
+			t.Fatalf("expected = %q, got = %q", "abc", reply.Msg)
+		}
+		client.Close()
+	}
+
+	// the connections are closed with the server
+	client, err := srv.NewClient(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	srv.Close()
+	if err := client.Call("EchoService.Echo", &EchoRequest{}, new(EchoResponse)); err == nil {
+		t.Fatal("expected error after Close")
+	}
+	if _, err := srv.Dial(); err != net.ErrClosed {
+		t.Fatalf("expected net.ErrClosed, got = %v", err)
+	}
+}
@@ -0,0 +1,7 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:generate protoc -I.. --go_out=.. ../protorpctest/conformance.proto
+
+package protorpctest
@@ -0,0 +1,205 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package protorpctest provides utilities for Protobuf-RPC testing:
+// an in-memory listener, a server of registered services on it, and
+// a conformance suite of the wire protocol.
+//
+// A test starts a server with the services to test, and calls them
+// without binding a port:
+//
+//	srv := protorpctest.StartServer(t, nil, func(srv *rpc.Server) error {
+//		return service.RegisterEchoService(srv, new(Echo))
+//	})
+//	conn, err := srv.Dial()
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	client := service.NewEchoServiceClient(conn)
+//	defer client.Close()
+package protorpctest
+
+import (
+	"context"
+	"net"
+	"net/rpc"
+	"sync"
+	"testing"
+
+	"github.com/chai2010/protorpc"
+)
+
+// Listener is an in-memory net.Listener. The connections are made by
+// Dial, and are synchronous full duplex net.Pipe connections.
+type Listener struct {
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+// NewListener returns a new in-memory listener.
+func NewListener() *Listener {
+	return &Listener{
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+}
+
+// Accept waits for and returns the next connection made by Dial.
+// It returns net.ErrClosed once the listener is closed.
+func (l *Listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close closes the listener. The accepted connections are not closed.
+func (l *Listener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+// Addr returns the address of the listener.
+func (l *Listener) Addr() net.Addr {
+	return pipeAddr{}
+}
+
+// Dial connects to the listener, and waits until the connection is
+// accepted.
+func (l *Listener) Dial() (net.Conn, error) {
+	return l.DialContext(context.Background())
+}
+
+// DialContext is like Dial, but gives up when ctx is done.
+func (l *Listener) DialContext(ctx context.Context) (net.Conn, error) {
+	cliConn, srvConn := net.Pipe()
+	select {
+	case l.conns <- srvConn:
+		return cliConn, nil
+	case <-l.done:
+		cliConn.Close()
+		srvConn.Close()
+		return nil, net.ErrClosed
+	case <-ctx.Done():
+		cliConn.Close()
+		srvConn.Close()
+		return nil, ctx.Err()
+	}
+}
+
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "protorpctest" }
+
+// Server is a Protobuf-RPC server listening on an in-memory Listener.
+type Server struct {
+	Listener *Listener
+	RPC      *rpc.Server       // the services, registered before Start
+	Options  *protorpc.Options // the options of the server codecs, nil means the defaults
+
+	mutex  sync.Mutex
+	conns  map[net.Conn]bool
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewServer returns a new server, not started, with the given options.
+func NewServer(opts *protorpc.Options) *Server {
+	return &Server{
+		Listener: NewListener(),
+		RPC:      rpc.NewServer(),
+		Options:  opts,
+		conns:    make(map[net.Conn]bool),
+	}
+}
+
+// StartServer starts a server with the given options and the services
+// registered by register, and closes it when the test ends.
+func StartServer(tb testing.TB, opts *protorpc.Options, register func(srv *rpc.Server) error) *Server {
+	tb.Helper()
+
+	s := NewServer(opts)
+	if register != nil {
+		if err := register(s.RPC); err != nil {
+			tb.Fatalf("protorpctest: register services: %v", err)
+		}
+	}
+	s.Start()
+	tb.Cleanup(func() { s.Close() })
+	return s
+}
+
+// Start serves the connections of the listener.
+func (s *Server) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := s.Listener.Accept()
+			if err != nil {
+				return
+			}
+			if !s.track(conn, true) {
+				conn.Close()
+				return
+			}
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				defer s.track(conn, false)
+				s.RPC.ServeCodec(protorpc.NewServerCodecWithOptions(conn, s.Options))
+			}()
+		}
+	}()
+}
+
+// track adds or removes an accepted connection. It returns false if
+// the server is closed.
+func (s *Server) track(conn net.Conn, add bool) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !add {
+		delete(s.conns, conn)
+		return true
+	}
+	if s.closed {
+		return false
+	}
+	s.conns[conn] = true
+	return true
+}
+
+// Dial returns a new connection to the server.
+func (s *Server) Dial() (net.Conn, error) {
+	return s.Listener.Dial()
+}
+
+// NewClient returns a new client of the server with the given options.
+func (s *Server) NewClient(opts *protorpc.Options) (*rpc.Client, error) {
+	conn, err := s.Dial()
+	if err != nil {
+		return nil, err
+	}
+	return protorpc.NewClientWithOptions(conn, opts), nil
+}
+
+// Close closes the listener and the connections, and waits until the
+// connections are served.
+func (s *Server) Close() error {
+	s.Listener.Close()
+
+	s.mutex.Lock()
+	s.closed = true
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mutex.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
@@ -7,13 +7,13 @@ package protorpc_test
 import (
 	"errors"
 	"log"
-	"net"
 	"net/rpc"
 	"testing"
 	"time"
 
 	"github.com/chai2010/protorpc"
 	msg "github.com/chai2010/protorpc/examples/message.pb"
+	"github.com/chai2010/protorpc/protorpctest"
 )
 
 type Arith int
@@ -49,14 +49,10 @@ func (t *Echo) Echo(args *msg.EchoRequest, reply *msg.EchoResponse) error {
 }
 
 func TestInternalMessagePkg(t *testing.T) {
-	err := listenAndServeArithAndEchoService("tcp", "127.0.0.1:1414")
+	srv := protorpctest.StartServer(t, nil, registerArithAndEchoService)
+	conn, err := srv.Dial()
 	if err != nil {
-		log.Fatalf("listenAndServeArithAndEchoService: %v", err)
-	}
-
-	conn, err := net.Dial("tcp", "127.0.0.1:1414")
-	if err != nil {
-		t.Fatalf(`net.Dial("tcp", "127.0.0.1:1414"): %v`, err)
+		t.Fatalf(`srv.Dial(): %v`, err)
 	}
 	client := rpc.NewClientWithCodec(protorpc.NewClientCodec(conn))
 	defer client.Close()
@@ -68,29 +64,25 @@ func TestInternalMessagePkg(t *testing.T) {
 	testEchoClientAsync(t, client)
 }
 
-func listenAndServeArithAndEchoService(network, addr string) error {
-	clients, err := net.Listen(network, addr)
-	if err != nil {
-		return err
-	}
-	srv := rpc.NewServer()
+func registerArithAndEchoService(srv *rpc.Server) error {
 	if err := srv.RegisterName("ArithService", new(Arith)); err != nil {
 		return err
 	}
-	if err := srv.RegisterName("EchoService", new(Echo)); err != nil {
-		return err
+	return srv.RegisterName("EchoService", new(Echo))
+}
+
+// newTestClient starts a server with serverOpts and the services
+// registered by register, and returns a client of it with clientOpts.
+// The client and the server are closed when the test ends.
+func newTestClient(t *testing.T, clientOpts, serverOpts *protorpc.Options, register func(srv *rpc.Server) error) *rpc.Client {
+	t.Helper()
+	srv := protorpctest.StartServer(t, serverOpts, register)
+	client, err := srv.NewClient(clientOpts)
+	if err != nil {
+		t.Fatal(err)
 	}
-	go func() {
-		for {
-			conn, err := clients.Accept()
-			if err != nil {
-				log.Printf("clients.Accept(): %v\n", err)
-				continue
-			}
-			go srv.ServeCodec(protorpc.NewServerCodec(conn))
-		}
-	}()
-	return nil
+	t.Cleanup(func() { client.Close() })
+	return client
 }
 
 func testArithClient(t *testing.T, client *rpc.Client) {
@@ -0,0 +1,151 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// DefaultSchedulerAging is the aging of NewScheduler when aging is 0.
+const DefaultSchedulerAging = 100 * time.Millisecond
+
+// A Scheduler limits the calls served at once by the server codecs
+// sharing it (see Options.Scheduler). When the limit is reached, the
+// received calls wait, and are started by priority once running calls
+// return: the calls with the highest priority (see the Priority call
+// option) first, and in the order they were received for the same
+// priority.
+//
+// Waiting calls age, so that calls of low priority do not starve: a call
+// gains one priority level for each aging period it waits.
+type Scheduler struct {
+	limit int
+	aging time.Duration
+	start time.Time // the origin of the keys
+
+	mutex   sync.Mutex
+	running int
+	waiting schedQueue
+	seq     uint64 // the number of submitted items
+}
+
+// NewScheduler returns a scheduler serving at most maxCalls calls at once.
+// A maxCalls below 1 means 1, an aging of 0 means DefaultSchedulerAging.
+func NewScheduler(maxCalls int, aging time.Duration) *Scheduler {
+	if maxCalls < 1 {
+		maxCalls = 1
+	}
+	if aging <= 0 {
+		aging = DefaultSchedulerAging
+	}
+	return &Scheduler{limit: maxCalls, aging: aging, start: time.Now()}
+}
+
+// Running returns the number of calls being served.
+func (s *Scheduler) Running() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.running
+}
+
+// Waiting returns the number of calls waiting to be served.
+func (s *Scheduler) Waiting() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.waiting)
+}
+
+// schedItem is a call submitted to a scheduler.
+type schedItem struct {
+	key   float64 // the lowest key is started first
+	seq   uint64  // the submit order of items with the same key
+	index int     // the index in the waiting queue, -1 if not waiting
+	run   func()  // called when the call may be served
+}
+
+func newSchedItem(run func()) *schedItem {
+	return &schedItem{index: -1, run: run}
+}
+
+// submit starts item with the given priority received at t, at once if
+// the limit is not reached, or once other calls are done. The run
+// function of item is called without the lock of the scheduler.
+func (s *Scheduler) submit(item *schedItem, priority int32, t time.Time) {
+	// a call waiting since t with priority p is started like a call of
+	// priority 0 waiting since p aging periods before t
+	item.key = float64(t.Sub(s.start)) - float64(priority)*float64(s.aging)
+
+	s.mutex.Lock()
+	if s.running < s.limit && len(s.waiting) == 0 {
+		s.running++
+		s.mutex.Unlock()
+		item.run()
+		return
+	}
+	s.seq++
+	item.seq = s.seq
+	heap.Push(&s.waiting, item)
+	s.mutex.Unlock()
+}
+
+// remove removes a waiting item. It reports false if item is not
+// waiting: it was started, or never submitted.
+func (s *Scheduler) remove(item *schedItem) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if item.index < 0 {
+		return false
+	}
+	heap.Remove(&s.waiting, item.index)
+	return true
+}
+
+// done ends a started call, and starts the next waiting call.
+func (s *Scheduler) done() {
+	s.mutex.Lock()
+	if len(s.waiting) == 0 {
+		s.running--
+		s.mutex.Unlock()
+		return
+	}
+	item := heap.Pop(&s.waiting).(*schedItem)
+	s.mutex.Unlock()
+	item.run()
+}
+
+// schedQueue is a heap of the waiting items.
+type schedQueue []*schedItem
+
+func (q schedQueue) Len() int { return len(q) }
+
+func (q schedQueue) Less(i, j int) bool {
+	if q[i].key != q[j].key {
+		return q[i].key < q[j].key
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q schedQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *schedQueue) Push(x interface{}) {
+	item := x.(*schedItem)
+	item.index = len(*q)
+	*q = append(*q, item)
+}
+
+func (q *schedQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	item.index = -1
+	*q = old[:len(old)-1]
+	return item
+}
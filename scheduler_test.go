@@ -0,0 +1,256 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/rpc"
+	"testing"
+	"time"
+
+	msg "github.com/chai2010/protorpc/examples/message.pb"
+	wire "github.com/chai2010/protorpc/wire.pb"
+)
+
+func TestSchedulerOrder(t *testing.T) {
+	s := NewScheduler(1, time.Hour)
+	var order []string
+	submit := func(name string, priority int32) *schedItem {
+		item := newSchedItem(func() { order = append(order, name) })
+		s.submit(item, priority, time.Now())
+		return item
+	}
+
+	submit("first", 0)
+	submit("low1", -1)
+	submit("default1", 0)
+	submit("high", 10)
+	canceled := submit("canceled", 20)
+	submit("default2", 0)
+	if !s.remove(canceled) {
+		t.Fatal("expected the waiting call to be removed")
+	}
+	if s.Running() != 1 || s.Waiting() != 4 {
+		t.Fatalf("expected 1 running and 4 waiting calls, got %d and %d", s.Running(), s.Waiting())
+	}
+	for i := 0; i < 5; i++ {
+		s.done()
+	}
+
+	want := []string{"first", "high", "default1", "default2", "low1"}
+	if len(order) != len(want) {
+		t.Fatalf("expected = %q, got = %q", want, order)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("expected = %q, got = %q", want, order)
+		}
+	}
+	if s.Running() != 0 || s.Waiting() != 0 {
+		t.Fatalf("expected no calls, got %d running and %d waiting", s.Running(), s.Waiting())
+	}
+	if s.remove(canceled) {
+		t.Fatal("expected the removed call not to be waiting")
+	}
+}
+
+func TestSchedulerAging(t *testing.T) {
+	const aging = time.Second
+	s := NewScheduler(1, aging)
+	var order []string
+	submit := func(name string, priority int32, t time.Time) {
+		s.submit(newSchedItem(func() { order = append(order, name) }), priority, t)
+	}
+
+	t0 := time.Now()
+	submit("first", 0, t0)
+	submit("old", 0, t0)
+	submit("new", 2, t0.Add(3*aging))   // gained 2 levels, but waited 3 periods less
+	submit("newer", 2, t0.Add(aging/2)) // waited half a period less, gained 2 levels
+	for i := 0; i < 3; i++ {
+		s.done()
+	}
+
+	want := []string{"first", "newer", "old", "new"}
+	for i := range want {
+		if i >= len(order) || order[i] != want[i] {
+			t.Fatalf("expected = %q, got = %q", want, order)
+		}
+	}
+}
+
+type testSched struct {
+	started chan string
+	release chan struct{}
+}
+
+// Block waits for release.
+func (t *testSched) Block(args *msg.EchoRequest, reply *msg.EchoResponse) error {
+	t.started <- "block"
+	<-t.release
+	return nil
+}
+
+// Record records the start of the call.
+func (t *testSched) Record(args *msg.EchoRequest, reply *msg.EchoResponse) error {
+	t.started <- args.Msg
+	reply.Msg = args.Msg
+	return nil
+}
+
+func newTestSched() *testSched {
+	return &testSched{started: make(chan string, 10), release: make(chan struct{})}
+}
+
+func (t *testSched) register(srv *rpc.Server) error {
+	if err := srv.RegisterName("SchedService", t); err != nil {
+		return err
+	}
+	return newTestStream().register(srv)
+}
+
+// waitScheduler waits until the scheduler has n waiting calls.
+func waitScheduler(t *testing.T, s *Scheduler, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Waiting() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d waiting calls, got %d", n, s.Waiting())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSchedulerServer(t *testing.T) {
+	sched, s := newTestSched(), NewScheduler(1, time.Hour)
+	client := newTestClient(t, &Options{Handshake: true}, &Options{Scheduler: s}, sched.register)
+	ctx := context.Background()
+
+	block := client.Go("SchedService.Block", &msg.EchoRequest{}, new(msg.EchoResponse), nil)
+	if got := <-sched.started; got != "block" {
+		t.Fatalf("expected the block call, got %q", got)
+	}
+
+	var calls []*rpc.Call
+	for _, c := range []struct {
+		msg      string
+		priority int32
+	}{{"low", -1}, {"batch", 0}, {"probe", 100}, {"interactive", 10}} {
+		calls = append(calls, GoContext(ctx, client, "SchedService.Record", &msg.EchoRequest{Msg: c.msg}, new(msg.EchoResponse), nil, Priority(c.priority)))
+	}
+	waitScheduler(t, s, 4)
+
+	close(sched.release)
+	if block = <-block.Done; block.Error != nil {
+		t.Fatal(block.Error)
+	}
+	for _, want := range []string{"probe", "interactive", "batch", "low"} {
+		if got := <-sched.started; got != want {
+			t.Fatalf("expected %q to start, got %q", want, got)
+		}
+	}
+	for _, call := range calls {
+		if call = <-call.Done; call.Error != nil {
+			t.Fatal(call.Error)
+		}
+	}
+	if s.Running() != 0 || s.Waiting() != 0 {
+		t.Fatalf("expected no calls, got %d running and %d waiting", s.Running(), s.Waiting())
+	}
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	sched, s := newTestSched(), NewScheduler(1, time.Hour)
+	client := newTestClient(t, &Options{Handshake: true}, &Options{Scheduler: s}, sched.register)
+
+	block := client.Go("SchedService.Block", &msg.EchoRequest{}, new(msg.EchoResponse), nil)
+	<-sched.started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	call := GoContext(ctx, client, "SchedService.Record", &msg.EchoRequest{Msg: "canceled"}, new(msg.EchoResponse), nil)
+	waitScheduler(t, s, 1)
+	cancel()
+	if call = <-call.Done; StatusFromError(call.Error).Code != CodeCanceled {
+		t.Fatalf("expected Canceled, got %v", call.Error)
+	}
+	waitScheduler(t, s, 0)
+
+	close(sched.release)
+	<-block.Done
+	var reply msg.EchoResponse
+	if err := client.Call("SchedService.Record", &msg.EchoRequest{Msg: "next"}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-sched.started; got != "next" {
+		t.Fatalf("expected the canceled call not to start, got %q", got)
+	}
+}
+
+func TestSchedulerStream(t *testing.T) {
+	sched, s := newTestSched(), NewScheduler(1, time.Hour)
+	client := newTestClient(t, &Options{Handshake: true}, &Options{Scheduler: s}, sched.register)
+
+	block := client.Go("SchedService.Block", &msg.EchoRequest{}, new(msg.EchoResponse), nil)
+	<-sched.started
+
+	// the messages sent while the stream waits are not lost
+	stream, err := NewStream(context.Background(), client, "StreamService.Concat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []string{"a", "b", "c"} {
+		if err := stream.SendMsg(&msg.EchoRequest{Msg: m}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	waitScheduler(t, s, 1)
+
+	close(sched.release)
+	<-block.Done
+	var reply msg.EchoResponse
+	if err := stream.RecvMsg(&reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Msg != "a,b,c" {
+		t.Fatalf("expected = %q, got = %q", "a,b,c", reply.Msg)
+	}
+}
+
+func TestSchedulerCancelBeforeBody(t *testing.T) {
+	cliConn, srvConn := net.Pipe()
+	defer cliConn.Close()
+	codec := NewServerCodecWithOptions(srvConn, &Options{Scheduler: NewScheduler(1, time.Hour)})
+	defer codec.Close()
+	go func() {
+		writeRequest(cliConn, DefaultOptions(), nil, noneCompressor{}, &wire.RequestHeader{Id: 7, Method: "SchedService.Record"}, &msg.EchoRequest{Msg: "canceled"})
+		io.Copy(ioutil.Discard, cliConn)
+	}()
+
+	// the cancel frame is handled by readLoop while net/rpc reads the body
+	var r rpc.Request
+	if err := codec.ReadRequestHeader(&r); err != nil {
+		t.Fatal(err)
+	}
+	codec.(*serverCodec).cancel(7)
+	var args msg.EchoRequest
+	if err := codec.ReadRequestBody(&args); err != nil {
+		t.Fatal(err)
+	}
+	if args.Msg != "canceled" {
+		t.Fatalf("expected = %q, got = %q", "canceled", args.Msg)
+	}
+	if err := RequestContext(&args).Err(); err != context.Canceled {
+		t.Fatalf("expected the context to be canceled, got %v", err)
+	}
+	if err := codec.WriteResponse(&rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq}, &msg.EchoResponse{}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	wire "github.com/chai2010/protorpc/wire.pb"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Names of the builtin serializers.
+const (
+	SerializationProto = "proto"
+	SerializationJSON  = "json"
+)
+
+// A Serializer marshals the messages of request and response bodies.
+//
+// The serializer of a body is identified on the wire by ID, so both
+// peers must register the same serializer under the same id.
+// The builtin ids are listed in wire.SerializationType.
+//
+// Marshal returns a new slice, which the codec owns. Unmarshal must not
+// keep data, which the codec reuses.
+type Serializer interface {
+	ID() uint32
+	Marshal(m proto.Message) ([]byte, error)
+	Unmarshal(data []byte, m proto.Message) error
+}
+
+var serializers = struct {
+	sync.RWMutex
+	byName map[string]Serializer
+	byID   map[uint32]Serializer
+}{
+	byName: make(map[string]Serializer),
+	byID:   make(map[uint32]Serializer),
+}
+
+func init() {
+	RegisterSerializer(SerializationProto, protoSerializer{})
+	RegisterSerializer(SerializationJSON, jsonSerializer{})
+}
+
+// RegisterSerializer makes a serializer available by the provided name.
+// If RegisterSerializer is called twice with the same name or id,
+// the last one wins.
+func RegisterSerializer(name string, s Serializer) {
+	serializers.Lock()
+	defer serializers.Unlock()
+	serializers.byName[name] = s
+	serializers.byID[s.ID()] = s
+}
+
+func getSerializer(name string) (Serializer, error) {
+	if name == "" {
+		return protoSerializer{}, nil
+	}
+
+	serializers.RLock()
+	defer serializers.RUnlock()
+	if s, ok := serializers.byName[name]; ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("protorpc: unknown serializer %q", name)
+}
+
+// getSerializerByID returns the serializer of a body.
+// Old peers do not send the serialization id, and use binary protobuf.
+func getSerializerByID(id uint32) (Serializer, error) {
+	if id == uint32(wire.SerializationType_SERIALIZATION_DEFAULT) {
+		return protoSerializer{}, nil
+	}
+
+	serializers.RLock()
+	defer serializers.RUnlock()
+	if s, ok := serializers.byID[id]; ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("protorpc: unknown serialization id %d", id)
+}
+
+// protoMessage returns the args or reply x as a message of the
+// golang/protobuf API. Messages generated by google.golang.org/protobuf
+// implement it; messages which only implement protoreflect.ProtoMessage
+// are wrapped.
+func protoMessage(x interface{}) (proto.Message, bool) {
+	switch m := x.(type) {
+	case proto.Message:
+		return m, true
+	case protoreflect.ProtoMessage:
+		return proto.MessageV1(m), true
+	}
+	return nil, false
+}
+
+// gogoMessage is implemented by the messages generated by gogo/protobuf
+// with the marshaler plugins.
+type gogoMessage interface {
+	Size() int
+	MarshalTo(data []byte) (int, error)
+}
+
+// vtMessage is implemented by the messages generated by vtprotobuf.
+type vtMessage interface {
+	SizeVT() int
+	MarshalToVT(data []byte) (int, error)
+	UnmarshalVT(data []byte) error
+}
+
+// marshaler and unmarshaler are implemented by the messages of
+// gogo/protobuf, and by other messages which marshal themselves.
+type marshaler interface {
+	Marshal() ([]byte, error)
+}
+type unmarshaler interface {
+	Unmarshal(data []byte) error
+}
+
+// protoSerializer marshals binary protobuf. Messages which marshal
+// themselves, like the messages of gogo/protobuf and vtprotobuf, are
+// marshaled and unmarshaled without reflection.
+type protoSerializer struct{}
+
+func (protoSerializer) ID() uint32 {
+	return uint32(wire.SerializationType_SERIALIZATION_PROTO)
+}
+func (protoSerializer) Marshal(m proto.Message) ([]byte, error) {
+	switch x := m.(type) {
+	case vtMessage:
+		return marshalTo(x.SizeVT(), x.MarshalToVT)
+	case gogoMessage:
+		return marshalTo(x.Size(), x.MarshalTo)
+	case marshaler:
+		return x.Marshal()
+	}
+	return marshal(m)
+}
+func (protoSerializer) Unmarshal(data []byte, m proto.Message) error {
+	switch x := m.(type) {
+	case vtMessage:
+		m.Reset()
+		return x.UnmarshalVT(data)
+	case unmarshaler:
+		m.Reset()
+		return x.Unmarshal(data)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+// marshalTo marshals a message of size bytes into a pooled buffer.
+func marshalTo(size int, marshalTo func(data []byte) (int, error)) ([]byte, error) {
+	buf := getBuffer(size)
+	n, err := marshalTo(buf)
+	if err != nil {
+		putBuffer(buf)
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// jsonSerializer marshals protobuf JSON, with the original field names.
+// Unknown fields are ignored.
+type jsonSerializer struct{}
+
+var (
+	jsonMarshaler   = &jsonpb.Marshaler{OrigName: true}
+	jsonUnmarshaler = &jsonpb.Unmarshaler{AllowUnknownFields: true}
+)
+
+func (jsonSerializer) ID() uint32 {
+	return uint32(wire.SerializationType_SERIALIZATION_JSON)
+}
+func (jsonSerializer) Marshal(m proto.Message) ([]byte, error) {
+	buf := bytes.NewBuffer(getBuffer(proto.Size(m) * 2)[:0])
+	if err := jsonMarshaler.Marshal(buf, m); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+func (jsonSerializer) Unmarshal(data []byte, m proto.Message) error {
+	m.Reset()
+	return jsonUnmarshaler.Unmarshal(bytes.NewReader(data), m)
+}
@@ -0,0 +1,248 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"bytes"
+	"net"
+	"net/rpc"
+	"testing"
+
+	msg "github.com/chai2010/protorpc/examples/message.pb"
+	wire "github.com/chai2010/protorpc/wire.pb"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func TestSerializerRoundTrip(t *testing.T) {
+	opts := DefaultOptions()
+	args := &msg.EchoRequest{Msg: "Hello, 世界."}
+
+	for _, name := range []string{SerializationProto, SerializationJSON} {
+		s, err := getSerializer(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		header := &wire.RequestHeader{Id: 1, Method: "EchoService.Echo", Serialization: s.ID()}
+		if err := writeRequest(&buf, opts, newCompressStats(), noneCompressor{}, header, args); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var got wire.RequestHeader
+		if err := readRequestHeader(&buf, opts, &got); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got.Serialization != s.ID() {
+			t.Fatalf("%s: expected serialization = %d, got = %d", name, s.ID(), got.Serialization)
+		}
+		if body := buf.Bytes(); name == SerializationJSON && !bytes.Contains(body, []byte(`"msg":`)) {
+			t.Fatalf("%s: expected JSON body, got = %q", name, body)
+		}
+		var reply msg.EchoRequest
+		if err := readRequestBody(&buf, opts, &got, &reply); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if reply.Msg != args.Msg {
+			t.Fatalf("%s: expected = %q, got = %q", name, args.Msg, reply.Msg)
+		}
+	}
+}
+
+func TestSerializationServerReply(t *testing.T) {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("EchoService", new(testEcho)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, handshake := range []bool{false, true} {
+		cliConn, srvConn := net.Pipe()
+		go srv.ServeCodec(NewServerCodec(srvConn))
+
+		codec := NewClientCodecWithOptions(cliConn, &Options{
+			Handshake:     handshake,
+			Serialization: SerializationJSON,
+		}).(*clientCodec)
+		args := &msg.EchoRequest{Msg: "hi"}
+		if err := codec.WriteRequest(&rpc.Request{ServiceMethod: "EchoService.Echo", Seq: 1}, args); err != nil {
+			t.Fatalf("handshake = %v: WriteRequest: %v", handshake, err)
+		}
+		var resp rpc.Response
+		if err := codec.ReadResponseHeader(&resp); err != nil {
+			t.Fatalf("handshake = %v: ReadResponseHeader: %v", handshake, err)
+		}
+
+		// old servers do not know JSON
+		want := wire.SerializationType_SERIALIZATION_PROTO
+		if handshake {
+			want = wire.SerializationType_SERIALIZATION_JSON
+		}
+		if got := wire.SerializationType(codec.resp.header.Serialization); got != want {
+			t.Fatalf("handshake = %v: expected serialization = %v, got = %v", handshake, want, got)
+		}
+		var reply msg.EchoResponse
+		if err := codec.ReadResponseBody(&reply); err != nil {
+			t.Fatalf("handshake = %v: ReadResponseBody: %v", handshake, err)
+		}
+		if reply.Msg != args.Msg {
+			t.Fatalf("handshake = %v: expected = %q, got = %q", handshake, args.Msg, reply.Msg)
+		}
+		codec.Close()
+	}
+}
+
+func TestSerializerUnknown(t *testing.T) {
+	if _, err := getSerializer("unknown"); err == nil {
+		t.Fatal("expected error for unknown serializer")
+	}
+	if _, err := getSerializerByID(1000); err == nil {
+		t.Fatal("expected error for unknown serialization id")
+	}
+}
+
+// testVTMessage marshals itself like the messages of vtprotobuf.
+type testVTMessage struct {
+	msg.EchoRequest
+	calls int
+}
+
+func (m *testVTMessage) SizeVT() int {
+	m.calls++
+	return proto.Size(&m.EchoRequest)
+}
+func (m *testVTMessage) MarshalToVT(data []byte) (int, error) {
+	m.calls++
+	b, err := proto.Marshal(&m.EchoRequest)
+	return copy(data, b), err
+}
+func (m *testVTMessage) UnmarshalVT(data []byte) error {
+	m.calls++
+	return proto.Unmarshal(data, &m.EchoRequest)
+}
+
+// testGogoMessage marshals itself like the messages of gogo/protobuf.
+type testGogoMessage struct {
+	msg.EchoRequest
+	calls int
+}
+
+func (m *testGogoMessage) Size() int {
+	m.calls++
+	return proto.Size(&m.EchoRequest)
+}
+func (m *testGogoMessage) MarshalTo(data []byte) (int, error) {
+	m.calls++
+	b, err := proto.Marshal(&m.EchoRequest)
+	return copy(data, b), err
+}
+func (m *testGogoMessage) Unmarshal(data []byte) error {
+	m.calls++
+	return proto.Unmarshal(data, &m.EchoRequest)
+}
+
+// testMarshalerMessage only has the plain Marshal and Unmarshal methods.
+type testMarshalerMessage struct {
+	msg.EchoRequest
+	calls int
+}
+
+func (m *testMarshalerMessage) Marshal() ([]byte, error) {
+	m.calls++
+	return proto.Marshal(&m.EchoRequest)
+}
+func (m *testMarshalerMessage) Unmarshal(data []byte) error {
+	m.calls++
+	return proto.Unmarshal(data, &m.EchoRequest)
+}
+
+func TestSerializerFastPath(t *testing.T) {
+	s := protoSerializer{}
+	want := &msg.EchoRequest{Msg: "abc"}
+
+	vt := &testVTMessage{EchoRequest: *want}
+	data, err := s.Marshal(vt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vt.calls != 2 {
+		t.Fatalf("vtprotobuf: expected SizeVT and MarshalToVT to be called, got %d calls", vt.calls)
+	}
+	var got msg.EchoRequest
+	if err := proto.Unmarshal(data, &got); err != nil || got.Msg != want.Msg {
+		t.Fatalf("vtprotobuf: expected = %v, got = %v, %v", want, &got, err)
+	}
+	vt = new(testVTMessage)
+	if err := s.Unmarshal(data, vt); err != nil || vt.Msg != want.Msg {
+		t.Fatalf("vtprotobuf: expected = %v, got = %v, %v", want, &vt.EchoRequest, err)
+	}
+	if vt.calls != 1 {
+		t.Fatalf("vtprotobuf: expected UnmarshalVT to be called, got %d calls", vt.calls)
+	}
+
+	gogo := &testGogoMessage{EchoRequest: *want}
+	data, err = s.Marshal(gogo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gogo.calls != 2 {
+		t.Fatalf("gogo: expected Size and MarshalTo to be called, got %d calls", gogo.calls)
+	}
+	gogo = new(testGogoMessage)
+	if err := s.Unmarshal(data, gogo); err != nil || gogo.Msg != want.Msg {
+		t.Fatalf("gogo: expected = %v, got = %v, %v", want, &gogo.EchoRequest, err)
+	}
+	if gogo.calls != 1 {
+		t.Fatalf("gogo: expected Unmarshal to be called, got %d calls", gogo.calls)
+	}
+
+	plain := &testMarshalerMessage{EchoRequest: *want}
+	data, err = s.Marshal(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain.calls != 1 {
+		t.Fatalf("marshaler: expected Marshal to be called, got %d calls", plain.calls)
+	}
+	plain = new(testMarshalerMessage)
+	if err := s.Unmarshal(data, plain); err != nil || plain.Msg != want.Msg {
+		t.Fatalf("marshaler: expected = %v, got = %v, %v", want, &plain.EchoRequest, err)
+	}
+	if plain.calls != 1 {
+		t.Fatalf("marshaler: expected Unmarshal to be called, got %d calls", plain.calls)
+	}
+}
+
+// testReflectMessage only implements the google.golang.org/protobuf API.
+type testReflectMessage struct {
+	protoreflect.ProtoMessage
+}
+
+func TestSerializerProtoReflect(t *testing.T) {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("EchoService", new(testEcho)); err != nil {
+		t.Fatal(err)
+	}
+	cliConn, srvConn := net.Pipe()
+	go srv.ServeCodec(NewServerCodec(srvConn))
+
+	client := NewClientWithOptions(cliConn, nil)
+	defer client.Close()
+
+	newMessage := func(m proto.Message) (*dynamicpb.Message, protoreflect.FieldDescriptor) {
+		desc := proto.MessageReflect(m).Descriptor()
+		return dynamicpb.NewMessage(desc), desc.Fields().ByName("msg")
+	}
+	args, field := newMessage(&msg.EchoRequest{})
+	args.Set(field, protoreflect.ValueOfString("abc"))
+	reply, field := newMessage(&msg.EchoResponse{})
+
+	if err := client.Call("EchoService.Echo", testReflectMessage{args}, testReflectMessage{reply}); err != nil {
+		t.Fatal(err)
+	}
+	if got := reply.Get(field).String(); got != "abc" {
+		t.Fatalf("expected = %q, got = %q", "abc", got)
+	}
+}
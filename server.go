@@ -5,98 +5,772 @@
 package protorpc
 
 import (
+	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/rpc"
 	"sync"
+	"time"
 
 	wire "github.com/chai2010/protorpc/wire.pb"
 	"github.com/golang/protobuf/proto"
 )
 
 type serverCodec struct {
-	r io.Reader
-	w io.Writer
-	c io.Closer
+	r     *bufio.Reader
+	w     io.Writer
+	c     io.Closer
+	opts  *Options
+	stats *compressStats
+
+	mux    *mux         // writes the frames
+	chunks *assembler   // the requests being received in chunks
+	flight *flightLimit // the received bytes held by the codec
 
 	// temporary work space
-	reqHeader wire.RequestHeader
+	req       *serverRequest // the request whose body is read next
+	reqHeader *wire.RequestHeader
+	reqBody   []byte
+	recvTime  time.Time // when the header of the frame returned by recv was read
+
+	started   bool       // the mode of the connection is detected
+	peer      *Peer      // the client, known once the mode is detected
+	keepalive *keepalive // nil without Options.KeepaliveInterval
+	done      chan struct{}
+	doneOnce  sync.Once
+
+	// ctx is the parent of the contexts of the calls, canceled once the
+	// connection is not read anymore
+	ctx         context.Context
+	cancelCalls context.CancelFunc
+
+	sched       *Scheduler    // nil without Options.Scheduler
+	readyNotify chan struct{} // signaled when ready or readErr change
 
 	// Package rpc expects uint64 request IDs.
 	// We assign uint64 sequence numbers to incoming requests
 	// but save the original request ID in the pending map.
 	// When rpc responds, we use the sequence number in
 	// the response to find the original request ID.
-	mutex   sync.Mutex // protects seq, pending
+	mutex   sync.Mutex // protects the fields below
 	seq     uint64
-	pending map[uint64]uint64
+	pending map[uint64]*serverRequest
+
+	// A canceled request is moved from pending to canceled, its response
+	// is dropped.
+	seqs     map[uint64]uint64 // map original request ID to sequence number
+	canceled map[uint64]*serverRequest
+
+	streams map[uint64]*ServerStream // map original request ID to open streams
+
+	// With Options.Scheduler, the frames are read by readLoop, and the
+	// requests wait in queued until the scheduler starts them.
+	queued  map[uint64]*queuedCall // map original request ID to waiting calls
+	ready   []*queuedCall          // the started calls, in order
+	readErr error                  // the error which ended readLoop
+}
+
+// queuedCall is a request read by readLoop, until ReadRequestHeader
+// returns it.
+type queuedCall struct {
+	header   *wire.RequestHeader
+	body     []byte
+	recvTime time.Time
+	item     *schedItem
+	canceled bool // protected by the mutex of the codec
+}
+
+// serverRequest is the state of a request saved until its response is sent.
+type serverRequest struct {
+	id            uint64 // original request ID
+	method        string
+	compressor    Compressor // the response uses the compressor of the request
+	checksumType  uint32     // the checksum type of the request
+	serialization uint32     // and the serializer of the request
+
+	deadline  time.Time // zero if the request has no timeout
+	oneWay    bool      // no response is sent
+	scheduled bool      // started by the scheduler
+
+	ctx  context.Context // the parent of the context of the handler
+	span Span            // nil without Options.Tracer
+
+	args   interface{}   // the args of the request, the key of call
+	stream *ServerStream // nil if the request is not a stream
+
+	// protected by the mutex of the codec, the request may be canceled
+	// by readLoop while its body is read
+	call     *serverCall
+	canceled bool
 }
 
 // NewServerCodec returns a serverCodec that communicates with the ClientCodec
 // on the other end of the given conn.
 func NewServerCodec(conn io.ReadWriteCloser) rpc.ServerCodec {
-	return &serverCodec{
-		r:       conn,
-		w:       conn,
-		c:       conn,
-		pending: make(map[uint64]uint64),
+	return NewServerCodecWithOptions(conn, nil)
+}
+
+// NewServerCodecWithOptions is like NewServerCodec but uses the given options.
+// A nil opts means DefaultOptions().
+func NewServerCodecWithOptions(conn io.ReadWriteCloser, opts *Options) rpc.ServerCodec {
+	opts = opts.clone()
+	c := &serverCodec{
+		r:        opts.newReader(conn),
+		w:        opts.newWriter(conn),
+		c:        conn,
+		opts:     opts,
+		stats:    newCompressStats(),
+		done:     make(chan struct{}),
+		pending:  make(map[uint64]*serverRequest),
+		seqs:     make(map[uint64]uint64),
+		canceled: make(map[uint64]*serverRequest),
+		streams:  make(map[uint64]*ServerStream),
 	}
+	if opts.Scheduler != nil {
+		c.sched = opts.Scheduler
+		c.queued = make(map[uint64]*queuedCall)
+		c.readyNotify = make(chan struct{}, 1)
+	}
+	c.ctx, c.cancelCalls = context.WithCancel(context.Background())
+	c.flight = newFlightLimit(opts)
+	c.chunks = newAssembler(c.flight)
+	c.mux = newMux(c.w, conn, false, opts)
+	return c
 }
 
 func (c *serverCodec) ReadRequestHeader(r *rpc.Request) error {
-	header := wire.RequestHeader{}
-	err := readRequestHeader(c.r, &header)
+	first := !c.started
+	if first {
+		c.started = true
+		info, err := serverHandshake(c.r, c.w, c.opts)
+		if err != nil {
+			return err
+		}
+		c.peer = newPeer(c.c) // the TLS handshake is done by the first read
+		if info.features&uint64(wire.Feature_FEATURE_MUX) != 0 {
+			c.mux.enableFlow(info)
+		}
+		if c.opts.KeepaliveInterval > 0 && info.features&uint64(wire.Feature_FEATURE_PING) != 0 {
+			c.keepalive = newKeepalive(c.opts, c.ping, c.dead, c.done)
+			go c.keepalive.run()
+		}
+		if c.sched != nil {
+			go c.readLoop()
+		}
+	}
+	if c.sched != nil {
+		return c.next(r)
+	}
+
+	header, body, err := c.recv()
 	if err != nil {
+		c.readFailed(err)
+		if first && err != io.EOF {
+			err = fmt.Errorf("%w: %v", ErrNotProtorpc, err)
+		}
 		return err
 	}
 
+	// control frames are handled here, net/rpc only sees requests
+	for header.FrameType != wire.FrameType_FRAME_CALL {
+		c.control(header, body)
+		if header, body, err = c.recv(); err != nil {
+			c.readFailed(err)
+			return err
+		}
+	}
+	c.startRequest(r, header, body, c.recvTime, false)
+	return nil
+}
+
+// control handles a control frame.
+func (c *serverCodec) control(header *wire.RequestHeader, body []byte) {
+	switch header.FrameType {
+	case wire.FrameType_FRAME_CANCEL:
+		c.cancel(header.Id)
+	case wire.FrameType_FRAME_STREAM_DATA:
+		if s := c.stream(header.Id); s != nil {
+			serialization := header.Serialization
+			s.queue.push(func(m proto.Message) error {
+				defer putBuffer(body)
+				return decodeBody(serialization, body, m)
+			})
+		}
+	case wire.FrameType_FRAME_STREAM_HALF_CLOSE:
+		if s := c.stream(header.Id); s != nil {
+			s.queue.close(io.EOF)
+		}
+	case wire.FrameType_FRAME_PING:
+		c.writeControl(header.Id, wire.FrameType_FRAME_PONG)
+	case wire.FrameType_FRAME_WINDOW_UPDATE:
+		c.mux.update(header.Id, header.Window, header.ConnWindow)
+	}
+}
+
+// startRequest saves the state of the request of header, received at
+// recvTime, and passes it to net/rpc.
+func (c *serverCodec) startRequest(r *rpc.Request, header *wire.RequestHeader, body []byte, recvTime time.Time, scheduled bool) {
+	// reply with the compressor of the request, if we know it
+	compressor, err := getCompressorByID(header.Compression, header.SnappyCompressedRequestLen)
+	if err != nil {
+		compressor = noneCompressor{}
+	}
+
+	req := &serverRequest{
+		id:            header.Id,
+		method:        header.Method,
+		compressor:    compressor,
+		checksumType:  replyChecksumType(uint32(header.ChecksumType)),
+		serialization: header.Serialization,
+		oneWay:        header.OneWay,
+		scheduled:     scheduled,
+	}
+	if header.Timeout != 0 {
+		req.deadline = recvTime.Add(time.Duration(header.Timeout) * time.Microsecond)
+	}
+	req.ctx = context.WithValue(c.ctx, peerKey{}, c.peer)
+	req.ctx = traceContext(req.ctx, header.Traceparent, header.Tracestate)
+	if c.opts.Tracer != nil {
+		req.ctx, req.span = c.opts.Tracer.StartServerSpan(req.ctx, header.Method)
+	}
+
 	c.mutex.Lock()
 	c.seq++
-	c.pending[c.seq] = header.Id
+	c.pending[c.seq] = req
+	c.seqs[header.Id] = c.seq
 	r.ServiceMethod = header.Method
 	r.Seq = c.seq
 	c.mutex.Unlock()
 
-	c.reqHeader = header
-	return nil
+	c.req, c.reqHeader, c.reqBody = req, header, body
+}
+
+// readLoop reads the frames of a codec with a scheduler: the control
+// frames are handled at once, the requests wait for the scheduler.
+func (c *serverCodec) readLoop() {
+	first := true
+	for {
+		header, body, err := c.recv()
+		if err != nil {
+			c.readFailed(err)
+			if first && err != io.EOF {
+				err = fmt.Errorf("%w: %v", ErrNotProtorpc, err)
+			}
+			c.mutex.Lock()
+			c.readErr = err
+			c.mutex.Unlock()
+			c.signalReady()
+			return
+		}
+		first = false
+		if header.FrameType != wire.FrameType_FRAME_CALL {
+			c.control(header, body)
+			continue
+		}
+
+		// the body is held until the call starts
+		if err := c.flight.add(len(body)); err != nil {
+			c.mutex.Lock()
+			c.readErr = err
+			c.mutex.Unlock()
+			c.signalReady()
+			c.c.Close()
+			return
+		}
+		q := &queuedCall{header: header, body: body, recvTime: c.recvTime}
+		q.item = newSchedItem(func() { c.push(q) })
+
+		// the messages sent on a stream before it starts are queued
+		s := &ServerStream{codec: c, queue: c.newStreamQueue(header.Id)}
+
+		c.mutex.Lock()
+		c.queued[header.Id] = q
+		c.streams[header.Id] = s
+		c.mutex.Unlock()
+		c.sched.submit(q.item, header.Priority, q.recvTime)
+	}
+}
+
+// push queues a call started by the scheduler for ReadRequestHeader.
+func (c *serverCodec) push(q *queuedCall) {
+	c.mutex.Lock()
+	select {
+	case <-c.done:
+		q.canceled = true // submitted while the codec was closed
+	default:
+	}
+	if q.canceled {
+		c.mutex.Unlock()
+		c.sched.done()
+		return
+	}
+	c.ready = append(c.ready, q)
+	c.mutex.Unlock()
+	c.signalReady()
+}
+
+func (c *serverCodec) signalReady() {
+	select {
+	case c.readyNotify <- struct{}{}:
+	default:
+	}
+}
+
+// next waits for the next call started by the scheduler. Once the
+// connection is broken, it returns the error after the calls read
+// before.
+func (c *serverCodec) next(r *rpc.Request) error {
+	for {
+		c.mutex.Lock()
+		if len(c.ready) != 0 {
+			q := c.ready[0]
+			c.ready[0] = nil
+			c.ready = c.ready[1:]
+			delete(c.queued, q.header.Id)
+			c.mutex.Unlock()
+
+			c.flight.sub(len(q.body))
+			c.startRequest(r, q.header, q.body, q.recvTime, true)
+			return nil
+		}
+		err, waiting := c.readErr, len(c.queued)
+		c.mutex.Unlock()
+		if err != nil && waiting == 0 {
+			return err
+		}
+
+		select {
+		case <-c.readyNotify:
+		case <-c.done:
+			return io.EOF
+		}
+	}
+}
+
+// unqueue removes the waiting call id, it reports false if the call is
+// not waiting.
+func (c *serverCodec) unqueue(id uint64) bool {
+	c.mutex.Lock()
+	q, ok := c.queued[id]
+	if !ok {
+		c.mutex.Unlock()
+		return false
+	}
+	delete(c.queued, id)
+	s := c.streams[id]
+	delete(c.streams, id)
+	q.canceled = true
+	started := false
+	for i, x := range c.ready {
+		if x == q {
+			c.ready = append(c.ready[:i], c.ready[i+1:]...)
+			started = true
+			break
+		}
+	}
+	c.mutex.Unlock()
+
+	c.flight.sub(len(q.body))
+	putBuffer(q.body)
+	if s != nil {
+		s.queue.close(io.EOF)
+	}
+	// a call being started is dropped by push
+	if started {
+		c.sched.done()
+	} else {
+		c.sched.remove(q.item)
+	}
+	c.signalReady()
+	return true
+}
+
+// recv reads the next frame, the bodies sent in chunks are assembled.
+func (c *serverCodec) recv() (*wire.RequestHeader, []byte, error) {
+	for {
+		header := new(wire.RequestHeader)
+		if err := readRequestHeader(c.r, c.opts, header); err != nil {
+			return nil, nil, err
+		}
+		now := time.Now()
+		if c.keepalive != nil {
+			c.keepalive.received()
+		}
+
+		if header.FrameType == wire.FrameType_FRAME_CHUNK {
+			b, n, err := c.chunks.recv(c.r, c.opts, header.Id, header.More)
+			if err != nil {
+				return nil, nil, err
+			}
+			if b == nil {
+				c.mux.credit(header.Id, n, false)
+				continue
+			}
+			first := b.first.(*wire.RequestHeader)
+			if _, err := c.credit(first, n); err != nil {
+				return nil, nil, err
+			}
+			if header.More {
+				continue
+			}
+			c.recvTime = b.time
+			return c.open(first, b.body)
+		}
+
+		body, err := recvRequestBody(c.r, c.opts, header)
+		if err != nil {
+			return nil, nil, err
+		}
+		held, err := c.credit(header, len(body))
+		if err != nil {
+			return nil, nil, err
+		}
+		if header.More {
+			limit := bodyLimit(c.opts, maxUint32(header.RawRequestLen, header.SnappyCompressedRequestLen))
+			if err := c.chunks.start(header.Id, header, body, limit, now, held); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		c.recvTime = now
+		return c.open(header, body)
+	}
+}
+
+// open checks and decompresses the body of header. The frames are
+// corrupted if it fails: the call fails with CodeDataLoss, and the
+// error breaks the connection.
+func (c *serverCodec) open(header *wire.RequestHeader, body []byte) (*wire.RequestHeader, []byte, error) {
+	body, err := openRequestBody(header, body)
+	if err != nil {
+		if header.FrameType == wire.FrameType_FRAME_CALL {
+			c.sendError(header, NewStatus(CodeDataLoss, err.Error()))
+		} else if s := c.stream(header.Id); s != nil {
+			s.queue.close(err)
+		}
+		return nil, nil, err
+	}
+	return header, body, nil
+}
+
+// sendError replies to the request of header with status s, without
+// calling the service.
+func (c *serverCodec) sendError(header *wire.RequestHeader, s *Status) {
+	if header.OneWay {
+		c.oneWayError(header.Method, s)
+		return
+	}
+	resp := &wire.ResponseHeader{
+		Id:           header.Id,
+		Error:        s.Error(),
+		Status:       s.toWire(),
+		ChecksumType: wire.ChecksumType(replyChecksumType(uint32(header.ChecksumType))),
+	}
+	body, err := encodeResponse(c.opts, nil, noneCompressor{}, "", resp, nil)
+	if err != nil {
+		return
+	}
+	c.mux.send(header.Id, resp, body, true, false)
+}
+
+// oneWayError reports the error of a one-way call to the handler of the
+// options, if any.
+func (c *serverCodec) oneWayError(method string, err error) {
+	if c.opts.OneWayErrorHandler != nil {
+		c.opts.OneWayErrorHandler(method, err)
+	}
+}
+
+// credit grants n received body bytes of header back to the client. The
+// bytes of a stream are held until its messages are read, and counted
+// in the flight limit: credit reports if they are held.
+func (c *serverCodec) credit(header *wire.RequestHeader, n int) (bool, error) {
+	if header.FrameType != wire.FrameType_FRAME_STREAM_DATA {
+		c.mux.credit(header.Id, n, true)
+	} else if s := c.stream(header.Id); s != nil {
+		if err := c.flight.add(n); err != nil {
+			return false, err
+		}
+		s.queue.hold(n)
+		return true, nil
+	} else {
+		c.mux.credit(header.Id, n, false)
+	}
+	return false, nil
+}
+
+// writeControl sends a control frame with an empty body, before the
+// queued messages.
+func (c *serverCodec) writeControl(id uint64, frameType wire.FrameType) error {
+	header := &wire.ResponseHeader{Id: id, FrameType: frameType}
+	if _, err := encodeResponse(c.opts, nil, noneCompressor{}, "", header, nil); err != nil {
+		return err
+	}
+	return c.mux.sendControl(header)
+}
+
+func (c *serverCodec) ping() error {
+	return c.writeControl(pingID, wire.FrameType_FRAME_PING)
+}
+
+// dead closes the connection to a client which stopped answering, and
+// cancels its calls.
+func (c *serverCodec) dead(err error) {
+	c.cancelCalls()
+	c.c.Close()
+}
+
+// readFailed cancels the calls once reading the connection failed: the
+// client hung up or broke the connection, and can not cancel them. The
+// messages of a client which hung up are not sent, and no window is
+// granted anymore: the messages waiting for it fail.
+func (c *serverCodec) readFailed(err error) {
+	c.cancelCalls()
+	if hungUp(err) {
+		c.mux.close(errMuxClosed)
+	} else {
+		c.mux.stall(errMuxClosed)
+	}
+}
+
+// hungUp reports whether a read error is the end of the connection,
+// rather than a frame rejected by the codec.
+func hungUp(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.ErrClosedPipe) || errors.As(err, &netErr)
+}
+
+// stream returns the open stream id, or nil.
+func (c *serverCodec) stream(id uint64) *ServerStream {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.streams[id]
+}
+
+// writeData sends m on the stream of req, and waits until it is written.
+func (c *serverCodec) writeData(req *serverRequest, m proto.Message) error {
+	header := &wire.ResponseHeader{
+		Id:            req.id,
+		ChecksumType:  wire.ChecksumType(req.checksumType),
+		Serialization: req.serialization,
+		FrameType:     wire.FrameType_FRAME_STREAM_DATA,
+	}
+	body, err := encodeResponse(c.opts, c.stats, req.compressor, req.method, header, m)
+	if err != nil {
+		return err
+	}
+
+	err = c.mux.send(req.id, header, body, false, true)
+	if err == errMuxDropped {
+		err = contextError(req.call.ctx.Err())
+	}
+	return err
+}
+
+// cancel cancels the context of the request id, and drops its response.
+func (c *serverCodec) cancel(id uint64) {
+	// the rest of the request is not sent
+	c.chunks.remove(id)
+	if c.sched != nil && c.unqueue(id) {
+		return
+	}
+
+	c.mutex.Lock()
+	seq, ok := c.seqs[id]
+	if !ok {
+		c.mutex.Unlock()
+		return // the response is sent
+	}
+	req := c.pending[seq]
+	delete(c.pending, seq)
+	delete(c.seqs, id)
+	c.canceled[seq] = req
+	req.canceled = true
+	call := req.call
+	c.mutex.Unlock()
+
+	if call != nil {
+		call.cancel()
+	}
+	// unblock the messages of a stream waiting for the window
+	c.mux.drop(id)
 }
 
 func (c *serverCodec) ReadRequestBody(x interface{}) error {
+	// the request may be canceled by readLoop at any time, it is not
+	// looked up in pending
+	req, header, body := c.req, c.reqHeader, c.reqBody
+	c.req, c.reqHeader, c.reqBody = nil, nil, nil
+	defer putBuffer(body)
+
 	if x == nil {
+		// net/rpc discards the body of an invalid request
+		c.dropEarly(header.Id)
 		return nil
 	}
-	request, ok := x.(proto.Message)
-	if !ok {
+	stream, isStream := x.(*ServerStream)
+	request, ok := protoMessage(x)
+	if !ok && !isStream {
+		c.dropEarly(header.Id)
 		return fmt.Errorf(
 			"protorpc.ServerCodec.ReadRequestBody: %T does not implement proto.Message",
 			x,
 		)
 	}
 
-	err := readRequestBody(c.r, &c.reqHeader, request)
-	if err != nil {
-		return nil
+	// the body of a stream request is empty, the messages follow
+	if err := decodeBody(header.Serialization, body, request); err != nil {
+		c.dropEarly(header.Id)
+		return err
+	}
+
+	c.mutex.Lock()
+	early := c.streams[req.id] // the messages received while the request waited
+	if isStream {
+		queue := c.newStreamQueue(req.id)
+		if early != nil {
+			queue, early = early.queue, nil
+		}
+		*stream = ServerStream{codec: c, req: req, queue: queue}
+		req.stream = stream
+		c.streams[req.id] = stream
+	} else if early != nil {
+		delete(c.streams, req.id)
+	}
+	c.mutex.Unlock()
+	if early != nil {
+		early.queue.close(io.EOF)
 	}
 
-	c.reqHeader = wire.RequestHeader{}
+	req.args = x
+	md := metadataFromWire(header.Metadata)
+	if c.opts.Authenticator != nil {
+		if err := c.authenticate(req, md); err != nil {
+			return err
+		}
+	}
+	call := newServerCall(req.ctx, x, md, req.deadline)
+	c.mutex.Lock()
+	req.call = call
+	canceled := req.canceled
+	c.mutex.Unlock()
+	if canceled {
+		// canceled while the body was read
+		call.cancel()
+	}
+
+	// do not serve stale requests, net/rpc replies with the error
+	if !req.deadline.IsZero() && !time.Now().Before(req.deadline) {
+		return errDeadlineExceeded
+	}
+	return nil
+}
+
+// authenticate runs the Authenticator of the options on req, whose
+// metadata is md, and adds the principal to the context of the handler.
+func (c *serverCodec) authenticate(req *serverRequest, md Metadata) error {
+	ctx := context.WithValue(req.ctx, incomingMetadataKey{}, md)
+	principal, err := c.opts.Authenticator.Authenticate(ctx, req.method)
+	if err != nil {
+		return unauthenticated(err)
+	}
+	if principal != nil {
+		req.ctx = context.WithValue(req.ctx, principalKey{}, principal)
+	}
 	return nil
 }
 
+// newStreamQueue returns the message queue of the stream id.
+func (c *serverCodec) newStreamQueue(id uint64) *streamQueue {
+	q := newStreamQueue()
+	q.credit = func(n int, stream bool) {
+		c.flight.sub(n)
+		c.mux.credit(id, n, stream)
+	}
+	return q
+}
+
+// dropEarly drops the messages received for the request id while it
+// waited for the scheduler, the request is not a stream.
+func (c *serverCodec) dropEarly(id uint64) {
+	c.mutex.Lock()
+	s := c.streams[id]
+	if s != nil && s.req == nil {
+		delete(c.streams, id)
+	}
+	c.mutex.Unlock()
+	if s != nil && s.req == nil {
+		s.queue.close(io.EOF)
+	}
+}
+
 // A value sent as a placeholder for the server's response value when the server
 // receives an invalid request. It is never decoded by the client since the Response
 // contains an error when it is used.
 var invalidRequest = struct{}{}
 
-func (c *serverCodec) WriteResponse(r *rpc.Response, x interface{}) error {
+var errDeadlineExceeded = Errorf(CodeDeadlineExceeded, "protorpc: request deadline exceeded")
+
+func (c *serverCodec) WriteResponse(r *rpc.Response, x interface{}) (err error) {
+	c.mutex.Lock()
+	req, ok := c.pending[r.Seq]
+	canceled := false
+	if ok {
+		delete(c.pending, r.Seq)
+		delete(c.seqs, req.id)
+	} else if req, ok = c.canceled[r.Seq]; ok {
+		delete(c.canceled, r.Seq)
+		canceled = true
+	}
+	if ok && req.stream != nil {
+		delete(c.streams, req.id)
+	}
+	c.mutex.Unlock()
+	if !ok {
+		return errors.New("protorpc: invalid sequence number in response")
+	}
+	if req.scheduled {
+		// the handler returned, the next call may start
+		c.sched.done()
+	}
+
+	if req.call != nil {
+		defer req.call.done(req.args)
+	}
+	callErr := r.Error // the error the span ends with, if not err
+	if req.span != nil {
+		defer func() {
+			spanErr := err
+			if callErr != "" {
+				spanErr = parseStatus(callErr)
+			}
+			req.span.End(spanErr)
+		}()
+	}
+	if canceled {
+		callErr = contextError(context.Canceled).Error()
+		// drop the window of the messages sent after the cancel
+		c.mux.finish(req.id)
+		return nil
+	}
+	if req.oneWay {
+		// the client does not wait for a response
+		c.mux.finish(req.id)
+		if r.Error != "" {
+			c.oneWayError(r.ServiceMethod, parseStatus(r.Error))
+		}
+		return nil
+	}
+
 	var response proto.Message
-	if x != nil {
+	if x != nil && req.stream == nil {
 		var ok bool
-		if response, ok = x.(proto.Message); !ok {
+		if response, ok = protoMessage(x); !ok {
 			if _, ok = x.(struct{}); !ok {
-				c.mutex.Lock()
-				delete(c.pending, r.Seq)
-				c.mutex.Unlock()
 				return fmt.Errorf(
 					"protorpc.ServerCodec.WriteResponse: %T does not implement proto.Message",
 					x,
@@ -105,24 +779,75 @@ func (c *serverCodec) WriteResponse(r *rpc.Response, x interface{}) error {
 		}
 	}
 
-	c.mutex.Lock()
-	id, ok := c.pending[r.Seq]
-	if !ok {
-		c.mutex.Unlock()
-		return errors.New("protorpc: invalid sequence number in response")
+	header := &wire.ResponseHeader{
+		Id:            req.id,
+		Error:         r.Error,
+		ChecksumType:  wire.ChecksumType(req.checksumType),
+		Serialization: req.serialization,
 	}
-	delete(c.pending, r.Seq)
-	c.mutex.Unlock()
+	if req.stream != nil {
+		header.FrameType = wire.FrameType_FRAME_STREAM_END
+	}
+	if req.call != nil {
+		trailer := req.call.getTrailer()
+		if n := trailer.size(); n > metadataLimit(c.opts) {
+			if header.Error == "" {
+				header.Error = Errorf(CodeInternal, "protorpc: trailer larger than max_metadata_len: %d", n).Error()
+			}
+		} else {
+			header.Trailer = trailer.toWire()
+		}
+	}
+
+	// the client stopped waiting, do not send a stale result
+	if header.Error == "" && !req.deadline.IsZero() && !time.Now().Before(req.deadline) {
+		header.Error = errDeadlineExceeded.Error()
+	}
+
+	// send the status along with the error text, which old clients read
+	if header.Error != "" {
+		header.Status = parseStatus(header.Error).toWire()
+	}
+	callErr = header.Error
 
-	err := writeResponse(c.w, id, r.Error, response)
+	body, err := encodeResponse(c.opts, c.stats, req.compressor, r.ServiceMethod, header, response)
+	var limitErr *LimitError
+	if errors.As(err, &limitErr) && limitErr.Err == ErrHeaderTooLarge {
+		// fail the call without its error text and trailer, the
+		// connection is kept
+		s := NewStatus(CodeResourceExhausted, fmt.Sprintf("protorpc: response header larger than max_header_len: %d", limitErr.Size))
+		header.Error, header.Status, header.Trailer = s.Error(), s.toWire(), nil
+		callErr = header.Error
+		body, err = encodeResponse(c.opts, c.stats, req.compressor, r.ServiceMethod, header, nil)
+	}
 	if err != nil {
 		return err
 	}
 
-	return nil
+	// rpc.Server holds its lock, do not wait for the response to be written
+	return c.mux.send(req.id, header, body, true, false)
 }
 
+// Close closes the underlying connection, once the responses are sent.
 func (s *serverCodec) Close() error {
+	s.doneOnce.Do(func() { close(s.done) })
+	s.cancelCalls()
+	if s.sched != nil {
+		// the waiting calls are not served
+		s.mutex.Lock()
+		ids := make([]uint64, 0, len(s.queued))
+		for id := range s.queued {
+			ids = append(ids, id)
+		}
+		s.mutex.Unlock()
+		for _, id := range ids {
+			s.unqueue(id)
+		}
+	}
+	// the client may not read the messages waiting for window
+	s.mux.stall(errMuxClosed)
+	s.mux.wait()
+	s.mux.close(errMuxClosed)
 	return s.c.Close()
 }
 
@@ -132,3 +857,8 @@ func (s *serverCodec) Close() error {
 func ServeConn(conn io.ReadWriteCloser) {
 	rpc.ServeCodec(NewServerCodec(conn))
 }
+
+// ServeConnWithOptions is like ServeConn but uses the given options.
+func ServeConnWithOptions(conn io.ReadWriteCloser, opts *Options) {
+	rpc.ServeCodec(NewServerCodecWithOptions(conn, opts))
+}
@@ -0,0 +1,234 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/rpc"
+	"strings"
+
+	wire "github.com/chai2010/protorpc/wire.pb"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+// A Code is an RPC status code. The values are the same as gRPC codes.
+type Code uint32
+
+const (
+	CodeOK                 Code = 0
+	CodeCanceled           Code = 1
+	CodeUnknown            Code = 2
+	CodeInvalidArgument    Code = 3
+	CodeDeadlineExceeded   Code = 4
+	CodeNotFound           Code = 5
+	CodeAlreadyExists      Code = 6
+	CodePermissionDenied   Code = 7
+	CodeResourceExhausted  Code = 8
+	CodeFailedPrecondition Code = 9
+	CodeAborted            Code = 10
+	CodeOutOfRange         Code = 11
+	CodeUnimplemented      Code = 12
+	CodeInternal           Code = 13
+	CodeUnavailable        Code = 14
+	CodeDataLoss           Code = 15
+	CodeUnauthenticated    Code = 16
+)
+
+var codeNames = []string{
+	CodeOK:                 "OK",
+	CodeCanceled:           "Canceled",
+	CodeUnknown:            "Unknown",
+	CodeInvalidArgument:    "InvalidArgument",
+	CodeDeadlineExceeded:   "DeadlineExceeded",
+	CodeNotFound:           "NotFound",
+	CodeAlreadyExists:      "AlreadyExists",
+	CodePermissionDenied:   "PermissionDenied",
+	CodeResourceExhausted:  "ResourceExhausted",
+	CodeFailedPrecondition: "FailedPrecondition",
+	CodeAborted:            "Aborted",
+	CodeOutOfRange:         "OutOfRange",
+	CodeUnimplemented:      "Unimplemented",
+	CodeInternal:           "Internal",
+	CodeUnavailable:        "Unavailable",
+	CodeDataLoss:           "DataLoss",
+	CodeUnauthenticated:    "Unauthenticated",
+}
+
+func (c Code) String() string {
+	if int(c) < len(codeNames) {
+		return codeNames[c]
+	}
+	return fmt.Sprintf("Code(%d)", uint32(c))
+}
+
+func parseCode(s string) (Code, bool) {
+	for i, name := range codeNames {
+		if name == s {
+			return Code(i), true
+		}
+	}
+	var c uint32
+	if _, err := fmt.Sscanf(s, "Code(%d)", &c); err == nil {
+		return Code(c), true
+	}
+	return CodeUnknown, false
+}
+
+// Status is the result of an RPC: a code, a message and optional details.
+// A *Status with a code other than CodeOK is an error.
+//
+// Handlers return a status with Errorf or NewStatus(...).Err(); clients
+// get it back with StatusFromError.
+type Status struct {
+	Code    Code
+	Message string
+	Details []*any.Any
+
+	err error // the error the status was made from, if any
+}
+
+// NewStatus returns a status with the given code and message.
+func NewStatus(code Code, msg string) *Status {
+	return &Status{Code: code, Message: msg}
+}
+
+// Errorf returns an error with the given code and a formatted message.
+func Errorf(code Code, format string, a ...interface{}) error {
+	return NewStatus(code, fmt.Sprintf(format, a...)).Err()
+}
+
+// WithDetails returns a copy of s with the given messages appended as details.
+func (s *Status) WithDetails(details ...proto.Message) (*Status, error) {
+	x := &Status{
+		Code:    s.Code,
+		Message: s.Message,
+		Details: append([]*any.Any(nil), s.Details...),
+	}
+	for _, m := range details {
+		a, err := ptypes.MarshalAny(m)
+		if err != nil {
+			return nil, err
+		}
+		x.Details = append(x.Details, a)
+	}
+	return x, nil
+}
+
+// Err returns s as an error, or nil if s is nil or its code is CodeOK.
+func (s *Status) Err() error {
+	if s == nil || s.Code == CodeOK {
+		return nil
+	}
+	return s
+}
+
+// Error returns the status text, which is also how the status is sent
+// through net/rpc: "rpc error: code = NotFound desc = ...". A status
+// with CodeUnknown and no details is only its message, like the error
+// text of old peers.
+func (s *Status) Error() string {
+	if s.Code == CodeUnknown && len(s.Details) == 0 {
+		return s.Message
+	}
+	text := fmt.Sprintf("rpc error: code = %v desc = %s", s.Code, s.Message)
+	if len(s.Details) != 0 {
+		data, _ := proto.Marshal(&wire.Status{Details: s.Details})
+		text += statusDetailsSep + base64.RawURLEncoding.EncodeToString(data)
+	}
+	return text
+}
+
+// Is reports whether target is a *Status with the same code, and the
+// same message if the target message is not empty.
+func (s *Status) Is(target error) bool {
+	t, ok := target.(*Status)
+	if !ok {
+		return false
+	}
+	return s.Code == t.Code && (t.Message == "" || s.Message == t.Message)
+}
+
+// Unwrap returns the error s was made from by StatusFromError, if any.
+func (s *Status) Unwrap() error {
+	return s.err
+}
+
+// StatusFromError returns the status of err, which may be (or wrap)
+// a *Status or an rpc.ServerError returned by a client. Errors without
+// status, including the errors of old servers, have CodeUnknown.
+// StatusFromError returns nil if err is nil.
+func StatusFromError(err error) *Status {
+	if err == nil {
+		return nil
+	}
+	var s *Status
+	if errors.As(err, &s) {
+		return s
+	}
+	var serr rpc.ServerError
+	if errors.As(err, &serr) {
+		s = parseStatus(string(serr))
+		s.err = err
+		return s
+	}
+	return &Status{Code: CodeUnknown, Message: err.Error(), err: err}
+}
+
+const (
+	statusPrefix     = "rpc error: code = "
+	statusDescSep    = " desc = "
+	statusDetailsSep = " details = "
+)
+
+// parseStatus parses the text made by Status.Error.
+func parseStatus(text string) *Status {
+	s := &Status{Code: CodeUnknown, Message: text}
+	if !strings.HasPrefix(text, statusPrefix) {
+		return s
+	}
+	rest := text[len(statusPrefix):]
+	i := strings.Index(rest, statusDescSep)
+	if i < 0 {
+		return s
+	}
+	code, ok := parseCode(rest[:i])
+	if !ok {
+		return s
+	}
+	s.Code, s.Message = code, rest[i+len(statusDescSep):]
+
+	if i := strings.LastIndex(s.Message, statusDetailsSep); i >= 0 {
+		data, err := base64.RawURLEncoding.DecodeString(s.Message[i+len(statusDetailsSep):])
+		if err != nil {
+			return s
+		}
+		var x wire.Status
+		if err := proto.Unmarshal(data, &x); err != nil {
+			return s
+		}
+		s.Message, s.Details = s.Message[:i], x.Details
+	}
+	return s
+}
+
+func (s *Status) toWire() *wire.Status {
+	return &wire.Status{
+		Code:    uint32(s.Code),
+		Message: s.Message,
+		Details: s.Details,
+	}
+}
+
+func statusFromWire(x *wire.Status) *Status {
+	return &Status{
+		Code:    Code(x.Code),
+		Message: x.Message,
+		Details: x.Details,
+	}
+}
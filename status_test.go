@@ -0,0 +1,111 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc_test
+
+import (
+	"errors"
+	"net/rpc"
+	"testing"
+
+	"github.com/chai2010/protorpc"
+	msg "github.com/chai2010/protorpc/examples/message.pb"
+	"github.com/golang/protobuf/ptypes"
+)
+
+type StatusService int
+
+func (t *StatusService) NotFound(args *msg.EchoRequest, reply *msg.EchoResponse) error {
+	return protorpc.Errorf(protorpc.CodeNotFound, "%s not found", args.Msg)
+}
+
+func (t *StatusService) Details(args *msg.EchoRequest, reply *msg.EchoResponse) error {
+	s, err := protorpc.NewStatus(protorpc.CodeInvalidArgument, "bad msg").WithDetails(
+		&msg.EchoResponse{Msg: args.Msg},
+	)
+	if err != nil {
+		return err
+	}
+	return s.Err()
+}
+
+func (t *StatusService) Plain(args *msg.EchoRequest, reply *msg.EchoResponse) error {
+	return errors.New("plain error")
+}
+
+func TestStatus(t *testing.T) {
+	client := newTestClient(t, nil, nil, func(srv *rpc.Server) error {
+		return srv.RegisterName("StatusService", new(StatusService))
+	})
+
+	var reply msg.EchoResponse
+	err := client.Call("StatusService.NotFound", &msg.EchoRequest{Msg: "key"}, &reply)
+	s := protorpc.StatusFromError(err)
+	if s.Code != protorpc.CodeNotFound || s.Message != "key not found" {
+		t.Fatalf("NotFound: got %v %q", s.Code, s.Message)
+	}
+	if !errors.Is(s, protorpc.NewStatus(protorpc.CodeNotFound, "")) {
+		t.Fatalf("NotFound: errors.Is failed")
+	}
+	if errors.Is(s, protorpc.NewStatus(protorpc.CodeNotFound, "other")) {
+		t.Fatalf("NotFound: errors.Is matched another message")
+	}
+	var serr rpc.ServerError
+	if !errors.As(s, &serr) {
+		t.Fatalf("NotFound: expect the rpc.ServerError, got %T", errors.Unwrap(s))
+	}
+
+	err = client.Call("StatusService.Details", &msg.EchoRequest{Msg: "hello"}, &reply)
+	s = protorpc.StatusFromError(err)
+	if s.Code != protorpc.CodeInvalidArgument || s.Message != "bad msg" {
+		t.Fatalf("Details: got %v %q", s.Code, s.Message)
+	}
+	if len(s.Details) != 1 {
+		t.Fatalf("Details: expect 1 detail, got %d", len(s.Details))
+	}
+	var detail msg.EchoResponse
+	if err := ptypes.UnmarshalAny(s.Details[0], &detail); err != nil {
+		t.Fatal(err)
+	}
+	if detail.Msg != "hello" {
+		t.Fatalf("Details: expect %q, got %q", "hello", detail.Msg)
+	}
+
+	err = client.Call("StatusService.Plain", &msg.EchoRequest{}, &reply)
+	if err.Error() != "plain error" {
+		t.Fatalf("Plain: expect %q, got %q", "plain error", err.Error())
+	}
+	s = protorpc.StatusFromError(err)
+	if s.Code != protorpc.CodeUnknown || s.Message != "plain error" {
+		t.Fatalf("Plain: got %v %q", s.Code, s.Message)
+	}
+}
+
+func TestStatusFromError(t *testing.T) {
+	if s := protorpc.StatusFromError(nil); s != nil {
+		t.Fatalf("nil: expect nil, got %v", s)
+	}
+
+	// text of old servers, and of new servers read by old clients
+	tests := []struct {
+		text string
+		code protorpc.Code
+		msg  string
+	}{
+		{"divide by zero", protorpc.CodeUnknown, "divide by zero"},
+		{"rpc error: code = Unavailable desc = try later", protorpc.CodeUnavailable, "try later"},
+		{"rpc error: code = Code(42) desc = x", protorpc.Code(42), "x"},
+		{"rpc error: code = Bad desc = x", protorpc.CodeUnknown, "rpc error: code = Bad desc = x"},
+	}
+	for _, tt := range tests {
+		s := protorpc.StatusFromError(rpc.ServerError(tt.text))
+		if s.Code != tt.code || s.Message != tt.msg {
+			t.Fatalf("%q: got %v %q", tt.text, s.Code, s.Message)
+		}
+	}
+
+	if err := protorpc.NewStatus(protorpc.CodeOK, "").Err(); err != nil {
+		t.Fatalf("CodeOK: expect nil error, got %v", err)
+	}
+}
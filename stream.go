@@ -0,0 +1,294 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/rpc"
+	"sync"
+
+	wire "github.com/chai2010/protorpc/wire.pb"
+	"github.com/golang/protobuf/proto"
+)
+
+// A stream is a call whose request and response are sequences of messages.
+//
+// On the server a streaming method is a method of a net/rpc service with
+// the signature
+//
+//	func (t *T) MethodName(stream *protorpc.ServerStream, reply *protorpc.StreamReply) error
+//
+// The method receives and sends messages with stream, the stream ends
+// when it returns: the error of the method is the status of the stream.
+// protoc-gen-protorpc generates typed wrappers for the stream methods of
+// a service.
+//
+// On the client NewStream opens a stream.
+
+// errStreamDone is returned by streamQueue.recv when done is closed.
+var errStreamDone = errors.New("protorpc: stream done")
+
+// streamQueue buffers the messages received by a stream until they are read.
+type streamQueue struct {
+	mutex  sync.Mutex
+	msgs   []func(m proto.Message) error // decode the messages
+	err    error                         // returned when msgs is empty, if closed
+	closed bool
+
+	// With flow control, the received bytes are granted back to the peer
+	// by credit, unless there are unread messages: a slow reader holds
+	// the window of the stream.
+	credit func(n int, stream bool)
+	held   int
+
+	notify chan struct{} // signaled when a message is pushed or the queue closed
+}
+
+func newStreamQueue() *streamQueue {
+	return &streamQueue{notify: make(chan struct{}, 1)}
+}
+
+func (q *streamQueue) signal() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// push adds a message, decode decodes it.
+func (q *streamQueue) push(decode func(m proto.Message) error) {
+	q.mutex.Lock()
+	if !q.closed {
+		q.msgs = append(q.msgs, decode)
+	}
+	q.mutex.Unlock()
+	q.signal()
+}
+
+// close ends the queue, recv returns err once the messages are read.
+func (q *streamQueue) close(err error) {
+	q.mutex.Lock()
+	if !q.closed {
+		q.closed, q.err = true, err
+	}
+	held := q.held
+	q.held = 0
+	q.mutex.Unlock()
+	q.signal()
+
+	// the stream is done, only the connection window is granted
+	q.grant(held, false)
+}
+
+// hold grants n received bytes back to the peer, or holds them until
+// the messages are read.
+func (q *streamQueue) hold(n int) {
+	q.mutex.Lock()
+	if len(q.msgs) != 0 && !q.closed {
+		q.held += n
+		n = 0
+	}
+	closed := q.closed
+	q.mutex.Unlock()
+	q.grant(n, !closed)
+}
+
+func (q *streamQueue) grant(n int, stream bool) {
+	if n != 0 && q.credit != nil {
+		q.credit(n, stream)
+	}
+}
+
+// recv decodes the next message into m. It returns errStreamDone if done
+// is closed while waiting.
+func (q *streamQueue) recv(m proto.Message, done <-chan struct{}) error {
+	for {
+		q.mutex.Lock()
+		if len(q.msgs) != 0 {
+			decode := q.msgs[0]
+			q.msgs[0] = nil
+			q.msgs = q.msgs[1:]
+			held := 0
+			if len(q.msgs) == 0 {
+				held, q.held = q.held, 0
+			}
+			q.mutex.Unlock()
+			q.grant(held, true)
+			return decode(m)
+		}
+		closed, err := q.closed, q.err
+		q.mutex.Unlock()
+		if closed {
+			return err
+		}
+
+		select {
+		case <-q.notify:
+		case <-done:
+			return errStreamDone
+		}
+	}
+}
+
+// StreamReply is the reply type of streaming methods. The result of a
+// stream is the error of the method and the trailer.
+type StreamReply struct{}
+
+// ServerStream is the server side of a stream.
+type ServerStream struct {
+	codec *serverCodec
+	req   *serverRequest
+	queue *streamQueue
+}
+
+// Context returns the context of the stream. It has the metadata and
+// the deadline sent by the client, and is canceled when the client
+// cancels the stream or the method returns.
+func (s *ServerStream) Context() context.Context {
+	if s.req == nil {
+		return context.Background()
+	}
+	return s.req.call.ctx
+}
+
+// SendMsg sends m to the client.
+func (s *ServerStream) SendMsg(m proto.Message) error {
+	if s.req == nil {
+		return errors.New("protorpc: the stream is not open")
+	}
+	if err := s.req.call.ctx.Err(); err != nil {
+		return contextError(err)
+	}
+	return s.codec.writeData(s.req, m)
+}
+
+// RecvMsg receives the next message of the client into m. It returns
+// io.EOF after the last message, when the client called CloseSend.
+func (s *ServerStream) RecvMsg(m proto.Message) error {
+	if s.req == nil {
+		return errors.New("protorpc: the stream is not open")
+	}
+	ctx := s.req.call.ctx
+	err := s.queue.recv(m, ctx.Done())
+	if err == errStreamDone {
+		err = contextError(ctx.Err())
+	}
+	return err
+}
+
+// ClientStream is the client side of a stream opened by NewStream.
+type ClientStream struct {
+	ctx   context.Context
+	codec *clientCodec
+	call  *clientCall
+	queue *streamQueue
+
+	trailer Metadata      // the trailer, unless the Trailer call option is used
+	done    chan struct{} // closed when the stream ended
+
+	mutex      sync.Mutex // protects halfClosed
+	halfClosed bool
+}
+
+// NewStream opens a stream to the streaming method serviceMethod of the
+// server. The metadata and deadline of ctx are sent like by CallContext,
+// and the stream is canceled when ctx is done.
+//
+// Streams need a connection made with Options.Handshake to a server
+// accepting streams.
+func NewStream(ctx context.Context, client *rpc.Client, serviceMethod string, opts ...CallOption) (*ClientStream, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(err)
+	}
+
+	s := &ClientStream{
+		ctx:   ctx,
+		queue: newStreamQueue(),
+		done:  make(chan struct{}),
+	}
+	s.call = newClientCall(ctx, nil, opts...)
+	s.call.stream = s
+	if s.call.info.trailer == nil {
+		s.call.info.trailer = &s.trailer
+	}
+
+	// rpc.Client.Go sends the request before it returns, the call
+	// completes when the stream ends
+	call := client.Go(serviceMethod, s.call, nil, make(chan *rpc.Call, 1))
+	if s.call.codec == nil {
+		call = <-call.Done
+		return nil, call.Error
+	}
+	s.codec = s.call.codec
+	if ctx.Done() != nil {
+		go s.call.watch(ctx)
+	}
+	go func() {
+		call := <-call.Done
+		close(s.done)
+		if call.Error != nil {
+			s.queue.close(call.Error)
+		} else {
+			s.queue.close(io.EOF)
+		}
+	}()
+	return s, nil
+}
+
+// Context returns the context of NewStream.
+func (s *ClientStream) Context() context.Context {
+	return s.ctx
+}
+
+// SendMsg sends m to the server. It returns io.EOF if the stream ended,
+// RecvMsg returns the status of the stream.
+func (s *ClientStream) SendMsg(m proto.Message) error {
+	select {
+	case <-s.done:
+		return io.EOF
+	default:
+	}
+
+	s.mutex.Lock()
+	halfClosed := s.halfClosed
+	s.mutex.Unlock()
+	if halfClosed {
+		return errors.New("protorpc: SendMsg called after CloseSend")
+	}
+	return s.codec.writeData(s.call.seq, m)
+}
+
+// CloseSend tells the server that the client sends no more messages.
+func (s *ClientStream) CloseSend() error {
+	s.mutex.Lock()
+	halfClosed := s.halfClosed
+	s.halfClosed = true
+	s.mutex.Unlock()
+	if halfClosed {
+		return nil
+	}
+
+	select {
+	case <-s.done:
+		return nil
+	default:
+	}
+	return s.codec.writeControl(s.call.seq, wire.FrameType_FRAME_STREAM_HALF_CLOSE)
+}
+
+// RecvMsg receives the next message of the server into m. It returns
+// io.EOF when the stream ended successfully, and the status error of
+// the stream otherwise.
+func (s *ClientStream) RecvMsg(m proto.Message) error {
+	return s.queue.recv(m, nil)
+}
+
+// Trailer returns the trailer sent by the server. It is only valid
+// after RecvMsg returned an error.
+func (s *ClientStream) Trailer() Metadata {
+	return *s.call.info.trailer
+}
@@ -0,0 +1,225 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"context"
+	"io"
+	"net/rpc"
+	"strings"
+	"testing"
+	"time"
+
+	msg "github.com/chai2010/protorpc/examples/message.pb"
+)
+
+type testStream struct {
+	started  chan struct{}
+	canceled chan error
+}
+
+// Repeat sends the message of the client 3 times.
+func (t *testStream) Repeat(stream *ServerStream, reply *StreamReply) error {
+	var args msg.EchoRequest
+	if err := stream.RecvMsg(&args); err != nil {
+		return err
+	}
+	for i := 0; i < 3; i++ {
+		if err := stream.SendMsg(&msg.EchoResponse{Msg: args.Msg}); err != nil {
+			return err
+		}
+	}
+	return SetTrailer(stream.Context(), NewMetadata("count", "3"))
+}
+
+// Concat sends the concatenation of the messages of the client.
+func (t *testStream) Concat(stream *ServerStream, reply *StreamReply) error {
+	var all []string
+	for {
+		var args msg.EchoRequest
+		err := stream.RecvMsg(&args)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		all = append(all, args.Msg)
+	}
+	return stream.SendMsg(&msg.EchoResponse{Msg: strings.Join(all, ",")})
+}
+
+// Echo sends back each message of the client.
+func (t *testStream) Echo(stream *ServerStream, reply *StreamReply) error {
+	for {
+		var args msg.EchoRequest
+		err := stream.RecvMsg(&args)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if args.Msg == "fail" {
+			return Errorf(CodeInvalidArgument, "bad message %q", args.Msg)
+		}
+		if err := stream.SendMsg(&msg.EchoResponse{Msg: args.Msg}); err != nil {
+			return err
+		}
+	}
+}
+
+// Wait waits for the client to cancel the stream.
+func (t *testStream) Wait(stream *ServerStream, reply *StreamReply) error {
+	t.started <- struct{}{}
+	var args msg.EchoRequest
+	err := stream.RecvMsg(&args)
+	t.canceled <- err
+	return err
+}
+
+// Hold waits until the context of the stream is done.
+func (t *testStream) Hold(stream *ServerStream, reply *StreamReply) error {
+	t.started <- struct{}{}
+	<-stream.Context().Done()
+	t.canceled <- stream.Context().Err()
+	return nil
+}
+
+func newTestStream() *testStream {
+	return &testStream{started: make(chan struct{}, 1), canceled: make(chan error, 1)}
+}
+
+func (t *testStream) register(srv *rpc.Server) error {
+	return srv.RegisterName("StreamService", t)
+}
+
+func TestStream(t *testing.T) {
+	client := newTestClient(t, &Options{Handshake: true}, nil, newTestStream().register)
+	ctx := context.Background()
+
+	// server streaming
+	stream, err := NewStream(ctx, client, "StreamService.Repeat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.SendMsg(&msg.EchoRequest{Msg: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for {
+		var reply msg.EchoResponse
+		err := stream.RecvMsg(&reply)
+		if err == io.EOF {
+			break
+		}
+		if err != nil || reply.Msg != "hi" {
+			t.Fatalf("Repeat: %v %q", err, reply.Msg)
+		}
+		n++
+	}
+	if n != 3 || stream.Trailer().Get("count") != "3" {
+		t.Fatalf("Repeat: got %d messages, trailer %v", n, stream.Trailer())
+	}
+
+	// client streaming
+	stream, err = NewStream(ctx, client, "StreamService.Concat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"a", "b", "c"} {
+		if err := stream.SendMsg(&msg.EchoRequest{Msg: s}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream.CloseSend()
+	var reply msg.EchoResponse
+	if err := stream.RecvMsg(&reply); err != nil || reply.Msg != "a,b,c" {
+		t.Fatalf("Concat: %v %q", err, reply.Msg)
+	}
+	if err := stream.RecvMsg(&reply); err != io.EOF {
+		t.Fatalf("Concat: expect io.EOF, got %v", err)
+	}
+
+	// bidirectional streaming, ended by an error
+	stream, err = NewStream(ctx, client, "StreamService.Echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"x", "y"} {
+		if err := stream.SendMsg(&msg.EchoRequest{Msg: s}); err != nil {
+			t.Fatal(err)
+		}
+		if err := stream.RecvMsg(&reply); err != nil || reply.Msg != s {
+			t.Fatalf("Echo: %v %q", err, reply.Msg)
+		}
+	}
+	stream.SendMsg(&msg.EchoRequest{Msg: "fail"})
+	if err := stream.RecvMsg(&reply); StatusFromError(err).Code != CodeInvalidArgument {
+		t.Fatalf("Echo: expect InvalidArgument, got %v", err)
+	}
+
+	// the connection is still usable
+	var echo msg.EchoResponse
+	if err := client.Call("StreamService.Unknown", &msg.EchoRequest{}, &echo); err == nil {
+		t.Fatalf("expect error")
+	}
+}
+
+func TestStreamCancel(t *testing.T) {
+	service := newTestStream()
+	client := newTestClient(t, &Options{Handshake: true}, nil, service.register)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := NewStream(ctx, client, "StreamService.Wait")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a request canceled before it is sent never reaches the server
+	<-service.started
+	cancel()
+
+	var reply msg.EchoResponse
+	if err := stream.RecvMsg(&reply); StatusFromError(err).Code != CodeCanceled {
+		t.Fatalf("expect Canceled, got %v", err)
+	}
+	if err := <-service.canceled; StatusFromError(err).Code != CodeCanceled {
+		t.Fatalf("server: expect Canceled, got %v", err)
+	}
+}
+
+func TestStreamHangUp(t *testing.T) {
+	service := newTestStream()
+	conn := dialTestServer(t, nil, service.register)
+	client := NewClientWithOptions(conn, &Options{Handshake: true})
+	defer client.Close()
+
+	if _, err := NewStream(context.Background(), client, "StreamService.Hold"); err != nil {
+		t.Fatal(err)
+	}
+	<-service.started
+
+	// the client hangs up without canceling the stream
+	conn.Close()
+	select {
+	case err := <-service.canceled:
+		if err != context.Canceled {
+			t.Fatalf("expect context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the context of the stream is not canceled")
+	}
+}
+
+func TestStreamNoHandshake(t *testing.T) {
+	client := newTestClient(t, nil, nil, newTestStream().register)
+
+	if _, err := NewStream(context.Background(), client, "StreamService.Repeat"); err == nil {
+		t.Fatalf("expect error")
+	}
+}
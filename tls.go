@@ -0,0 +1,117 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/rpc"
+)
+
+// DialTLS connects to a Protobuf-RPC server at the specified network
+// address over TLS. A nil config means the default tls.Config.
+func DialTLS(network, address string, config *tls.Config) (*rpc.Client, error) {
+	return DialTLSWithOptions(network, address, config, nil)
+}
+
+// DialTLSWithOptions is like DialTLS but uses the given options for the
+// connection.
+func DialTLSWithOptions(network, address string, config *tls.Config, opts *Options) (*rpc.Client, error) {
+	conn, err := tls.Dial(network, address, config)
+	if err != nil {
+		return nil, err
+	}
+	return NewClientWithOptions(conn, opts), nil
+}
+
+// Serve accepts connections on the listener and serves the services of
+// srv on each of them with the given options. Serve blocks until Accept
+// fails, and returns its error.
+func Serve(lis net.Listener, srv *rpc.Server, opts *Options) error {
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			return err
+		}
+		go srv.ServeCodec(NewServerCodecWithOptions(conn, opts))
+	}
+}
+
+// ListenAndServeTLS listens on the local network address over TLS and
+// serves the services of srv, see Serve. config must have a server
+// certificate, see NewServerTLSConfig. The handlers get the identity of
+// the client from the request context, see PeerFromContext.
+func ListenAndServeTLS(network, address string, srv *rpc.Server, config *tls.Config, opts *Options) error {
+	lis, err := tls.Listen(network, address, config)
+	if err != nil {
+		return err
+	}
+	defer lis.Close()
+	return Serve(lis, srv, opts)
+}
+
+// NewServerTLSConfig returns the TLS config of a server with the
+// certificate and key of the PEM files certFile and keyFile.
+//
+// If clientCAFile is not empty, the server uses mutual TLS: the clients
+// must send a certificate signed by one of the certificate authorities
+// of the PEM file clientCAFile.
+func NewServerTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCAFile != "" {
+		if config.ClientCAs, err = loadCertPool(clientCAFile); err != nil {
+			return nil, err
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// NewClientTLSConfig returns the TLS config of a client which verifies
+// the server with the certificate authorities of the PEM file caFile,
+// or with the system roots if caFile is empty.
+//
+// If certFile is not empty, the client sends the certificate and key of
+// the PEM files certFile and keyFile to servers using mutual TLS.
+func NewClientTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		var err error
+		if config.RootCAs, err = loadCertPool(caFile); err != nil {
+			return nil, err
+		}
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// loadCertPool returns the certificates of a PEM file.
+func loadCertPool(file string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("protorpc: no certificate in %s", file)
+	}
+	return pool, nil
+}
@@ -0,0 +1,243 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/rpc"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chai2010/protorpc"
+	msg "github.com/chai2010/protorpc/examples/message.pb"
+)
+
+type PeerService int
+
+// Whoami replies if the connection uses TLS, and the subject of the
+// client certificate.
+func (t *PeerService) Whoami(args *msg.EchoRequest, reply *msg.EchoResponse) error {
+	p, ok := protorpc.PeerFromContext(protorpc.RequestContext(args))
+	if !ok || p.Addr == nil {
+		return protorpc.Errorf(protorpc.CodeInternal, "no peer")
+	}
+	reply.Msg = fmt.Sprintf("%v %s", p.TLS != nil, p.Subject())
+	return nil
+}
+
+// testCerts are the PEM files of a certificate authority, and of a
+// server and a client certificate signed by it.
+type testCerts struct {
+	ca                    string
+	serverCert, serverKey string
+	clientCert, clientKey string
+}
+
+func newTestCerts(t *testing.T) *testCerts {
+	dir := t.TempDir()
+	notAfter := time.Now().Add(time.Hour)
+
+	caKey, caFile, _ := writeTestCert(t, dir, "ca", &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "protorpc test CA"},
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}, nil, nil)
+	caCert := readTestCert(t, caFile)
+
+	certs := &testCerts{ca: caFile}
+	_, certs.serverCert, certs.serverKey = writeTestCert(t, dir, "server", &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "server"},
+		NotAfter:     notAfter,
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, caCert, caKey)
+	_, certs.clientCert, certs.clientKey = writeTestCert(t, dir, "client", &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "alice", Organization: []string{"Acme"}},
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, caCert, caKey)
+	return certs
+}
+
+// writeTestCert writes the certificate of template signed by parent, or
+// self-signed if parent is nil, and its new key, to PEM files.
+func writeTestCert(t *testing.T, dir, name string, template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (key *ecdsa.PrivateKey, certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, name+".pem")
+	keyFile = filepath.Join(dir, name+".key")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return key, certFile, keyFile
+}
+
+func readTestCert(t *testing.T, file string) *x509.Certificate {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(data)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func registerPeerService(srv *rpc.Server) error {
+	return srv.RegisterName("PeerService", new(PeerService))
+}
+
+// startTLSServer serves PeerService over TLS, and returns its address.
+func startTLSServer(t *testing.T, config *tls.Config) string {
+	srv := rpc.NewServer()
+	if err := registerPeerService(srv); err != nil {
+		t.Fatal(err)
+	}
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lis.Close() })
+	go protorpc.Serve(lis, srv, nil)
+	return lis.Addr().String()
+}
+
+func whoami(client *rpc.Client) (string, error) {
+	var reply msg.EchoResponse
+	err := client.Call("PeerService.Whoami", &msg.EchoRequest{}, &reply)
+	return reply.Msg, err
+}
+
+func TestTLS(t *testing.T) {
+	certs := newTestCerts(t)
+	serverConfig, err := protorpc.NewServerTLSConfig(certs.serverCert, certs.serverKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := startTLSServer(t, serverConfig)
+
+	clientConfig, err := protorpc.NewClientTLSConfig(certs.ca, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := protorpc.DialTLS("tcp", addr, clientConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	got, err := whoami(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "true " {
+		t.Fatalf("expected a TLS peer without certificate, got %q", got)
+	}
+
+	// the server is verified
+	untrusted, err := protorpc.NewClientTLSConfig("", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client, err := protorpc.DialTLS("tcp", addr, untrusted); err == nil {
+		client.Close()
+		t.Fatal("expected an unknown authority error")
+	}
+
+	// connections without TLS have a peer too
+	plain := newTestClient(t, nil, nil, registerPeerService)
+	if got, err := whoami(plain); err != nil || got != "false " {
+		t.Fatalf("expected a peer without TLS, got %q, %v", got, err)
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	certs := newTestCerts(t)
+	serverConfig, err := protorpc.NewServerTLSConfig(certs.serverCert, certs.serverKey, certs.ca)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := startTLSServer(t, serverConfig)
+
+	clientConfig, err := protorpc.NewClientTLSConfig(certs.ca, certs.clientCert, certs.clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := protorpc.DialTLSWithOptions("tcp", addr, clientConfig, &protorpc.Options{Handshake: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	got, err := whoami(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "true CN=alice,O=Acme" {
+		t.Fatalf("expected = %q, got = %q", "true CN=alice,O=Acme", got)
+	}
+
+	// clients without certificate are rejected
+	anonymous, err := protorpc.NewClientTLSConfig(certs.ca, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err = protorpc.DialTLS("tcp", addr, anonymous)
+	if err == nil {
+		defer client.Close()
+		_, err = whoami(client)
+	}
+	if err == nil {
+		t.Fatal("expected the client without certificate to be rejected")
+	}
+}
+
+func TestTLSConfigFiles(t *testing.T) {
+	certs := newTestCerts(t)
+	if _, err := protorpc.NewServerTLSConfig(certs.serverCert, certs.serverKey, certs.serverKey); err == nil {
+		t.Fatal("expected an error for a CA file without certificate")
+	}
+	if _, err := protorpc.NewClientTLSConfig(certs.ca, certs.clientCert, certs.serverKey); err == nil {
+		t.Fatal("expected an error for a key of another certificate")
+	}
+	if _, err := protorpc.NewClientTLSConfig(filepath.Join(t.TempDir(), "missing.pem"), "", ""); err == nil {
+		t.Fatal("expected an error for a missing CA file")
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc
+
+import (
+	"context"
+	"sync"
+)
+
+// TraceContext is the W3C trace context of a call
+// (https://www.w3.org/TR/trace-context/): the values of the traceparent
+// and tracestate HTTP headers.
+type TraceContext struct {
+	TraceParent string // like "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	TraceState  string // like "congo=t61rcWkgMzE", may be empty
+}
+
+// maxTraceStateLen is the size of the tracestate sent with a request,
+// the size every tracing system should propagate. A longer tracestate
+// is not sent.
+const maxTraceStateLen = 512
+
+type traceContextKey struct{}
+
+// NewTraceContext returns a copy of ctx with the trace context tc. The
+// client sends the trace context of the ctx of a call with the request,
+// and the server puts it in the context of the handler, see
+// RequestContext.
+func NewTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, tc)
+}
+
+// TraceContextFromContext returns the trace context of ctx, if any.
+func TraceContextFromContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceContextKey{}).(TraceContext)
+	return tc, ok
+}
+
+// A Tracer starts the spans of the calls of a codec, see Options.Tracer.
+// It is the hook of a tracing backend, which keeps its spans in the
+// contexts and converts them to and from TraceContext.
+type Tracer interface {
+	// StartClientSpan starts the span of a call made with ctx, when the
+	// request is written. The trace context of the returned context is
+	// sent with the request.
+	StartClientSpan(ctx context.Context, serviceMethod string) (context.Context, Span)
+
+	// StartServerSpan starts the span of a received call, when the
+	// request is read. ctx has the trace context of the request, if
+	// any. The returned context is the parent of the context of the
+	// handler.
+	StartServerSpan(ctx context.Context, serviceMethod string) (context.Context, Span)
+}
+
+// A Span is the span of a call started by a Tracer.
+type Span interface {
+	// End ends the span with the error of the call, nil on success.
+	// The client ends the span once the response is read, the server
+	// once the response is written.
+	End(err error)
+}
+
+// callSpan is the span of a client call, which may be ended by the
+// response and by the failure of the connection.
+type callSpan struct {
+	span Span
+	once sync.Once
+}
+
+// end ends the span, unless it is already ended.
+func (s *callSpan) end(err error) {
+	s.once.Do(func() { s.span.End(err) })
+}
+
+// traceHeader returns the trace context of ctx sent with a request.
+func traceHeader(ctx context.Context) (traceparent, tracestate string) {
+	tc, ok := TraceContextFromContext(ctx)
+	if !ok || !validTraceParent(tc.TraceParent) {
+		return "", ""
+	}
+	if len(tc.TraceState) > maxTraceStateLen {
+		return tc.TraceParent, ""
+	}
+	return tc.TraceParent, tc.TraceState
+}
+
+// traceContext returns ctx with the trace context of a request, if it
+// has a valid one.
+func traceContext(ctx context.Context, traceparent, tracestate string) context.Context {
+	if !validTraceParent(traceparent) {
+		return ctx
+	}
+	return NewTraceContext(ctx, TraceContext{TraceParent: traceparent, TraceState: tracestate})
+}
+
+// validTraceParent reports if s is a traceparent value:
+// version "-" trace-id "-" parent-id "-" trace-flags, in lower case hex,
+// with a trace-id and parent-id which are not all zeros. Versions after
+// 00 may append fields.
+func validTraceParent(s string) bool {
+	const n = 2 + 1 + 32 + 1 + 16 + 1 + 2
+	if len(s) < n || s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return false
+	}
+	version, traceID, parentID, flags := s[0:2], s[3:35], s[36:52], s[53:55]
+	if !isLowerHex(version) || version == "ff" || !isLowerHex(flags) {
+		return false
+	}
+	if !isLowerHex(traceID) || isZeros(traceID) || !isLowerHex(parentID) || isZeros(parentID) {
+		return false
+	}
+	if len(s) > n {
+		return version != "00" && s[n] == '-'
+	}
+	return true
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func isZeros(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] != '0' {
+			return false
+		}
+	}
+	return true
+}
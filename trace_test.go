@@ -0,0 +1,248 @@
+// Copyright 2021 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protorpc_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/rpc"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chai2010/protorpc"
+	msg "github.com/chai2010/protorpc/examples/message.pb"
+)
+
+type TraceService struct {
+	release chan struct{} // closed to return from Block
+}
+
+func (t *TraceService) register(srv *rpc.Server) error {
+	return srv.RegisterName("TraceService", t)
+}
+
+// Echo replies with the trace context of the request, it fails if the
+// message is "fail".
+func (t *TraceService) Echo(args *msg.EchoRequest, reply *msg.EchoResponse) error {
+	if args.Msg == "fail" {
+		return errors.New("echo failed")
+	}
+	tc, _ := protorpc.TraceContextFromContext(protorpc.RequestContext(args))
+	reply.Msg = tc.TraceParent + " " + tc.TraceState
+	return nil
+}
+
+// Block waits until the request is canceled, or released.
+func (t *TraceService) Block(args *msg.EchoRequest, reply *msg.EchoResponse) error {
+	select {
+	case <-protorpc.RequestContext(args).Done():
+	case <-t.release:
+	}
+	return nil
+}
+
+// testTracer starts spans whose trace context has the trace id of the
+// parent span, if any.
+type testTracer struct {
+	mutex sync.Mutex
+	spans []*testSpan
+}
+
+type testSpan struct {
+	method string
+	parent protorpc.TraceContext // the trace context of the parent span
+	tc     protorpc.TraceContext
+	ended  chan error
+}
+
+func (s *testSpan) End(err error) {
+	s.ended <- err
+}
+
+func (t *testTracer) StartClientSpan(ctx context.Context, serviceMethod string) (context.Context, protorpc.Span) {
+	return t.start(ctx, serviceMethod)
+}
+
+func (t *testTracer) StartServerSpan(ctx context.Context, serviceMethod string) (context.Context, protorpc.Span) {
+	return t.start(ctx, serviceMethod)
+}
+
+func (t *testTracer) start(ctx context.Context, serviceMethod string) (context.Context, protorpc.Span) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	span := &testSpan{method: serviceMethod, ended: make(chan error, 2)}
+	span.parent, _ = protorpc.TraceContextFromContext(ctx)
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	if span.parent.TraceParent != "" {
+		traceID = span.parent.TraceParent[3:35]
+	}
+	span.tc = protorpc.TraceContext{
+		TraceParent: fmt.Sprintf("00-%s-%016x-01", traceID, len(t.spans)+1),
+		TraceState:  span.parent.TraceState,
+	}
+	t.spans = append(t.spans, span)
+	return protorpc.NewTraceContext(ctx, span.tc), span
+}
+
+// span waits for the i-th span started by the tracer.
+func (tr *testTracer) span(t *testing.T, i int) *testSpan {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		tr.mutex.Lock()
+		n := len(tr.spans)
+		tr.mutex.Unlock()
+		if n > i {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d spans, got %d", i+1, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	return tr.spans[i]
+}
+
+// wait returns the error the span ended with.
+func (s *testSpan) wait(t *testing.T) error {
+	t.Helper()
+	select {
+	case err := <-s.ended:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the span of %s did not end", s.method)
+		return nil
+	}
+}
+
+func TestTraceContext(t *testing.T) {
+	client := newTestClient(t, nil, nil, new(TraceService).register)
+
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	longState := strings.Repeat("a=b,", 200)
+	for _, tt := range []struct {
+		tc   protorpc.TraceContext
+		want string
+	}{
+		{protorpc.TraceContext{TraceParent: parent, TraceState: "congo=t61rcWkgMzE"}, parent + " congo=t61rcWkgMzE"},
+		{protorpc.TraceContext{TraceParent: parent}, parent + " "},
+		{protorpc.TraceContext{TraceParent: parent, TraceState: longState}, parent + " "},
+		{protorpc.TraceContext{TraceParent: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-next"}, "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-next "},
+
+		// malformed traceparents are not sent, nor their tracestate
+		{protorpc.TraceContext{TraceParent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01", TraceState: "a=b"}, " "},
+		{protorpc.TraceContext{TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"}, " "},
+		{protorpc.TraceContext{TraceParent: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"}, " "},
+		{protorpc.TraceContext{TraceParent: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}, " "},
+		{protorpc.TraceContext{TraceParent: parent + "-next"}, " "},
+		{protorpc.TraceContext{TraceParent: parent[:54]}, " "},
+		{protorpc.TraceContext{TraceParent: "not a traceparent"}, " "},
+	} {
+		ctx := protorpc.NewTraceContext(context.Background(), tt.tc)
+		var reply msg.EchoResponse
+		if err := protorpc.CallContext(ctx, client, "TraceService.Echo", &msg.EchoRequest{}, &reply); err != nil {
+			t.Fatal(err)
+		}
+		if reply.Msg != tt.want {
+			t.Fatalf("%v: expected = %q, got = %q", tt.tc, tt.want, reply.Msg)
+		}
+	}
+
+	// plain calls have no trace context
+	var reply msg.EchoResponse
+	if err := client.Call("TraceService.Echo", &msg.EchoRequest{}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Msg != " " {
+		t.Fatalf("expected no trace context, got %q", reply.Msg)
+	}
+}
+
+func TestTracer(t *testing.T) {
+	clientTracer, serverTracer := new(testTracer), new(testTracer)
+	service := &TraceService{release: make(chan struct{})}
+	defer close(service.release) // the server waits for the calls when it is closed
+	client := newTestClient(t,
+		&protorpc.Options{Handshake: true, Tracer: clientTracer},
+		&protorpc.Options{Tracer: serverTracer},
+		service.register,
+	)
+
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx := protorpc.NewTraceContext(context.Background(), protorpc.TraceContext{TraceParent: parent, TraceState: "congo=t61rcWkgMzE"})
+	var reply msg.EchoResponse
+	if err := protorpc.CallContext(ctx, client, "TraceService.Echo", &msg.EchoRequest{}, &reply); err != nil {
+		t.Fatal(err)
+	}
+
+	// the client span is a child of the caller, the server span a child
+	// of the client span, and the handler a child of the server span
+	clientSpan, serverSpan := clientTracer.span(t, 0), serverTracer.span(t, 0)
+	if clientSpan.method != "TraceService.Echo" || clientSpan.parent.TraceParent != parent {
+		t.Fatalf("unexpected client span %+v", clientSpan)
+	}
+	if serverSpan.method != "TraceService.Echo" || serverSpan.parent != clientSpan.tc {
+		t.Fatalf("expected the parent %+v, got %+v", clientSpan.tc, serverSpan.parent)
+	}
+	if want := serverSpan.tc.TraceParent + " " + serverSpan.tc.TraceState; reply.Msg != want {
+		t.Fatalf("expected = %q, got = %q", want, reply.Msg)
+	}
+	if err := clientSpan.wait(t); err != nil {
+		t.Fatalf("unexpected client span error %v", err)
+	}
+	if err := serverSpan.wait(t); err != nil {
+		t.Fatalf("unexpected server span error %v", err)
+	}
+
+	// the spans end with the error of the call
+	err := client.Call("TraceService.Echo", &msg.EchoRequest{Msg: "fail"}, &reply)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if err := clientTracer.span(t, 1).wait(t); err == nil || err.Error() != "echo failed" {
+		t.Fatalf("expected the error of the call, got %v", err)
+	}
+	if err := serverTracer.span(t, 1).wait(t); err == nil || err.Error() != "echo failed" {
+		t.Fatalf("expected the error of the call, got %v", err)
+	}
+
+	// and with Canceled for canceled calls
+	ctx, cancel := context.WithCancel(context.Background())
+	call := protorpc.GoContext(ctx, client, "TraceService.Block", &msg.EchoRequest{}, &reply, nil)
+	serverTracer.span(t, 2)
+	cancel()
+	<-call.Done
+	if err := clientTracer.span(t, 2).wait(t); protorpc.StatusFromError(err).Code != protorpc.CodeCanceled {
+		t.Fatalf("expected Canceled, got %v", err)
+	}
+	if err := serverTracer.span(t, 2).wait(t); protorpc.StatusFromError(err).Code != protorpc.CodeCanceled {
+		t.Fatalf("expected Canceled, got %v", err)
+	}
+
+	// the client span of a one-way call ends when the request is sent
+	if err := protorpc.OneWay(context.Background(), client, "TraceService.Echo", &msg.EchoRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := clientTracer.span(t, 3).wait(t); err != nil {
+		t.Fatalf("unexpected client span error %v", err)
+	}
+	if err := serverTracer.span(t, 3).wait(t); err != nil {
+		t.Fatalf("unexpected server span error %v", err)
+	}
+
+	// the spans of the pending calls end when the connection is closed
+	call = client.Go("TraceService.Block", &msg.EchoRequest{}, &reply, nil)
+	serverTracer.span(t, 4)
+	client.Close()
+	<-call.Done
+	if err := clientTracer.span(t, 4).wait(t); err == nil {
+		t.Fatal("expected an error")
+	}
+}
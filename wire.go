@@ -6,14 +6,17 @@ package protorpc
 
 import (
 	"fmt"
-	"hash/crc32"
 	"io"
 
 	wire "github.com/chai2010/protorpc/wire.pb"
 	"github.com/golang/protobuf/proto"
-	"github.com/golang/snappy"
 )
 
+// UseSnappy and UseCrc32ChecksumIEEE are the default values of
+// Options.Compression and Options.Checksum. They are read once when
+// a codec is created.
+//
+// Deprecated: use NewClientCodecWithOptions or NewServerCodecWithOptions.
 var (
 	UseSnappy            = true
 	UseCrc32ChecksumIEEE = true
@@ -26,225 +29,280 @@ func maxUint32(a, b uint32) uint32 {
 	return b
 }
 
-func writeRequest(w io.Writer, id uint64, method string, request proto.Message) error {
+// writeRequest sends header and request. The caller fills the id, method,
+// checksum type, serialization and metadata of header, writeRequest
+// fills the rest.
+func writeRequest(w io.Writer, opts *Options, stats *compressStats, compressor Compressor, header *wire.RequestHeader, request proto.Message) error {
+	body, err := encodeRequest(opts, stats, compressor, header, request)
+	if err != nil {
+		return err
+	}
+	defer putBuffer(body)
+	pbHeader, err := proto.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	// send header (more) and body (end)
+	var fw frameWriter
+	return fw.write(w, pbHeader, body)
+}
+
+// encodeRequest returns the body of request, and fills the lengths and
+// checksum of header like writeRequest. The body is a pooled buffer,
+// returned with putBuffer once sent.
+func encodeRequest(opts *Options, stats *compressStats, compressor Compressor, header *wire.RequestHeader, request proto.Message) ([]byte, error) {
+	// check metadata size
+	if n := wireMetadataSize(header.Metadata); n > metadataLimit(opts) {
+		return nil, limitError(ErrMetadataTooLarge, n, metadataLimit(opts))
+	}
+
 	// marshal request
 	pbRequest := []byte{}
 	if request != nil {
-		var err error
-		pbRequest, err = proto.Marshal(request)
+		serializer, err := getSerializerByID(header.Serialization)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		pbRequest, err = serializer.Marshal(request)
+		if err != nil {
+			return nil, err
 		}
 	}
 
 	// compress serialized proto data
-	compressedPbRequest := snappy.Encode(nil, pbRequest)
-
-	// generate header
-	header := &wire.RequestHeader{
-		Id:                         id,
-		Method:                     method,
-		RawRequestLen:              uint32(len(pbRequest)),
-		SnappyCompressedRequestLen: uint32(len(compressedPbRequest)),
-		Checksum:                   crc32.ChecksumIEEE(compressedPbRequest),
+	compressedPbRequest, compressed, err := compressBody(opts, stats, compressor, header.Method, pbRequest)
+	if err != nil {
+		putBuffer(pbRequest)
+		return nil, err
 	}
 
-	if !UseSnappy {
-		header.SnappyCompressedRequestLen = 0
-		compressedPbRequest = pbRequest
-	}
-	if !UseCrc32ChecksumIEEE {
-		header.Checksum = 0
+	// generate header
+	header.RawRequestLen = uint32(len(pbRequest))
+	header.Compression = compressor.ID()
+	if compressed {
+		header.SnappyCompressedRequestLen = uint32(len(compressedPbRequest))
 	}
+	header.Checksum, header.Checksum64 = checksum(uint32(header.ChecksumType), compressedPbRequest)
 
 	// check header size
-	pbHeader, err := proto.Marshal(header)
-	if err != err {
-		return err
-	}
-	if len(pbHeader) > int(wire.Const_MAX_REQUEST_HEADER_LEN) {
-		return fmt.Errorf("protorpc.writeRequest: header larger than max_header_len: %d.", len(pbHeader))
+	if n := proto.Size(header); n > requestHeaderLimit(opts) {
+		putBuffer(compressedPbRequest)
+		return nil, limitError(ErrHeaderTooLarge, n, requestHeaderLimit(opts))
 	}
 
-	// send header (more)
-	if err := sendFrame(w, pbHeader); err != nil {
+	return compressedPbRequest, nil
+}
+
+func readRequestHeader(r io.Reader, opts *Options, header *wire.RequestHeader) (err error) {
+	// recv header (more)
+	err = recvMessage(r, requestHeaderLimit(opts), header)
+	if err != nil {
 		return err
 	}
 
-	// send body (end)
-	if err := sendFrame(w, compressedPbRequest); err != nil {
-		return err
+	// check metadata size
+	if n := wireMetadataSize(header.Metadata); n > metadataLimit(opts) {
+		return limitError(ErrMetadataTooLarge, n, metadataLimit(opts))
 	}
 
-	return nil
+	return checkBodyLen(opts, header.RawRequestLen, header.SnappyCompressedRequestLen)
 }
 
-func readRequestHeader(r io.Reader, header *wire.RequestHeader) (err error) {
-	// recv header (more)
-	pbHeader, err := recvFrame(r, int(wire.Const_MAX_REQUEST_HEADER_LEN))
+func readRequestBody(r io.Reader, opts *Options, header *wire.RequestHeader, request proto.Message) error {
+	compressedPbRequest, err := recvRequestBody(r, opts, header)
 	if err != nil {
 		return err
 	}
-
-	// Marshal Header
-	err = proto.Unmarshal(pbHeader, header)
+	pbRequest, err := openRequestBody(header, compressedPbRequest)
 	if err != nil {
 		return err
 	}
-
-	return nil
+	defer putBuffer(pbRequest)
+	return decodeBody(header.Serialization, pbRequest, request)
 }
 
-func readRequestBody(r io.Reader, header *wire.RequestHeader, request proto.Message) error {
-	maxBodyLen := maxUint32(header.RawRequestLen, header.SnappyCompressedRequestLen)
+// recvRequestBody receives the body of header without decoding it, into
+// a pooled buffer.
+func recvRequestBody(r io.Reader, opts *Options, header *wire.RequestHeader) ([]byte, error) {
+	maxBodyLen := bodyLimit(opts, maxUint32(header.RawRequestLen, header.SnappyCompressedRequestLen))
 
 	// recv body (end)
-	compressedPbRequest, err := recvFrame(r, int(maxBodyLen))
+	return recvBody(r, maxBodyLen)
+}
+
+// openRequestBody checks the body received by recvRequestBody and
+// decompresses it. It returns the body, or the decompressed body in a
+// pooled buffer and releases the received one.
+//
+// An error means the frames are corrupted: ErrChecksumMismatch or
+// ErrBadCompression.
+func openRequestBody(header *wire.RequestHeader, compressedPbRequest []byte) ([]byte, error) {
+	// checksum
+	if !verifyChecksum(uint32(header.ChecksumType), header.Checksum, header.Checksum64, compressedPbRequest) {
+		return nil, ErrChecksumMismatch
+	}
+	if header.SnappyCompressedRequestLen == 0 {
+		return compressedPbRequest, nil
+	}
+
+	compressor, err := getCompressorByID(header.Compression, header.SnappyCompressedRequestLen)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// checksum
-	if header.Checksum != 0 {
-		if crc32.ChecksumIEEE(compressedPbRequest) != header.Checksum {
-			return fmt.Errorf("protorpc.readRequestBody: unexpected checksum.")
-		}
+	// decode the compressed data
+	pbRequest, err := openBody(compressor, compressedPbRequest, header.RawRequestLen)
+	if err != nil {
+		return nil, err
 	}
+	putBuffer(compressedPbRequest)
+	return pbRequest, nil
+}
 
-	var pbRequest []byte
-	if header.SnappyCompressedRequestLen != 0 {
-		// decode the compressed data
-		pbRequest, err = snappy.Decode(nil, compressedPbRequest)
-		if err != nil {
-			return err
-		}
-		// check wire header: rawMsgLen
-		if uint32(len(pbRequest)) != header.RawRequestLen {
-			return fmt.Errorf("protorpc.readRequestBody: Unexcpeted header.RawRequestLen.")
+// openBody decompresses a body into a pooled buffer, and checks the raw
+// length from the header.
+func openBody(compressor Compressor, body []byte, rawLen uint32) ([]byte, error) {
+	raw, pooled, err := decompressBody(compressor, body, int(rawLen))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadCompression, err)
+	}
+	// check wire header: rawMsgLen
+	if uint32(len(raw)) != rawLen {
+		if pooled {
+			putBuffer(raw)
 		}
-	} else {
-		pbRequest = compressedPbRequest
+		return nil, fmt.Errorf("%w: decompressed %d bytes, expect %d", ErrBadCompression, len(raw), rawLen)
+	}
+	if !pooled {
+		raw = append(getBuffer(len(raw))[:0], raw...)
 	}
+	return raw, nil
+}
 
-	// Unmarshal to proto message
-	if request != nil {
-		err = proto.Unmarshal(pbRequest, request)
-		if err != nil {
-			return err
-		}
+// decodeBody unmarshals a body opened by openRequestBody or
+// openResponseBody into m, if not nil, with the serializer of the header.
+func decodeBody(serialization uint32, raw []byte, m proto.Message) error {
+	if m == nil {
+		return nil
+	}
+	serializer, err := getSerializerByID(serialization)
+	if err != nil {
+		return err
 	}
+	return serializer.Unmarshal(raw, m)
+}
 
-	return nil
+// writeResponse sends header and response. The caller fills the id,
+// error, status, checksum type, serialization and trailer of header,
+// writeResponse fills the rest.
+func writeResponse(w io.Writer, opts *Options, stats *compressStats, compressor Compressor, method string, header *wire.ResponseHeader, response proto.Message) (err error) {
+	body, err := encodeResponse(opts, stats, compressor, method, header, response)
+	if err != nil {
+		return err
+	}
+	defer putBuffer(body)
+	pbHeader, err := proto.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	// send header (more) and body (end)
+	var fw frameWriter
+	return fw.write(w, pbHeader, body)
 }
 
-func writeResponse(w io.Writer, id uint64, serr string, response proto.Message) (err error) {
+// encodeResponse returns the body of response, and fills the lengths and
+// checksum of header like writeResponse. The body is a pooled buffer,
+// returned with putBuffer once sent.
+func encodeResponse(opts *Options, stats *compressStats, compressor Compressor, method string, header *wire.ResponseHeader, response proto.Message) (body []byte, err error) {
 	// clean response if error
-	if serr != "" {
+	if header.Error != "" {
 		response = nil
 	}
 
 	// marshal response
 	pbResponse := []byte{}
 	if response != nil {
-		pbResponse, err = proto.Marshal(response)
+		serializer, err := getSerializerByID(header.Serialization)
+		if err != nil {
+			return nil, err
+		}
+		pbResponse, err = serializer.Marshal(response)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	// compress serialized proto data
-	compressedPbResponse := snappy.Encode(nil, pbResponse)
-
-	// generate header
-	header := &wire.ResponseHeader{
-		Id:                          id,
-		Error:                       serr,
-		RawResponseLen:              uint32(len(pbResponse)),
-		SnappyCompressedResponseLen: uint32(len(compressedPbResponse)),
-		Checksum:                    crc32.ChecksumIEEE(compressedPbResponse),
+	compressedPbResponse, compressed, err := compressBody(opts, stats, compressor, method, pbResponse)
+	if err != nil {
+		putBuffer(pbResponse)
+		return nil, err
 	}
 
-	if !UseSnappy {
-		header.SnappyCompressedResponseLen = 0
-		compressedPbResponse = pbResponse
-	}
-	if !UseCrc32ChecksumIEEE {
-		header.Checksum = 0
+	// generate header
+	header.RawResponseLen = uint32(len(pbResponse))
+	header.Compression = compressor.ID()
+	if compressed {
+		header.SnappyCompressedResponseLen = uint32(len(compressedPbResponse))
 	}
+	header.Checksum, header.Checksum64 = checksum(uint32(header.ChecksumType), compressedPbResponse)
 
 	// check header size
-	pbHeader, err := proto.Marshal(header)
-	if err != err {
-		return
-	}
-
-	// send header (more)
-	if err = sendFrame(w, pbHeader); err != nil {
-		return
+	if n := proto.Size(header); n > responseHeaderLimit(opts) {
+		putBuffer(compressedPbResponse)
+		return nil, limitError(ErrHeaderTooLarge, n, responseHeaderLimit(opts))
 	}
 
-	// send body (end)
-	if err = sendFrame(w, compressedPbResponse); err != nil {
-		return
-	}
-
-	return nil
+	return compressedPbResponse, nil
 }
 
-func readResponseHeader(r io.Reader, header *wire.ResponseHeader) error {
+func readResponseHeader(r io.Reader, opts *Options, header *wire.ResponseHeader) error {
 	// recv header (more)
-	pbHeader, err := recvFrame(r, 0)
+	err := recvMessage(r, responseHeaderLimit(opts), header)
 	if err != nil {
 		return err
 	}
 
-	// Marshal Header
-	err = proto.Unmarshal(pbHeader, header)
-	if err != nil {
-		return err
+	// check trailer size
+	if n := wireMetadataSize(header.Trailer); n > metadataLimit(opts) {
+		return limitError(ErrMetadataTooLarge, n, metadataLimit(opts))
 	}
 
-	return nil
+	return checkBodyLen(opts, header.RawResponseLen, header.SnappyCompressedResponseLen)
 }
 
-func readResponseBody(r io.Reader, header *wire.ResponseHeader, response proto.Message) error {
-	maxBodyLen := int(maxUint32(header.RawResponseLen, header.SnappyCompressedResponseLen))
+// recvResponseBody receives the body of header without decoding it, into
+// a pooled buffer.
+func recvResponseBody(r io.Reader, opts *Options, header *wire.ResponseHeader) ([]byte, error) {
+	maxBodyLen := bodyLimit(opts, maxUint32(header.RawResponseLen, header.SnappyCompressedResponseLen))
 
 	// recv body (end)
-	compressedPbResponse, err := recvFrame(r, maxBodyLen)
-	if err != nil {
-		return err
-	}
+	return recvBody(r, maxBodyLen)
+}
 
+// openResponseBody checks the body received by recvResponseBody and
+// decompresses it, like openRequestBody.
+func openResponseBody(header *wire.ResponseHeader, compressedPbResponse []byte) ([]byte, error) {
 	// checksum
-	if header.Checksum != 0 {
-		if crc32.ChecksumIEEE(compressedPbResponse) != header.Checksum {
-			return fmt.Errorf("protorpc.readResponseBody: unexpected checksum.")
-		}
+	if !verifyChecksum(uint32(header.ChecksumType), header.Checksum, header.Checksum64, compressedPbResponse) {
+		return nil, ErrChecksumMismatch
 	}
-
-	var pbResponse []byte
-	if header.SnappyCompressedResponseLen != 0 {
-		// decode the compressed data
-		pbResponse, err = snappy.Decode(nil, compressedPbResponse)
-		if err != nil {
-			return err
-		}
-		// check wire header: rawMsgLen
-		if uint32(len(pbResponse)) != header.RawResponseLen {
-			return fmt.Errorf("protorpc.readResponseBody: Unexcpeted header.RawResponseLen.")
-		}
-	} else {
-		pbResponse = compressedPbResponse
+	if header.SnappyCompressedResponseLen == 0 {
+		return compressedPbResponse, nil
 	}
 
-	// Unmarshal to proto message
-	if response != nil {
-		err = proto.Unmarshal(pbResponse, response)
-		if err != nil {
-			return err
-		}
+	compressor, err := getCompressorByID(header.Compression, header.SnappyCompressedResponseLen)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	// decode the compressed data
+	pbResponse, err := openBody(compressor, compressedPbResponse, header.RawResponseLen)
+	if err != nil {
+		return nil, err
+	}
+	putBuffer(compressedPbResponse)
+	return pbResponse, nil
 }
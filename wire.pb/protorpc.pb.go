@@ -0,0 +1,44 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: protorpc.proto
+
+package protorpc_wire
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+import google_protobuf1 "github.com/golang/protobuf/protoc-gen-go/descriptor"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+var E_OneWay = &proto.ExtensionDesc{
+	ExtendedType:  (*google_protobuf1.MethodOptions)(nil),
+	ExtensionType: (*bool)(nil),
+	Field:         51414,
+	Name:          "protorpc.wire.one_way",
+	Tag:           "varint,51414,opt,name=one_way,json=oneWay",
+	Filename:      "protorpc.proto",
+}
+
+func init() {
+	proto.RegisterExtension(E_OneWay)
+}
+
+func init() { proto.RegisterFile("protorpc.proto", fileDescriptor1) }
+
+var fileDescriptor1 = []byte{
+	// 161 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0x2b, 0x28, 0xca, 0x2f,
+	0xc9, 0x2f, 0x2a, 0x48, 0xd6, 0x03, 0x33, 0x84, 0x78, 0xe1, 0xfc, 0xf2, 0xcc, 0xa2, 0x54, 0x29,
+	0x85, 0xf4, 0xfc, 0xfc, 0xf4, 0x9c, 0x54, 0x7d, 0xb0, 0x68, 0x52, 0x69, 0x9a, 0x7e, 0x4a, 0x6a,
+	0x71, 0x72, 0x51, 0x66, 0x41, 0x49, 0x7e, 0x11, 0x44, 0x83, 0x95, 0x25, 0x17, 0x7b, 0x7e, 0x5e,
+	0x6a, 0x7c, 0x79, 0x62, 0xa5, 0x90, 0x9c, 0x1e, 0x44, 0xb5, 0x1e, 0x4c, 0xb5, 0x9e, 0x6f, 0x6a,
+	0x49, 0x46, 0x7e, 0x8a, 0x7f, 0x41, 0x49, 0x66, 0x7e, 0x5e, 0xb1, 0xc4, 0xb5, 0x89, 0xcc, 0x0a,
+	0x8c, 0x1a, 0x1c, 0x41, 0x6c, 0xf9, 0x79, 0xa9, 0xe1, 0x89, 0x95, 0x4e, 0x26, 0x51, 0x46, 0xe9,
+	0x99, 0x25, 0x19, 0xa5, 0x49, 0x7a, 0xc9, 0xf9, 0xb9, 0xfa, 0xc9, 0x19, 0x89, 0x99, 0x46, 0x06,
+	0x86, 0x06, 0xfa, 0x30, 0x17, 0xe8, 0x83, 0x5c, 0xa0, 0x57, 0x90, 0x64, 0x0d, 0x13, 0x88, 0x07,
+	0x09, 0x24, 0xb1, 0x81, 0xb9, 0xc6, 0x80, 0x01, 0x00, 0xc9, 0x77, 0xcf, 0xff, 0xba, 0x00, 0x00,
+	0x00,
+}
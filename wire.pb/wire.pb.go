@@ -4,7 +4,6 @@
 /*
 Package protorpc_wire is a generated protocol buffer package.
 
-
 	protorpc wire format wrapper
 
 	0. Frame Format
@@ -27,12 +26,133 @@ Package protorpc_wire is a generated protocol buffer package.
 	Recv ResponseHeader: recvFrame(zsock, hdr, max_hdr_len, 0)
 	Recv Response: recvFrame(zsock, body, hdr.snappy_compressed_response_len, 0)
 
+	5. Compression
+	The body is compressed with the compressor named by hdr.compression,
+	and hdr.snappy_compressed_*_len holds the compressed length for any
+	compressor. If hdr.snappy_compressed_*_len is 0 the body is not
+	compressed (small bodies), hdr.compression still names the compressor
+	the peer prefers.
+	Old peers do not send hdr.compression: the body is snappy compressed
+	if hdr.snappy_compressed_*_len != 0.
+	The server replies with the compressor named by the request.
+
+	6. Checksum
+	The (compressed) body is checked with the algorithm named by
+	hdr.checksum_type: 32-bit checksums are in hdr.checksum, 64-bit
+	checksums in hdr.checksum64. If hdr.checksum_type is set, the checksum
+	is verified even if it is 0.
+	Old peers do not send hdr.checksum_type: the checksum is crc32 (IEEE),
+	and hdr.checksum == 0 means no checksum.
+	The server replies with the checksum type of the request.
+
+	7. Status
+	A failed call has a non-empty hdr.error (the error text, understood
+	by old peers) and hdr.status (the error code and details).
+
+	8. Metadata
+	The client sends the request metadata in hdr.metadata, the server
+	sends the response trailer in hdr.trailer. A key may be repeated
+	to send several values; keys are lower case.
+	The size of the metadata (the sum of the key and value lengths) is
+	limited to MAX_METADATA_LEN, and max_hdr_len of a request header
+	is MAX_REQUEST_HEADER_LEN + MAX_METADATA_LEN. Old servers limit the
+	whole request header to MAX_REQUEST_HEADER_LEN.
+
+	9. Deadline
+	hdr.timeout is the time in microseconds the client waits for the
+	response, counted from when the request is sent; 0 means no deadline.
+	The server answers a request received after its deadline with the
+	DeadlineExceeded status code (4), without serving it.
+
+	10. Cancel
+	A request header with hdr.frame_type = FRAME_CANCEL, followed by an
+	empty body, cancels the request hdr.id. The server cancels the
+	handler and sends no response for the canceled request, nor for the
+	cancel frame. Clients only send cancel frames to servers which
+	accepted FEATURE_CANCEL in the handshake.
+
+	11. Handshake
+	A client may start the connection with a preamble: the magic bytes
+	"\x00PRPC" and a Handshake frame. The server replies with the same
+	magic bytes and a HandshakeReply frame before any response. A legacy
+	connection starts with a RequestHeader frame, whose first byte (the
+	uvarint frame length) is never 0.
+	The features of the reply are the features of the client accepted by
+	the server; the client only uses these. If reply.error is not empty,
+	the server closes the connection.
+
+	12. Keepalive
+	A request or response header with hdr.frame_type = FRAME_PING,
+	followed by an empty body, asks the peer to reply with a FRAME_PONG
+	header with the same hdr.id and an empty body. Peers only send pings
+	if FEATURE_PING was accepted in the handshake. Any frame received
+	from the peer shows that it is alive.
+
+	13. Streaming
+	A stream is a call to a streaming method: the client opens it with a
+	request (FRAME_CALL) with an empty body, and the server ends it with
+	the response of the call, whose hdr.frame_type is FRAME_STREAM_END,
+	with an empty body, the status and the trailer.
+	In between, both peers send messages of the stream hdr.id as a header
+	with hdr.frame_type = FRAME_STREAM_DATA followed by the message body.
+	The client sends a FRAME_STREAM_HALF_CLOSE request header with an
+	empty body after its last message, and may cancel the stream with a
+	FRAME_CANCEL frame. Clients only open streams on servers which
+	accepted FEATURE_STREAMING in the handshake.
+
+	14. Multiplexing
+	If FEATURE_MUX is accepted in the handshake, a body may be sent in
+	chunks, so that the bodies of several ids are interleaved: the header
+	is sent with hdr.more = true and the first chunk as body, the next
+	chunks are sent as a header with hdr.frame_type = FRAME_CHUNK, the
+	same hdr.id and hdr.more = true but for the last chunk. The lengths
+	and checksum of the first header are the ones of the whole body.
+	The body bytes sent to a peer are limited by a window per id and a
+	window for the connection. The initial windows are the stream_window
+	and conn_window of the handshake of the peer (DEFAULT_STREAM_WINDOW
+	and DEFAULT_CONN_WINDOW if 0). A peer grants more bytes with a header
+	with hdr.frame_type = FRAME_WINDOW_UPDATE, hdr.window bytes for the
+	id hdr.id and hdr.conn_window bytes for the connection, followed by
+	an empty body. Frames with an empty body are not limited.
+
+	15. Serialization
+	The message of a body is marshaled by the serializer named by
+	hdr.serialization: binary protobuf if SERIALIZATION_PROTO, or 0 for
+	old peers, protobuf JSON if SERIALIZATION_JSON. The body is then
+	compressed and checked like any body.
+	The server replies with the serializer of the request. Clients only
+	send JSON to servers which accepted FEATURE_JSON in the handshake.
+
+	16. One-way
+	A request with hdr.one_way = true gets no response: the server serves
+	it and sends nothing back, its error, if any, stays on the server.
+	Old servers ignore hdr.one_way and reply, the client drops the
+	response of an id it does not wait for.
+
+	17. Priority
+	hdr.priority orders the requests waiting for a server which limits
+	the calls served at once: the highest first, 0 by default. A request
+	gains one priority level for each aging period it waits, so that low
+	priority requests are served too. Old servers ignore hdr.priority.
+
+	18. Trace context
+	hdr.traceparent and hdr.tracestate carry the W3C trace context of
+	the request (https://www.w3.org/TR/trace-context/), the values of the
+	traceparent and tracestate HTTP headers. The server drops a malformed
+	traceparent, and the tracestate with it. Old servers ignore them.
 
 It is generated from these files:
+
 	wire.proto
+	protorpc.proto
 
 It has these top-level messages:
+
+	Handshake
+	HandshakeReply
 	RequestHeader
+	KeyValue
+	Status
 	ResponseHeader
 */
 package protorpc_wire
@@ -40,6 +160,7 @@ package protorpc_wire
 import proto "github.com/golang/protobuf/proto"
 import fmt "fmt"
 import math "math"
+import google_protobuf "github.com/golang/protobuf/ptypes/any"
 
 // Reference imports to suppress errors if they are not otherwise used.
 var _ = proto.Marshal
@@ -57,15 +178,27 @@ type Const int32
 const (
 	Const_ZERO                   Const = 0
 	Const_MAX_REQUEST_HEADER_LEN Const = 1024
+	Const_MAX_METADATA_LEN       Const = 16384
+	Const_PROTOCOL_VERSION       Const = 1
+	Const_DEFAULT_STREAM_WINDOW  Const = 65536
+	Const_DEFAULT_CONN_WINDOW    Const = 1048576
 )
 
 var Const_name = map[int32]string{
-	0:    "ZERO",
-	1024: "MAX_REQUEST_HEADER_LEN",
+	0:       "ZERO",
+	1024:    "MAX_REQUEST_HEADER_LEN",
+	16384:   "MAX_METADATA_LEN",
+	1:       "PROTOCOL_VERSION",
+	65536:   "DEFAULT_STREAM_WINDOW",
+	1048576: "DEFAULT_CONN_WINDOW",
 }
 var Const_value = map[string]int32{
 	"ZERO":                   0,
 	"MAX_REQUEST_HEADER_LEN": 1024,
+	"MAX_METADATA_LEN":       16384,
+	"PROTOCOL_VERSION":       1,
+	"DEFAULT_STREAM_WINDOW":  65536,
+	"DEFAULT_CONN_WINDOW":    1048576,
 }
 
 func (x Const) String() string {
@@ -73,18 +206,302 @@ func (x Const) String() string {
 }
 func (Const) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
 
+// Feature bits of Handshake.features.
+// crc32 (IEEE) checksums and uncompressed bodies are always supported.
+type Feature int32
+
+const (
+	Feature_FEATURE_NONE      Feature = 0
+	Feature_FEATURE_SNAPPY    Feature = 1
+	Feature_FEATURE_GZIP      Feature = 2
+	Feature_FEATURE_DEFLATE   Feature = 4
+	Feature_FEATURE_CRC32C    Feature = 8
+	Feature_FEATURE_XXHASH64  Feature = 16
+	Feature_FEATURE_METADATA  Feature = 32
+	Feature_FEATURE_DEADLINE  Feature = 64
+	Feature_FEATURE_CANCEL    Feature = 128
+	Feature_FEATURE_STREAMING Feature = 256
+	Feature_FEATURE_PING      Feature = 512
+	Feature_FEATURE_MUX       Feature = 1024
+	Feature_FEATURE_JSON      Feature = 2048
+)
+
+var Feature_name = map[int32]string{
+	0:    "FEATURE_NONE",
+	1:    "FEATURE_SNAPPY",
+	2:    "FEATURE_GZIP",
+	4:    "FEATURE_DEFLATE",
+	8:    "FEATURE_CRC32C",
+	16:   "FEATURE_XXHASH64",
+	32:   "FEATURE_METADATA",
+	64:   "FEATURE_DEADLINE",
+	128:  "FEATURE_CANCEL",
+	256:  "FEATURE_STREAMING",
+	512:  "FEATURE_PING",
+	1024: "FEATURE_MUX",
+	2048: "FEATURE_JSON",
+}
+var Feature_value = map[string]int32{
+	"FEATURE_NONE":      0,
+	"FEATURE_SNAPPY":    1,
+	"FEATURE_GZIP":      2,
+	"FEATURE_DEFLATE":   4,
+	"FEATURE_CRC32C":    8,
+	"FEATURE_XXHASH64":  16,
+	"FEATURE_METADATA":  32,
+	"FEATURE_DEADLINE":  64,
+	"FEATURE_CANCEL":    128,
+	"FEATURE_STREAMING": 256,
+	"FEATURE_PING":      512,
+	"FEATURE_MUX":       1024,
+	"FEATURE_JSON":      2048,
+}
+
+func (x Feature) String() string {
+	return proto.EnumName(Feature_name, int32(x))
+}
+func (Feature) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+type FrameType int32
+
+const (
+	FrameType_FRAME_CALL              FrameType = 0
+	FrameType_FRAME_CANCEL            FrameType = 1
+	FrameType_FRAME_PING              FrameType = 2
+	FrameType_FRAME_PONG              FrameType = 3
+	FrameType_FRAME_STREAM_DATA       FrameType = 4
+	FrameType_FRAME_STREAM_HALF_CLOSE FrameType = 5
+	FrameType_FRAME_STREAM_END        FrameType = 6
+	FrameType_FRAME_CHUNK             FrameType = 7
+	FrameType_FRAME_WINDOW_UPDATE     FrameType = 8
+)
+
+var FrameType_name = map[int32]string{
+	0: "FRAME_CALL",
+	1: "FRAME_CANCEL",
+	2: "FRAME_PING",
+	3: "FRAME_PONG",
+	4: "FRAME_STREAM_DATA",
+	5: "FRAME_STREAM_HALF_CLOSE",
+	6: "FRAME_STREAM_END",
+	7: "FRAME_CHUNK",
+	8: "FRAME_WINDOW_UPDATE",
+}
+var FrameType_value = map[string]int32{
+	"FRAME_CALL":              0,
+	"FRAME_CANCEL":            1,
+	"FRAME_PING":              2,
+	"FRAME_PONG":              3,
+	"FRAME_STREAM_DATA":       4,
+	"FRAME_STREAM_HALF_CLOSE": 5,
+	"FRAME_STREAM_END":        6,
+	"FRAME_CHUNK":             7,
+	"FRAME_WINDOW_UPDATE":     8,
+}
+
+func (x FrameType) String() string {
+	return proto.EnumName(FrameType_name, int32(x))
+}
+func (FrameType) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+type CompressionType int32
+
+const (
+	CompressionType_COMPRESSION_DEFAULT CompressionType = 0
+	CompressionType_COMPRESSION_NONE    CompressionType = 1
+	CompressionType_COMPRESSION_SNAPPY  CompressionType = 2
+	CompressionType_COMPRESSION_GZIP    CompressionType = 3
+	CompressionType_COMPRESSION_DEFLATE CompressionType = 4
+)
+
+var CompressionType_name = map[int32]string{
+	0: "COMPRESSION_DEFAULT",
+	1: "COMPRESSION_NONE",
+	2: "COMPRESSION_SNAPPY",
+	3: "COMPRESSION_GZIP",
+	4: "COMPRESSION_DEFLATE",
+}
+var CompressionType_value = map[string]int32{
+	"COMPRESSION_DEFAULT": 0,
+	"COMPRESSION_NONE":    1,
+	"COMPRESSION_SNAPPY":  2,
+	"COMPRESSION_GZIP":    3,
+	"COMPRESSION_DEFLATE": 4,
+}
+
+func (x CompressionType) String() string {
+	return proto.EnumName(CompressionType_name, int32(x))
+}
+func (CompressionType) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+type SerializationType int32
+
+const (
+	SerializationType_SERIALIZATION_DEFAULT SerializationType = 0
+	SerializationType_SERIALIZATION_PROTO   SerializationType = 1
+	SerializationType_SERIALIZATION_JSON    SerializationType = 2
+)
+
+var SerializationType_name = map[int32]string{
+	0: "SERIALIZATION_DEFAULT",
+	1: "SERIALIZATION_PROTO",
+	2: "SERIALIZATION_JSON",
+}
+var SerializationType_value = map[string]int32{
+	"SERIALIZATION_DEFAULT": 0,
+	"SERIALIZATION_PROTO":   1,
+	"SERIALIZATION_JSON":    2,
+}
+
+func (x SerializationType) String() string {
+	return proto.EnumName(SerializationType_name, int32(x))
+}
+func (SerializationType) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+type ChecksumType int32
+
+const (
+	ChecksumType_CHECKSUM_DEFAULT    ChecksumType = 0
+	ChecksumType_CHECKSUM_NONE       ChecksumType = 1
+	ChecksumType_CHECKSUM_CRC32_IEEE ChecksumType = 2
+	ChecksumType_CHECKSUM_CRC32C     ChecksumType = 3
+	ChecksumType_CHECKSUM_XXHASH64   ChecksumType = 4
+)
+
+var ChecksumType_name = map[int32]string{
+	0: "CHECKSUM_DEFAULT",
+	1: "CHECKSUM_NONE",
+	2: "CHECKSUM_CRC32_IEEE",
+	3: "CHECKSUM_CRC32C",
+	4: "CHECKSUM_XXHASH64",
+}
+var ChecksumType_value = map[string]int32{
+	"CHECKSUM_DEFAULT":    0,
+	"CHECKSUM_NONE":       1,
+	"CHECKSUM_CRC32_IEEE": 2,
+	"CHECKSUM_CRC32C":     3,
+	"CHECKSUM_XXHASH64":   4,
+}
+
+func (x ChecksumType) String() string {
+	return proto.EnumName(ChecksumType_name, int32(x))
+}
+func (ChecksumType) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+type Handshake struct {
+	Version      uint32 `protobuf:"varint,1,opt,name=version" json:"version,omitempty"`
+	Features     uint64 `protobuf:"varint,2,opt,name=features" json:"features,omitempty"`
+	StreamWindow uint32 `protobuf:"varint,3,opt,name=stream_window,json=streamWindow" json:"stream_window,omitempty"`
+	ConnWindow   uint32 `protobuf:"varint,4,opt,name=conn_window,json=connWindow" json:"conn_window,omitempty"`
+}
+
+func (m *Handshake) Reset()                    { *m = Handshake{} }
+func (m *Handshake) String() string            { return proto.CompactTextString(m) }
+func (*Handshake) ProtoMessage()               {}
+func (*Handshake) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+func (m *Handshake) GetVersion() uint32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *Handshake) GetFeatures() uint64 {
+	if m != nil {
+		return m.Features
+	}
+	return 0
+}
+
+func (m *Handshake) GetStreamWindow() uint32 {
+	if m != nil {
+		return m.StreamWindow
+	}
+	return 0
+}
+
+func (m *Handshake) GetConnWindow() uint32 {
+	if m != nil {
+		return m.ConnWindow
+	}
+	return 0
+}
+
+type HandshakeReply struct {
+	Version      uint32 `protobuf:"varint,1,opt,name=version" json:"version,omitempty"`
+	Features     uint64 `protobuf:"varint,2,opt,name=features" json:"features,omitempty"`
+	Error        string `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+	StreamWindow uint32 `protobuf:"varint,4,opt,name=stream_window,json=streamWindow" json:"stream_window,omitempty"`
+	ConnWindow   uint32 `protobuf:"varint,5,opt,name=conn_window,json=connWindow" json:"conn_window,omitempty"`
+}
+
+func (m *HandshakeReply) Reset()                    { *m = HandshakeReply{} }
+func (m *HandshakeReply) String() string            { return proto.CompactTextString(m) }
+func (*HandshakeReply) ProtoMessage()               {}
+func (*HandshakeReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *HandshakeReply) GetVersion() uint32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *HandshakeReply) GetFeatures() uint64 {
+	if m != nil {
+		return m.Features
+	}
+	return 0
+}
+
+func (m *HandshakeReply) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *HandshakeReply) GetStreamWindow() uint32 {
+	if m != nil {
+		return m.StreamWindow
+	}
+	return 0
+}
+
+func (m *HandshakeReply) GetConnWindow() uint32 {
+	if m != nil {
+		return m.ConnWindow
+	}
+	return 0
+}
+
 type RequestHeader struct {
-	Id                         uint64 `protobuf:"varint,1,opt,name=id" json:"id,omitempty"`
-	Method                     string `protobuf:"bytes,2,opt,name=method" json:"method,omitempty"`
-	RawRequestLen              uint32 `protobuf:"varint,3,opt,name=raw_request_len,json=rawRequestLen" json:"raw_request_len,omitempty"`
-	SnappyCompressedRequestLen uint32 `protobuf:"varint,4,opt,name=snappy_compressed_request_len,json=snappyCompressedRequestLen" json:"snappy_compressed_request_len,omitempty"`
-	Checksum                   uint32 `protobuf:"varint,5,opt,name=checksum" json:"checksum,omitempty"`
+	Id                         uint64       `protobuf:"varint,1,opt,name=id" json:"id,omitempty"`
+	Method                     string       `protobuf:"bytes,2,opt,name=method" json:"method,omitempty"`
+	RawRequestLen              uint32       `protobuf:"varint,3,opt,name=raw_request_len,json=rawRequestLen" json:"raw_request_len,omitempty"`
+	SnappyCompressedRequestLen uint32       `protobuf:"varint,4,opt,name=snappy_compressed_request_len,json=snappyCompressedRequestLen" json:"snappy_compressed_request_len,omitempty"`
+	Checksum                   uint32       `protobuf:"varint,5,opt,name=checksum" json:"checksum,omitempty"`
+	Compression                uint32       `protobuf:"varint,6,opt,name=compression" json:"compression,omitempty"`
+	ChecksumType               ChecksumType `protobuf:"varint,7,opt,name=checksum_type,json=checksumType,enum=protorpc.wire.ChecksumType" json:"checksum_type,omitempty"`
+	Checksum64                 uint64       `protobuf:"fixed64,8,opt,name=checksum64" json:"checksum64,omitempty"`
+	Metadata                   []*KeyValue  `protobuf:"bytes,9,rep,name=metadata" json:"metadata,omitempty"`
+	Timeout                    uint64       `protobuf:"varint,10,opt,name=timeout" json:"timeout,omitempty"`
+	FrameType                  FrameType    `protobuf:"varint,11,opt,name=frame_type,json=frameType,enum=protorpc.wire.FrameType" json:"frame_type,omitempty"`
+	More                       bool         `protobuf:"varint,12,opt,name=more" json:"more,omitempty"`
+	Window                     uint32       `protobuf:"varint,13,opt,name=window" json:"window,omitempty"`
+	ConnWindow                 uint32       `protobuf:"varint,14,opt,name=conn_window,json=connWindow" json:"conn_window,omitempty"`
+	Serialization              uint32       `protobuf:"varint,15,opt,name=serialization" json:"serialization,omitempty"`
+	OneWay                     bool         `protobuf:"varint,16,opt,name=one_way,json=oneWay" json:"one_way,omitempty"`
+	Priority                   int32        `protobuf:"varint,17,opt,name=priority" json:"priority,omitempty"`
+	Traceparent                string       `protobuf:"bytes,18,opt,name=traceparent" json:"traceparent,omitempty"`
+	Tracestate                 string       `protobuf:"bytes,19,opt,name=tracestate" json:"tracestate,omitempty"`
 }
 
 func (m *RequestHeader) Reset()                    { *m = RequestHeader{} }
 func (m *RequestHeader) String() string            { return proto.CompactTextString(m) }
 func (*RequestHeader) ProtoMessage()               {}
-func (*RequestHeader) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+func (*RequestHeader) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
 
 func (m *RequestHeader) GetId() uint64 {
 	if m != nil {
@@ -121,18 +538,182 @@ func (m *RequestHeader) GetChecksum() uint32 {
 	return 0
 }
 
+func (m *RequestHeader) GetCompression() uint32 {
+	if m != nil {
+		return m.Compression
+	}
+	return 0
+}
+
+func (m *RequestHeader) GetChecksumType() ChecksumType {
+	if m != nil {
+		return m.ChecksumType
+	}
+	return ChecksumType_CHECKSUM_DEFAULT
+}
+
+func (m *RequestHeader) GetChecksum64() uint64 {
+	if m != nil {
+		return m.Checksum64
+	}
+	return 0
+}
+
+func (m *RequestHeader) GetMetadata() []*KeyValue {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+func (m *RequestHeader) GetTimeout() uint64 {
+	if m != nil {
+		return m.Timeout
+	}
+	return 0
+}
+
+func (m *RequestHeader) GetFrameType() FrameType {
+	if m != nil {
+		return m.FrameType
+	}
+	return FrameType_FRAME_CALL
+}
+
+func (m *RequestHeader) GetMore() bool {
+	if m != nil {
+		return m.More
+	}
+	return false
+}
+
+func (m *RequestHeader) GetWindow() uint32 {
+	if m != nil {
+		return m.Window
+	}
+	return 0
+}
+
+func (m *RequestHeader) GetConnWindow() uint32 {
+	if m != nil {
+		return m.ConnWindow
+	}
+	return 0
+}
+
+func (m *RequestHeader) GetSerialization() uint32 {
+	if m != nil {
+		return m.Serialization
+	}
+	return 0
+}
+
+func (m *RequestHeader) GetOneWay() bool {
+	if m != nil {
+		return m.OneWay
+	}
+	return false
+}
+
+func (m *RequestHeader) GetPriority() int32 {
+	if m != nil {
+		return m.Priority
+	}
+	return 0
+}
+
+func (m *RequestHeader) GetTraceparent() string {
+	if m != nil {
+		return m.Traceparent
+	}
+	return ""
+}
+
+func (m *RequestHeader) GetTracestate() string {
+	if m != nil {
+		return m.Tracestate
+	}
+	return ""
+}
+
+type KeyValue struct {
+	Key   string `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
+	Value string `protobuf:"bytes,2,opt,name=value" json:"value,omitempty"`
+}
+
+func (m *KeyValue) Reset()                    { *m = KeyValue{} }
+func (m *KeyValue) String() string            { return proto.CompactTextString(m) }
+func (*KeyValue) ProtoMessage()               {}
+func (*KeyValue) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *KeyValue) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *KeyValue) GetValue() string {
+	if m != nil {
+		return m.Value
+	}
+	return ""
+}
+
+type Status struct {
+	Code    uint32                 `protobuf:"varint,1,opt,name=code" json:"code,omitempty"`
+	Message string                 `protobuf:"bytes,2,opt,name=message" json:"message,omitempty"`
+	Details []*google_protobuf.Any `protobuf:"bytes,3,rep,name=details" json:"details,omitempty"`
+}
+
+func (m *Status) Reset()                    { *m = Status{} }
+func (m *Status) String() string            { return proto.CompactTextString(m) }
+func (*Status) ProtoMessage()               {}
+func (*Status) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *Status) GetCode() uint32 {
+	if m != nil {
+		return m.Code
+	}
+	return 0
+}
+
+func (m *Status) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+func (m *Status) GetDetails() []*google_protobuf.Any {
+	if m != nil {
+		return m.Details
+	}
+	return nil
+}
+
 type ResponseHeader struct {
-	Id                          uint64 `protobuf:"varint,1,opt,name=id" json:"id,omitempty"`
-	Error                       string `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
-	RawResponseLen              uint32 `protobuf:"varint,3,opt,name=raw_response_len,json=rawResponseLen" json:"raw_response_len,omitempty"`
-	SnappyCompressedResponseLen uint32 `protobuf:"varint,4,opt,name=snappy_compressed_response_len,json=snappyCompressedResponseLen" json:"snappy_compressed_response_len,omitempty"`
-	Checksum                    uint32 `protobuf:"varint,5,opt,name=checksum" json:"checksum,omitempty"`
+	Id                          uint64       `protobuf:"varint,1,opt,name=id" json:"id,omitempty"`
+	Error                       string       `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+	RawResponseLen              uint32       `protobuf:"varint,3,opt,name=raw_response_len,json=rawResponseLen" json:"raw_response_len,omitempty"`
+	SnappyCompressedResponseLen uint32       `protobuf:"varint,4,opt,name=snappy_compressed_response_len,json=snappyCompressedResponseLen" json:"snappy_compressed_response_len,omitempty"`
+	Checksum                    uint32       `protobuf:"varint,5,opt,name=checksum" json:"checksum,omitempty"`
+	Compression                 uint32       `protobuf:"varint,6,opt,name=compression" json:"compression,omitempty"`
+	ChecksumType                ChecksumType `protobuf:"varint,7,opt,name=checksum_type,json=checksumType,enum=protorpc.wire.ChecksumType" json:"checksum_type,omitempty"`
+	Checksum64                  uint64       `protobuf:"fixed64,8,opt,name=checksum64" json:"checksum64,omitempty"`
+	Status                      *Status      `protobuf:"bytes,9,opt,name=status" json:"status,omitempty"`
+	Trailer                     []*KeyValue  `protobuf:"bytes,10,rep,name=trailer" json:"trailer,omitempty"`
+	FrameType                   FrameType    `protobuf:"varint,11,opt,name=frame_type,json=frameType,enum=protorpc.wire.FrameType" json:"frame_type,omitempty"`
+	More                        bool         `protobuf:"varint,12,opt,name=more" json:"more,omitempty"`
+	Window                      uint32       `protobuf:"varint,13,opt,name=window" json:"window,omitempty"`
+	ConnWindow                  uint32       `protobuf:"varint,14,opt,name=conn_window,json=connWindow" json:"conn_window,omitempty"`
+	Serialization               uint32       `protobuf:"varint,15,opt,name=serialization" json:"serialization,omitempty"`
 }
 
 func (m *ResponseHeader) Reset()                    { *m = ResponseHeader{} }
 func (m *ResponseHeader) String() string            { return proto.CompactTextString(m) }
 func (*ResponseHeader) ProtoMessage()               {}
-func (*ResponseHeader) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+func (*ResponseHeader) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
 
 func (m *ResponseHeader) GetId() uint64 {
 	if m != nil {
@@ -169,33 +750,168 @@ func (m *ResponseHeader) GetChecksum() uint32 {
 	return 0
 }
 
+func (m *ResponseHeader) GetCompression() uint32 {
+	if m != nil {
+		return m.Compression
+	}
+	return 0
+}
+
+func (m *ResponseHeader) GetChecksumType() ChecksumType {
+	if m != nil {
+		return m.ChecksumType
+	}
+	return ChecksumType_CHECKSUM_DEFAULT
+}
+
+func (m *ResponseHeader) GetChecksum64() uint64 {
+	if m != nil {
+		return m.Checksum64
+	}
+	return 0
+}
+
+func (m *ResponseHeader) GetStatus() *Status {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *ResponseHeader) GetTrailer() []*KeyValue {
+	if m != nil {
+		return m.Trailer
+	}
+	return nil
+}
+
+func (m *ResponseHeader) GetFrameType() FrameType {
+	if m != nil {
+		return m.FrameType
+	}
+	return FrameType_FRAME_CALL
+}
+
+func (m *ResponseHeader) GetMore() bool {
+	if m != nil {
+		return m.More
+	}
+	return false
+}
+
+func (m *ResponseHeader) GetWindow() uint32 {
+	if m != nil {
+		return m.Window
+	}
+	return 0
+}
+
+func (m *ResponseHeader) GetConnWindow() uint32 {
+	if m != nil {
+		return m.ConnWindow
+	}
+	return 0
+}
+
+func (m *ResponseHeader) GetSerialization() uint32 {
+	if m != nil {
+		return m.Serialization
+	}
+	return 0
+}
+
 func init() {
+	proto.RegisterType((*Handshake)(nil), "protorpc.wire.Handshake")
+	proto.RegisterType((*HandshakeReply)(nil), "protorpc.wire.HandshakeReply")
 	proto.RegisterType((*RequestHeader)(nil), "protorpc.wire.RequestHeader")
+	proto.RegisterType((*KeyValue)(nil), "protorpc.wire.KeyValue")
+	proto.RegisterType((*Status)(nil), "protorpc.wire.Status")
 	proto.RegisterType((*ResponseHeader)(nil), "protorpc.wire.ResponseHeader")
 	proto.RegisterEnum("protorpc.wire.Const", Const_name, Const_value)
+	proto.RegisterEnum("protorpc.wire.Feature", Feature_name, Feature_value)
+	proto.RegisterEnum("protorpc.wire.FrameType", FrameType_name, FrameType_value)
+	proto.RegisterEnum("protorpc.wire.CompressionType", CompressionType_name, CompressionType_value)
+	proto.RegisterEnum("protorpc.wire.SerializationType", SerializationType_name, SerializationType_value)
+	proto.RegisterEnum("protorpc.wire.ChecksumType", ChecksumType_name, ChecksumType_value)
 }
 
 func init() { proto.RegisterFile("wire.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 292 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x91, 0x5f, 0x4b, 0xf3, 0x30,
-	0x14, 0xc6, 0xdf, 0xec, 0xdd, 0xc6, 0x3c, 0xd0, 0x5a, 0x82, 0x8c, 0xb2, 0xa1, 0x94, 0x5d, 0x48,
-	0xf1, 0xa2, 0x37, 0x7e, 0x82, 0x52, 0x03, 0xbb, 0x98, 0x8a, 0x51, 0x41, 0xbc, 0x09, 0xb5, 0x3d,
-	0xb0, 0xa2, 0x6d, 0x62, 0xd2, 0x51, 0xbc, 0xf3, 0x93, 0x09, 0x7e, 0x33, 0x59, 0x53, 0x66, 0xc5,
-	0x3f, 0x57, 0xe1, 0x1c, 0x9e, 0xdf, 0x93, 0xfc, 0x08, 0x40, 0x53, 0x68, 0x8c, 0x94, 0x96, 0xb5,
-	0xa4, 0x4e, 0x7b, 0x68, 0x95, 0x45, 0xdb, 0xe5, 0xe2, 0x8d, 0x80, 0xc3, 0xf1, 0x79, 0x83, 0xa6,
-	0x5e, 0x62, 0x9a, 0xa3, 0xa6, 0x2e, 0x0c, 0x8a, 0xdc, 0x27, 0x01, 0x09, 0x87, 0x7c, 0x50, 0xe4,
-	0x74, 0x0a, 0xe3, 0x12, 0xeb, 0xb5, 0xcc, 0xfd, 0x41, 0x40, 0xc2, 0x3d, 0xde, 0x4d, 0xf4, 0x18,
-	0xf6, 0x75, 0xda, 0x08, 0x6d, 0x61, 0xf1, 0x84, 0x95, 0xff, 0x3f, 0x20, 0xa1, 0xc3, 0x1d, 0x9d,
-	0x36, 0x5d, 0xe5, 0x0a, 0x2b, 0x1a, 0xc3, 0xa1, 0xa9, 0x52, 0xa5, 0x5e, 0x44, 0x26, 0x4b, 0xa5,
-	0xd1, 0x18, 0xcc, 0xbf, 0x50, 0xc3, 0x96, 0x9a, 0xd9, 0x50, 0xb2, 0xcb, 0xf4, 0x2a, 0x66, 0x30,
-	0xc9, 0xd6, 0x98, 0x3d, 0x9a, 0x4d, 0xe9, 0x8f, 0xda, 0xf4, 0x6e, 0x5e, 0xbc, 0x13, 0x70, 0x39,
-	0x1a, 0x25, 0x2b, 0x83, 0xbf, 0x18, 0x1c, 0xc0, 0x08, 0xb5, 0x96, 0xba, 0x13, 0xb0, 0x03, 0x0d,
-	0xc1, 0xb3, 0xef, 0xb7, 0x6c, 0x4f, 0xc0, 0x6d, 0x05, 0xec, 0x7a, 0x7b, 0x7d, 0x02, 0x47, 0x3f,
-	0x19, 0xf4, 0x38, 0xab, 0x30, 0xff, 0xae, 0xf0, 0x59, 0xf2, 0x87, 0xc3, 0x49, 0x04, 0xa3, 0x44,
-	0x56, 0xa6, 0xa6, 0x13, 0x18, 0xde, 0x33, 0x7e, 0xe9, 0xfd, 0xa3, 0x73, 0x98, 0x9e, 0xc7, 0x77,
-	0x82, 0xb3, 0xab, 0x5b, 0x76, 0x7d, 0x23, 0x96, 0x2c, 0x3e, 0x63, 0x5c, 0xac, 0xd8, 0x85, 0xf7,
-	0x3a, 0x79, 0x18, 0xb7, 0x7f, 0x78, 0xfa, 0x11, 0x00, 0x00, 0xff, 0xff, 0xc1, 0x10, 0xb3, 0xa4,
-	0xd8, 0x01, 0x00, 0x00,
+	// 1188 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xd4, 0x55, 0xcd, 0x6e, 0xdb, 0x46,
+	0x17, 0x35, 0x65, 0x59, 0x3f, 0xd7, 0x96, 0x3c, 0x1e, 0xc5, 0x36, 0x63, 0xe3, 0xcb, 0x27, 0xb8,
+	0x45, 0x21, 0x18, 0xa8, 0x82, 0x3a, 0x41, 0xba, 0x0d, 0x41, 0x8d, 0x22, 0x35, 0x32, 0xa5, 0x0e,
+	0xa5, 0xd8, 0xcd, 0x86, 0x98, 0x48, 0xe3, 0x84, 0x88, 0x44, 0xaa, 0x24, 0x15, 0x41, 0x45, 0x17,
+	0xda, 0x65, 0x5d, 0xa0, 0x4f, 0xd0, 0x67, 0xe8, 0x0b, 0xf4, 0x19, 0xfa, 0x42, 0xc5, 0xcc, 0x90,
+	0x34, 0xe5, 0xb8, 0x2d, 0xd0, 0x55, 0xbb, 0x12, 0xef, 0xb9, 0x67, 0xee, 0x9c, 0xfb, 0x37, 0x02,
+	0x58, 0xba, 0x01, 0x6f, 0xce, 0x03, 0x3f, 0xf2, 0x71, 0x45, 0xfe, 0x04, 0xf3, 0x71, 0x53, 0x80,
+	0x27, 0x0f, 0xdf, 0xfa, 0xfe, 0xdb, 0x29, 0x7f, 0x2c, 0xd1, 0x37, 0x8b, 0x9b, 0xc7, 0xcc, 0x5b,
+	0x29, 0xe6, 0xd9, 0x47, 0x0d, 0xca, 0x1d, 0xe6, 0x4d, 0xc2, 0x77, 0xec, 0x3d, 0xc7, 0x3a, 0x14,
+	0x3f, 0xf0, 0x20, 0x74, 0x7d, 0x4f, 0xd7, 0xea, 0x5a, 0xa3, 0x42, 0x13, 0x13, 0x9f, 0x40, 0xe9,
+	0x86, 0xb3, 0x68, 0x11, 0xf0, 0x50, 0xcf, 0xd5, 0xb5, 0x46, 0x9e, 0xa6, 0x36, 0xfe, 0x0c, 0x2a,
+	0x61, 0x14, 0x70, 0x36, 0x73, 0x96, 0xae, 0x37, 0xf1, 0x97, 0xfa, 0xb6, 0x3c, 0xbb, 0xa7, 0xc0,
+	0x2b, 0x89, 0xe1, 0xff, 0xc3, 0xee, 0xd8, 0xf7, 0xbc, 0x84, 0x92, 0x97, 0x14, 0x10, 0x90, 0x22,
+	0x9c, 0xfd, 0xa2, 0x41, 0x35, 0x55, 0x42, 0xf9, 0x7c, 0xba, 0xfa, 0x87, 0x72, 0x1e, 0xc0, 0x0e,
+	0x0f, 0x02, 0x3f, 0x90, 0x32, 0xca, 0x54, 0x19, 0x9f, 0x8a, 0xcc, 0xff, 0xbd, 0xc8, 0x9d, 0x4f,
+	0x44, 0xfe, 0xba, 0x03, 0x15, 0xca, 0xbf, 0x5f, 0xf0, 0x30, 0xea, 0x70, 0x36, 0xe1, 0x01, 0xae,
+	0x42, 0xce, 0x9d, 0x48, 0x79, 0x79, 0x9a, 0x73, 0x27, 0xf8, 0x08, 0x0a, 0x33, 0x1e, 0xbd, 0xf3,
+	0x27, 0x52, 0x57, 0x99, 0xc6, 0x16, 0xfe, 0x02, 0xf6, 0x03, 0xb6, 0x74, 0x02, 0x75, 0xd8, 0x99,
+	0x72, 0x2f, 0x2e, 0x53, 0x25, 0x60, 0xcb, 0x38, 0x64, 0x8f, 0x7b, 0xd8, 0x80, 0xff, 0x85, 0x1e,
+	0x9b, 0xcf, 0x57, 0xce, 0xd8, 0x9f, 0xcd, 0x03, 0x1e, 0x86, 0x7c, 0xb2, 0x71, 0x4a, 0xe9, 0x3e,
+	0x51, 0x24, 0x33, 0xe5, 0x64, 0x42, 0x9c, 0x40, 0x69, 0xfc, 0x8e, 0x8f, 0xdf, 0x87, 0x8b, 0x59,
+	0x9c, 0x42, 0x6a, 0xe3, 0xba, 0xc8, 0x50, 0x9d, 0x11, 0x65, 0x2d, 0x48, 0x77, 0x16, 0xc2, 0xcf,
+	0xa1, 0x92, 0xb0, 0x9d, 0x68, 0x35, 0xe7, 0x7a, 0xb1, 0xae, 0x35, 0xaa, 0x17, 0xa7, 0xcd, 0x8d,
+	0x99, 0x6a, 0x9a, 0x31, 0x67, 0xb8, 0x9a, 0x73, 0xba, 0x37, 0xce, 0x58, 0xf8, 0x11, 0x40, 0x62,
+	0x3f, 0x7b, 0xaa, 0x97, 0xea, 0x5a, 0xa3, 0x40, 0x33, 0x08, 0x7e, 0x02, 0xa5, 0x19, 0x8f, 0xd8,
+	0x84, 0x45, 0x4c, 0x2f, 0xd7, 0xb7, 0x1b, 0xbb, 0x17, 0xc7, 0x77, 0x82, 0xbf, 0xe4, 0xab, 0x57,
+	0x6c, 0xba, 0xe0, 0x34, 0x25, 0x8a, 0x59, 0x88, 0xdc, 0x19, 0xf7, 0x17, 0x91, 0x0e, 0xb2, 0xd8,
+	0x89, 0x89, 0xbf, 0x06, 0xb8, 0x09, 0xd8, 0x8c, 0x2b, 0xb5, 0xbb, 0x52, 0xad, 0x7e, 0x27, 0x60,
+	0x5b, 0x10, 0xa4, 0xd4, 0xf2, 0x4d, 0xf2, 0x89, 0x31, 0xe4, 0x67, 0x7e, 0xc0, 0xf5, 0xbd, 0xba,
+	0xd6, 0x28, 0x51, 0xf9, 0x2d, 0xda, 0x17, 0x37, 0xbf, 0x22, 0x4b, 0x53, 0x58, 0xde, 0x3b, 0x19,
+	0xd5, 0xbb, 0x93, 0x81, 0x3f, 0x87, 0x4a, 0xc8, 0x03, 0x97, 0x4d, 0xdd, 0x1f, 0x58, 0x24, 0x4a,
+	0xbb, 0xaf, 0xba, 0xbb, 0x01, 0xe2, 0x63, 0x28, 0xfa, 0x1e, 0x77, 0x96, 0x6c, 0xa5, 0x23, 0x79,
+	0x6b, 0xc1, 0xf7, 0xf8, 0x15, 0x5b, 0x89, 0x9e, 0xcd, 0x03, 0xd7, 0x0f, 0xdc, 0x68, 0xa5, 0x1f,
+	0xd4, 0xb5, 0xc6, 0x0e, 0x4d, 0x6d, 0xd1, 0xb3, 0x28, 0x60, 0x63, 0x3e, 0x67, 0x01, 0xf7, 0x22,
+	0x1d, 0xcb, 0xb9, 0xca, 0x42, 0xa2, 0xe2, 0xd2, 0x0c, 0x23, 0x16, 0x71, 0xbd, 0x26, 0x09, 0x19,
+	0xe4, 0xec, 0x02, 0x4a, 0x49, 0x49, 0x31, 0x82, 0xed, 0xf7, 0x7c, 0x25, 0x27, 0xb6, 0x4c, 0xc5,
+	0xa7, 0x58, 0x98, 0x0f, 0xc2, 0x15, 0x4f, 0xac, 0x32, 0xce, 0x6e, 0xa0, 0x60, 0x47, 0x2c, 0x5a,
+	0x84, 0xa2, 0x4e, 0x63, 0x7f, 0xc2, 0xe3, 0x1d, 0x94, 0xdf, 0xa2, 0x1d, 0x33, 0x1e, 0x86, 0xec,
+	0x6d, 0x72, 0x2a, 0x31, 0x71, 0x13, 0x8a, 0x13, 0x1e, 0x31, 0x77, 0x1a, 0xea, 0xdb, 0xb2, 0xb9,
+	0x0f, 0x9a, 0xea, 0xf9, 0x69, 0x26, 0xcf, 0x4f, 0xd3, 0xf0, 0x56, 0x34, 0x21, 0x9d, 0xfd, 0x9e,
+	0x87, 0x2a, 0xe5, 0xe1, 0xdc, 0xf7, 0x42, 0xfe, 0x27, 0x3b, 0x95, 0x6e, 0x74, 0x2e, 0xbb, 0xd1,
+	0x0d, 0x40, 0x6a, 0xa3, 0xd4, 0xd9, 0xcc, 0x4a, 0x55, 0xe5, 0x4a, 0x29, 0x58, 0x2c, 0x84, 0x09,
+	0x8f, 0xee, 0xdb, 0xa9, 0xcc, 0x39, 0xb5, 0x54, 0xa7, 0x9f, 0x2e, 0xd5, 0x6d, 0x90, 0x7f, 0xfb,
+	0x56, 0x7d, 0x09, 0x85, 0x50, 0xf6, 0x4b, 0x2f, 0xd7, 0xb5, 0xc6, 0xee, 0xc5, 0xe1, 0x9d, 0xd0,
+	0xaa, 0x99, 0x34, 0x26, 0xe1, 0xaf, 0xa0, 0x18, 0x05, 0xcc, 0x9d, 0xf2, 0x40, 0x87, 0xbf, 0xde,
+	0xc1, 0x84, 0xf7, 0x5f, 0x5a, 0xb4, 0xf3, 0x9f, 0x35, 0xd8, 0x31, 0x7d, 0x2f, 0x8c, 0x70, 0x09,
+	0xf2, 0xaf, 0x09, 0xed, 0xa3, 0x2d, 0x7c, 0x0a, 0x47, 0x97, 0xc6, 0xb5, 0x43, 0xc9, 0xb7, 0x23,
+	0x62, 0x0f, 0x9d, 0x0e, 0x31, 0x5a, 0x84, 0x3a, 0x3d, 0x62, 0xa1, 0x75, 0x09, 0x1f, 0x01, 0x12,
+	0xce, 0x4b, 0x32, 0x34, 0x5a, 0xc6, 0xd0, 0x50, 0xf0, 0x5a, 0xc3, 0x0f, 0x00, 0x0d, 0x68, 0x7f,
+	0xd8, 0x37, 0xfb, 0x3d, 0xe7, 0x15, 0xa1, 0x76, 0xb7, 0x6f, 0x21, 0x0d, 0x9f, 0xc2, 0x61, 0x8b,
+	0xb4, 0x8d, 0x51, 0x6f, 0xe8, 0xd8, 0x43, 0x4a, 0x8c, 0x4b, 0xe7, 0xaa, 0x6b, 0xb5, 0xfa, 0x57,
+	0x68, 0xbd, 0xce, 0xe3, 0x87, 0x50, 0x4b, 0x9c, 0x66, 0xdf, 0xb2, 0x6e, 0x5d, 0xcf, 0xcf, 0x7f,
+	0xca, 0x41, 0xb1, 0xad, 0xfe, 0xa8, 0x30, 0x82, 0xbd, 0x36, 0x31, 0x86, 0x23, 0x4a, 0x1c, 0xab,
+	0x6f, 0x11, 0xb4, 0x85, 0x31, 0x54, 0x13, 0xc4, 0xb6, 0x8c, 0xc1, 0xe0, 0x3b, 0xa4, 0x65, 0x59,
+	0x2f, 0x5e, 0x77, 0x07, 0x28, 0x87, 0x6b, 0xb0, 0x9f, 0x20, 0x2d, 0xd2, 0xee, 0x19, 0x43, 0x82,
+	0xf2, 0xd9, 0xa3, 0x26, 0x35, 0x9f, 0x5c, 0x98, 0xa8, 0x24, 0xa4, 0x27, 0xd8, 0xf5, 0x75, 0xc7,
+	0xb0, 0x3b, 0xcf, 0x9e, 0x22, 0x94, 0x45, 0x93, 0x64, 0x51, 0x3d, 0x8b, 0xb6, 0x88, 0xd1, 0xea,
+	0x75, 0x2d, 0x82, 0x9e, 0xe3, 0x5a, 0x26, 0xaa, 0x61, 0x99, 0xa4, 0x87, 0xd6, 0x1a, 0x3e, 0x82,
+	0x83, 0x54, 0xa5, 0xcc, 0xbd, 0x6b, 0xbd, 0x40, 0xeb, 0x1c, 0x3e, 0xb8, 0x55, 0x3a, 0x90, 0x50,
+	0x1e, 0x23, 0xd8, 0x4d, 0xef, 0x1a, 0x5d, 0x8b, 0x32, 0x67, 0x48, 0xdf, 0xd8, 0x7d, 0x0b, 0xad,
+	0xd1, 0xf9, 0x6f, 0x1a, 0x94, 0xd3, 0xb1, 0xc1, 0x55, 0x80, 0x36, 0x35, 0x2e, 0xc5, 0x85, 0xbd,
+	0x1e, 0xda, 0x92, 0xf9, 0xc7, 0xb6, 0x14, 0xa0, 0xdd, 0x32, 0xe4, 0x2d, 0xb9, 0x8c, 0xdd, 0xb7,
+	0x5e, 0xa0, 0x6d, 0x7c, 0x08, 0x07, 0xca, 0x8e, 0x3b, 0x23, 0x33, 0xcc, 0xe3, 0x53, 0x38, 0xde,
+	0x80, 0x3b, 0x46, 0xaf, 0xed, 0x98, 0xbd, 0xbe, 0x4d, 0xd0, 0x8e, 0x4c, 0x3f, 0xeb, 0x24, 0x56,
+	0x0b, 0x15, 0xf0, 0x3e, 0xec, 0xc6, 0x77, 0x77, 0x46, 0xd6, 0x4b, 0x54, 0xc4, 0xc7, 0x50, 0x53,
+	0x80, 0x6a, 0xa9, 0x33, 0x1a, 0xb4, 0x44, 0xf9, 0x4b, 0xe7, 0x1f, 0x35, 0xd8, 0x37, 0x6f, 0xd7,
+	0x5d, 0x66, 0x72, 0x0c, 0x35, 0xb3, 0x7f, 0x39, 0xa0, 0xc4, 0x16, 0x43, 0xe3, 0xc4, 0x23, 0x81,
+	0xb6, 0xc4, 0x65, 0x59, 0x87, 0x6c, 0xbe, 0x28, 0x2b, 0xce, 0xa2, 0xf1, 0x00, 0xe4, 0xee, 0xb2,
+	0xe5, 0x10, 0x6c, 0xdf, 0x13, 0x5c, 0x0d, 0xc2, 0xb9, 0x03, 0x07, 0x76, 0x76, 0x13, 0xa4, 0x94,
+	0x87, 0x70, 0x68, 0x13, 0xda, 0x35, 0x7a, 0xdd, 0xd7, 0xc6, 0x70, 0x53, 0xcc, 0x31, 0xd4, 0x36,
+	0x5d, 0x72, 0xda, 0x95, 0x9e, 0x4d, 0x87, 0xec, 0x57, 0xee, 0xfc, 0x47, 0xd8, 0xcb, 0xbe, 0x52,
+	0x52, 0x5f, 0x87, 0x98, 0x2f, 0xed, 0xd1, 0x65, 0x26, 0xec, 0x01, 0x54, 0x52, 0x34, 0x4e, 0x50,
+	0x48, 0x4e, 0x20, 0x39, 0xa3, 0x4e, 0x97, 0x10, 0xa2, 0x06, 0x7a, 0xd3, 0x61, 0xaa, 0x2e, 0xa6,
+	0x60, 0x3a, 0xbd, 0xf9, 0x37, 0x05, 0xf9, 0xdc, 0x3c, 0xf9, 0x63, 0x00, 0x27, 0x3f, 0x24, 0x82,
+	0xee, 0x0a, 0x00, 0x00,
 }